@@ -0,0 +1,94 @@
+package raytracer
+
+import "math"
+
+// AABB is an axis-aligned bounding box
+type AABB struct {
+	Min, Max Vector3
+}
+
+// EmptyAABB returns a bounding box that contains nothing, a Union with it returns the other box
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{
+		Min: Vector3{inf, inf, inf},
+		Max: Vector3{-inf, -inf, -inf},
+	}
+}
+
+// Hit returns true if the ray passes through the box between tMin and tMax
+func (b AABB) Hit(r Ray, tMin, tMax float64) bool {
+	_, _, hit := b.clip(r, tMin, tMax)
+	return hit
+}
+
+// clip returns the part of the ray between tMin and tMax inside the box, and false if there isn't any
+func (b AABB) clip(r Ray, tMin, tMax float64) (float64, float64, bool) {
+	// Source: https://raytracing.github.io/books/RayTracingTheNextWeek.html#boundingvolumehierarchies
+	for axis := 0; axis < 3; axis++ {
+		invD := 1.0 / r.Direction.Axis(axis)
+		t0 := (b.Min.Axis(axis) - r.Origin.Axis(axis)) * invD
+		t1 := (b.Max.Axis(axis) - r.Origin.Axis(axis)) * invD
+		if invD < 0.0 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMax < tMin {
+			return tMin, tMax, false
+		}
+	}
+	return tMin, tMax, true
+}
+
+// Union returns a new box enclosing both this and the other box
+func (b AABB) Union(o AABB) AABB {
+	return AABB{
+		Min: Vector3{math.Min(b.Min.X, o.Min.X), math.Min(b.Min.Y, o.Min.Y), math.Min(b.Min.Z, o.Min.Z)},
+		Max: Vector3{math.Max(b.Max.X, o.Max.X), math.Max(b.Max.Y, o.Max.Y), math.Max(b.Max.Z, o.Max.Z)},
+	}
+}
+
+// Extend returns a new box enclosing both this box and the point
+func (b AABB) Extend(p Vector3) AABB {
+	return b.Union(AABB{Min: p, Max: p})
+}
+
+// Centroid returns the center point of the box
+func (b AABB) Centroid() Vector3 {
+	return b.Min.Add(b.Max).Scale(0.5)
+}
+
+// SurfaceArea returns the surface area of the box, 0 for an empty box
+func (b AABB) SurfaceArea() float64 {
+	d := b.Max.Subtract(b.Min)
+	if d.X < 0 || d.Y < 0 || d.Z < 0 {
+		return 0
+	}
+	return 2.0 * (d.X*d.Y + d.Y*d.Z + d.Z*d.X)
+}
+
+// diagonal returns the distance between the corners of the box, 0 for an empty box
+func (b AABB) diagonal() float64 {
+	d := b.Max.Subtract(b.Min)
+	if d.X < 0 || d.Y < 0 || d.Z < 0 {
+		return 0
+	}
+	return d.Length()
+}
+
+// pad returns a new box that's at least delta thick along every axis,
+// so flat primitives like axis aligned triangles still get hit
+func (b AABB) pad(delta float64) AABB {
+	for axis := 0; axis < 3; axis++ {
+		if b.Max.Axis(axis)-b.Min.Axis(axis) < delta {
+			b.Min = b.Min.setAxis(axis, b.Min.Axis(axis)-delta*0.5)
+			b.Max = b.Max.setAxis(axis, b.Max.Axis(axis)+delta*0.5)
+		}
+	}
+	return b
+}
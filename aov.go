@@ -0,0 +1,180 @@
+package raytracer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AOV is an auxiliary output, a pass rendered alongside the image from what the camera rays hit first
+type AOV int
+
+// The available passes
+const (
+	// AOVNormal is the shading normal in world space, facing the camera
+	AOVNormal AOV = iota
+	// AOVAlbedo is the color of the material, without any lighting
+	AOVAlbedo
+	// AOVDepth is the distance from the camera along its view direction
+	AOVDepth
+	// AOVPosition is the position in world space
+	AOVPosition
+	// AOVObjectID numbers the objects from 1, see World.Build
+	AOVObjectID
+	// AOVMaterialID numbers the materials of the objects from 1 in the order they first appear from the top left of the image,
+	// see World.Build
+	AOVMaterialID
+	// AOVDirect is the light reaching the camera after a single bounce
+	AOVDirect
+	// AOVIndirect is the light reaching the camera after two or more bounces
+	AOVIndirect
+	// AOVEmission is the light emitted by the first hit or, for rays that miss, by the sky
+	// With AOVDirect and AOVIndirect it adds up to the image
+	AOVEmission
+)
+
+var aovNames = []string{"normal", "albedo", "depth", "position", "object-id", "material-id", "direct", "indirect", "emission"}
+
+// ParseAOV returns the pass with the name returned by its String method
+func ParseAOV(name string) (AOV, error) {
+	for i, aovName := range aovNames {
+		if name == aovName {
+			return AOV(i), nil
+		}
+	}
+	return AOVNormal, fmt.Errorf("unknown AOV %q", name)
+}
+
+// String returns the name of the pass
+func (a AOV) String() string {
+	if a < 0 || int(a) >= len(aovNames) {
+		return fmt.Sprintf("AOV(%d)", int(a))
+	}
+	return aovNames[a]
+}
+
+// SingleValued returns whether the pass has a single value per pixel, which its images repeat in every color component
+func (a AOV) SingleValued() bool {
+	return a == AOVDepth || a == AOVObjectID || a == AOVMaterialID
+}
+
+// isLighting returns whether the pass splits up the light of the image, it's filtered like the image
+func (a AOV) isLighting() bool {
+	return a == AOVDirect || a == AOVIndirect || a == AOVEmission
+}
+
+// aovSample is what a camera ray found, rays that miss everything only get emission from the sky
+type aovSample struct {
+	hit                      bool
+	normal, albedo, position Vector3
+	depth                    float64
+	objectID, materialID     int
+	// light splits up the color of the ray into emission, direct and indirect light
+	light [3]Vector3
+}
+
+// recordHit records the first hit of the camera ray
+func (a *aovSample) recordHit(h *HitRecord, c Camera) {
+	if a == nil {
+		return
+	}
+	a.hit = true
+	a.normal = h.Normal
+	a.position = h.Point
+	a.depth = c.viewDepth(h.Point)
+	a.objectID = h.objectID
+	a.materialID = h.materialID
+	if m, ok := h.Material.(albedoMaterial); ok {
+		a.albedo = m.albedo(*h)
+	}
+}
+
+// addLight adds light that reaches the camera after the number of bounces to the lighting passes
+func (a *aovSample) addLight(light Vector3, bounces int) {
+	if a == nil {
+		return
+	}
+	if bounces > 2 {
+		bounces = 2
+	}
+	a.light[bounces] = a.light[bounces].Add(light)
+}
+
+// lightingIndex returns the index of the lighting pass in aovSample.light
+func (a AOV) lightingIndex() int {
+	switch a {
+	case AOVDirect:
+		return 1
+	case AOVIndirect:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// albedoMaterial is implemented by materials with a color for AOVAlbedo, it's black for the others
+type albedoMaterial interface {
+	albedo(h HitRecord) Vector3
+}
+
+// aovBuffer sums up the passes that aren't filtered for every pixel of a renderBuffer, and holds the IDs of their first sample
+type aovBuffer struct {
+	normal, albedo, position []Vector3
+	depth                    []float64
+	objectIDs, materialIDs   []int
+}
+
+func newAOVBuffer(pixels int) *aovBuffer {
+	return &aovBuffer{
+		normal:      make([]Vector3, pixels),
+		albedo:      make([]Vector3, pixels),
+		position:    make([]Vector3, pixels),
+		depth:       make([]float64, pixels),
+		objectIDs:   make([]int, pixels),
+		materialIDs: make([]int, pixels),
+	}
+}
+
+// add adds the sample to the pixel with the index i, first is whether it's the pixel's first sample
+func (b *aovBuffer) add(i int, a *aovSample, first bool) {
+	if first {
+		b.objectIDs[i] = a.objectID
+		b.materialIDs[i] = a.materialID
+	}
+	if !a.hit {
+		return
+	}
+	b.normal[i] = b.normal[i].Add(a.normal)
+	b.albedo[i] = b.albedo[i].Add(a.albedo)
+	b.position[i] = b.position[i].Add(a.position)
+	b.depth[i] += a.depth
+}
+
+// materialIDs renumbers the material indexes of World.Build from 1 in the order they appear in the pixels, with the pixels
+// ordered by the rows of the image, and returns the ID of every pixel, 0 for pixels without a material
+func materialIDs(materials []int, width, height int) []int {
+	ids := make([]int, len(materials))
+	renumbered := map[int]int{}
+	for row := 0; row < height; row++ {
+		// the first row of the image is the top line
+		y := height - 1 - row
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if materials[i] == 0 {
+				continue
+			}
+			id, ok := renumbered[materials[i]]
+			if !ok {
+				id = len(renumbered) + 1
+				renumbered[materials[i]] = id
+			}
+			ids[i] = id
+		}
+	}
+	return ids
+}
+
+// sameMaterial returns whether a and b are equal materials, World.Build only compares those of neighboring triangles
+// Materials can hold textures with slices or maps, which == panics on, so they're compared with reflect.DeepEqual
+func sameMaterial(a, b Material) bool {
+	return a != nil && b != nil && reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,167 @@
+package raytracer
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAOVs(t *testing.T) {
+	img := NewFloatImage(1, 1)
+	img.Set(0, 0, Vector3{0.5, 0.5, 0.5})
+	red := Lambertian{Color: Vector3{0.8, 0.1, 0.1}}
+	w := World{
+		Camera: NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 40, 0, 1, 16, 16),
+		Hittables: []Hittable{
+			Sphere{Position: Vector3{0, 0, -3}, Radius: 1, Material: red},
+			Sphere{Position: Vector3{0, -101, -3}, Radius: 100, Material: Lambertian{Color: Vector3{0.5, 0.5, 0.5}}},
+		},
+		Environment: NewEnvironmentLight(img, 0, 1),
+	}
+	options := DefaultOptions()
+	options.Width, options.Height = 16, 16
+	options.SamplesPerPixel = 8
+	options.MaxBounces = 8
+	options.Filter = MitchellFilter
+	options.AOVs = []AOV{AOVNormal, AOVAlbedo, AOVDepth, AOVPosition, AOVObjectID, AOVMaterialID, AOVDirect, AOVIndirect, AOVEmission}
+	var aovs map[AOV]*FloatImage
+	options.AOVImages = func(images map[AOV]*FloatImage) { aovs = images }
+	beauty, err := RenderHDR(context.Background(), &w, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aovs) != len(options.AOVs) {
+		t.Fatalf("got %d passes, want %d", len(aovs), len(options.AOVs))
+	}
+
+	// the middle of the sphere is 2 in front of the camera, and the top corner looks at the sky
+	for _, test := range []struct {
+		aov          AOV
+		middle, sky  Vector3
+		maxDeviation float64
+	}{
+		{AOVNormal, Vector3{0, 0, 1}, Vector3{0, 0, 0}, 0.02},
+		{AOVAlbedo, red.Color, Vector3{0, 0, 0}, 1e-6},
+		{AOVDepth, Vector3{2, 2, 2}, Vector3{0, 0, 0}, 0.01},
+		{AOVPosition, Vector3{0, 0, -2}, Vector3{0, 0, 0}, 0.05},
+		{AOVObjectID, Vector3{1, 1, 1}, Vector3{0, 0, 0}, 0},
+		{AOVMaterialID, Vector3{1, 1, 1}, Vector3{0, 0, 0}, 0},
+		{AOVEmission, Vector3{0, 0, 0}, Vector3{0.5, 0.5, 0.5}, 1e-6},
+		{AOVIndirect, Vector3{0, 0, 0}, Vector3{0, 0, 0}, 0.1},
+	} {
+		// the middle of the image is between pixels 7 and 8
+		middle := aovs[test.aov].At(7, 7).Add(aovs[test.aov].At(8, 8)).Scale(0.5)
+		if middle.Subtract(test.middle).Length() > test.maxDeviation {
+			t.Errorf("%v: the middle is %v, want %v", test.aov, middle, test.middle)
+		}
+		if sky := aovs[test.aov].At(0, 0); sky.Subtract(test.sky).Length() > 1e-6 {
+			t.Errorf("%v: the sky is %v, want %v", test.aov, sky, test.sky)
+		}
+	}
+	if direct := aovs[AOVDirect].At(8, 8); direct.X < 0.1 || direct.X < 2*direct.Y {
+		t.Errorf("the sphere's direct light is %v, want red", direct)
+	}
+	// the floor is the second object, and its material the second one seen from the top of the image
+	if floor := aovs[AOVObjectID].At(0, 15); floor.X != 2 {
+		t.Errorf("the floor's object ID is %v, want 2", floor.X)
+	}
+	if floor := aovs[AOVMaterialID].At(0, 15); floor.X != 2 {
+		t.Errorf("the floor's material ID is %v, want 2", floor.X)
+	}
+
+	// the lighting passes add up to the image
+	for y := 0; y < beauty.Height; y++ {
+		for x := 0; x < beauty.Width; x++ {
+			sum := aovs[AOVEmission].At(x, y).Add(aovs[AOVDirect].At(x, y)).Add(aovs[AOVIndirect].At(x, y))
+			if got := beauty.At(x, y); sum.Subtract(got).Length() > 1e-5*math.Max(got.Length(), 1) {
+				t.Fatalf("the lighting passes of pixel %d, %d add up to %v, the image is %v", x, y, sum, got)
+			}
+		}
+	}
+
+	// the passes don't change the image
+	options.AOVs = nil
+	if without, err := RenderHDR(context.Background(), &w, options); err != nil || floatImageRMSE(without, beauty) > 1e-6 {
+		t.Error("the image rendered without passes differs")
+	}
+}
+
+func TestIdentifyObjects(t *testing.T) {
+	white, gray := Lambertian{Color: Vector3{1, 1, 1}}, Lambertian{Color: Vector3{0.5, 0.5, 0.5}}
+	light := Light{Emission: Vector3{4, 4, 4}}
+	tri := func(m Material) Triangle { return Triangle{V1: Vector3{1, 0, 0}, V2: Vector3{0, 1, 0}, Material: m} }
+	w := World{Hittables: []Hittable{
+		// two meshes, a sphere between triangles of the same material, an emissive mesh, a textured one,
+		// a sphere with an equal material and an instance
+		tri(white), tri(white), tri(gray),
+		Sphere{Radius: 1, Material: gray},
+		tri(gray),
+		tri(light), tri(light),
+		tri(Lambertian{Texture: newStripesTexture()}), tri(Lambertian{Texture: newStripesTexture()}),
+		Sphere{Radius: 1, Material: Lambertian{Color: Vector3{0.5, 0.5, 0.5}}},
+		Instance{object: tri(white)},
+	}}
+	hittables := identifyObjects(w.buildLights())
+	var ids, materials []int
+	for _, h := range hittables {
+		ids = append(ids, h.(objectHittable).id)
+		materials = append(materials, h.(objectHittable).material)
+	}
+	// every object has a material of its own, except for the instance, which has none
+	wantIDs := []int{1, 1, 2, 3, 4, 5, 5, 6, 6, 7, 8}
+	wantMaterials := []int{1, 1, 2, 3, 4, 5, 5, 6, 6, 7, 0}
+	if !reflect.DeepEqual(ids, wantIDs) || !reflect.DeepEqual(materials, wantMaterials) {
+		t.Errorf("the object IDs are %v and the material indexes %v, want %v and %v", ids, materials, wantIDs, wantMaterials)
+	}
+}
+
+// stripesTexture holds a slice, so == panics on materials with it
+type stripesTexture struct {
+	colors []Vector3
+}
+
+func newStripesTexture() stripesTexture {
+	return stripesTexture{colors: []Vector3{{1, 1, 1}, {0, 0, 0}}}
+}
+
+// Value returns the colors in stripes along u
+func (s stripesTexture) Value(u, v float64, p Vector3) Vector3 {
+	return s.colors[int(math.Abs(u)*10)%len(s.colors)]
+}
+
+// TestMaterialIDsWithUncomparableTextures renders the IDs of materials with textures that can't be compared with ==
+func TestMaterialIDsWithUncomparableTextures(t *testing.T) {
+	striped := Lambertian{Texture: newStripesTexture()}
+	plain := Principled{BaseColor: Vector3{0.5, 0.5, 0.5}, BaseColorTexture: stripesTexture{colors: []Vector3{{1, 0, 0}}}}
+	mesh := NewBVHNode([]Hittable{
+		Triangle{V0: Vector3{-1, -1, 0}, V1: Vector3{1, -1, 0}, V2: Vector3{1, 1, 0}, Material: plain},
+		Triangle{V0: Vector3{-1, -1, 0}, V1: Vector3{1, 1, 0}, V2: Vector3{-1, 1, 0}, Material: Lambertian{Texture: newStripesTexture()}},
+	})
+	instance, err := NewInstance(mesh, TranslationMatrix(Vector3{1, 0, -2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := World{
+		Camera:    NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 90, 0, 1, 16, 8),
+		Hittables: []Hittable{Sphere{Position: Vector3{-1, 0, -2}, Radius: 0.8, Material: striped}, instance},
+	}
+
+	options := DefaultOptions()
+	options.Width, options.Height = 16, 8
+	options.SamplesPerPixel = 1
+	options.AOVs = []AOV{AOVObjectID, AOVMaterialID}
+	var aovs map[AOV]*FloatImage
+	options.AOVImages = func(images map[AOV]*FloatImage) { aovs = images }
+	if _, err := RenderHDR(context.Background(), &w, options); err != nil {
+		t.Fatal(err)
+	}
+	// the sphere is on the left and the instance, which has no single material, on the right
+	for _, test := range []struct{ x, y, object, material int }{{5, 3, 1, 1}, {8, 2, 2, 0}, {11, 5, 2, 0}, {0, 0, 0, 0}} {
+		object, material := aovs[AOVObjectID].At(test.x, test.y).X, aovs[AOVMaterialID].At(test.x, test.y).X
+		if object != float64(test.object) || material != float64(test.material) {
+			t.Errorf("pixel %d, %d has the object ID %v and the material ID %v, want %d and %d",
+				test.x, test.y, object, material, test.object, test.material)
+		}
+	}
+}
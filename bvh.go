@@ -0,0 +1,158 @@
+package raytracer
+
+const sahBins = 16
+
+// BVHNode is a node in a bounding volume hierarchy, it's a Hittable itself
+// Leaf nodes hold a single Hittable in Left and nil in Right
+type BVHNode struct {
+	Box         AABB
+	Left, Right Hittable
+}
+
+// NewBVHNode builds a bounding volume hierarchy over the hittables and returns its root,
+// the split positions are chosen with the surface area heuristic
+// Returns nil if there are no hittables
+func NewBVHNode(hittables []Hittable) *BVHNode {
+	if len(hittables) == 0 {
+		return nil
+	}
+	primitives := make([]bvhPrimitive, len(hittables))
+	for i, h := range hittables {
+		box := h.BoundingBox()
+		primitives[i] = bvhPrimitive{
+			hittable: h,
+			box:      box,
+			centroid: box.Centroid(),
+		}
+	}
+	return buildBVH(primitives)
+}
+
+// Hit returns the closest hit in this node's subtree
+func (n *BVHNode) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	if !n.Box.Hit(r, tMin, tMax) {
+		return nil, false
+	}
+	leftRecord, hitLeft := n.Left.Hit(r, tMin, tMax)
+	if hitLeft {
+		tMax = leftRecord.T
+	}
+	if n.Right != nil {
+		if rightRecord, hitRight := n.Right.Hit(r, tMin, tMax); hitRight {
+			return rightRecord, true
+		}
+	}
+	return leftRecord, hitLeft
+}
+
+// BoundingBox returns the box enclosing every Hittable in this node's subtree
+func (n *BVHNode) BoundingBox() AABB {
+	return n.Box
+}
+
+type bvhPrimitive struct {
+	hittable Hittable
+	box      AABB
+	centroid Vector3
+}
+
+type sahBin struct {
+	box   AABB
+	count int
+}
+
+func buildBVH(primitives []bvhPrimitive) *BVHNode {
+	box := EmptyAABB()
+	centroidBox := EmptyAABB()
+	for _, p := range primitives {
+		box = box.Union(p.box)
+		centroidBox = centroidBox.Extend(p.centroid)
+	}
+
+	switch len(primitives) {
+	case 1:
+		return &BVHNode{Box: box, Left: primitives[0].hittable}
+	case 2:
+		return &BVHNode{Box: box, Left: primitives[0].hittable, Right: primitives[1].hittable}
+	}
+
+	mid := splitSAH(primitives, centroidBox)
+	return &BVHNode{
+		Box:   box,
+		Left:  buildBVH(primitives[:mid]),
+		Right: buildBVH(primitives[mid:]),
+	}
+}
+
+// splitSAH partitions the primitives in place and returns the index of the first primitive in the right half
+// Source: https://www.pbr-book.org/3ed-2018/Primitives_and_Intersection_Acceleration/Bounding_Volume_Hierarchies
+func splitSAH(primitives []bvhPrimitive, centroidBox AABB) int {
+	bestAxis, bestSplit := -1, 0
+	bestCost := 0.0
+	for axis := 0; axis < 3; axis++ {
+		lo := centroidBox.Min.Axis(axis)
+		extent := centroidBox.Max.Axis(axis) - lo
+		if extent <= 0 {
+			continue
+		}
+
+		var bins [sahBins]sahBin
+		for i := range bins {
+			bins[i].box = EmptyAABB()
+		}
+		for _, p := range primitives {
+			b := binIndex(p.centroid.Axis(axis), lo, extent)
+			bins[b].count++
+			bins[b].box = bins[b].box.Union(p.box)
+		}
+
+		// sweep from the right to get the cost of everything right of each split
+		var rightCosts [sahBins]float64
+		rightBox, rightCount := EmptyAABB(), 0
+		for i := sahBins - 1; i > 0; i-- {
+			rightBox = rightBox.Union(bins[i].box)
+			rightCount += bins[i].count
+			rightCosts[i] = rightBox.SurfaceArea() * float64(rightCount)
+		}
+
+		leftBox, leftCount := EmptyAABB(), 0
+		for split := 1; split < sahBins; split++ {
+			leftBox = leftBox.Union(bins[split-1].box)
+			leftCount += bins[split-1].count
+			if leftCount == 0 || leftCount == len(primitives) {
+				continue
+			}
+			cost := leftBox.SurfaceArea()*float64(leftCount) + rightCosts[split]
+			if bestAxis < 0 || cost < bestCost {
+				bestAxis, bestSplit, bestCost = axis, split, cost
+			}
+		}
+	}
+
+	if bestAxis < 0 {
+		// all centroids are in the same spot, any split is as good as the other
+		return len(primitives) / 2
+	}
+
+	lo := centroidBox.Min.Axis(bestAxis)
+	extent := centroidBox.Max.Axis(bestAxis) - lo
+	mid := 0
+	for i, p := range primitives {
+		if binIndex(p.centroid.Axis(bestAxis), lo, extent) < bestSplit {
+			primitives[i], primitives[mid] = primitives[mid], primitives[i]
+			mid++
+		}
+	}
+	return mid
+}
+
+func binIndex(value, lo, extent float64) int {
+	b := int(sahBins * (value - lo) / extent)
+	if b >= sahBins {
+		return sahBins - 1
+	}
+	if b < 0 {
+		return 0
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBVHMatchesLinearSearch(t *testing.T) {
+	sampler := newIndependentSampler(1)
+	randomPoint := func() Vector3 {
+		return Vector3{sampler.Get1D()*4 - 2, sampler.Get1D()*4 - 2, sampler.Get1D()*4 - 2}
+	}
+
+	var hittables []Hittable
+	for i := 0; i < 200; i++ {
+		center := randomPoint()
+		if i%2 == 0 {
+			hittables = append(hittables, Sphere{Position: center, Radius: sampler.Get1D() * 0.2})
+			continue
+		}
+		normal := RandomOnUnitSphere(sampler)
+		hittables = append(hittables, Triangle{
+			V0: center,
+			V1: center.Add(RandomInUnitSphere(sampler).Scale(0.3)),
+			V2: center.Add(RandomInUnitSphere(sampler).Scale(0.3)),
+			N0: normal, N1: normal, N2: normal,
+		})
+	}
+
+	linear := World{Hittables: hittables}
+	accelerated := World{Hittables: hittables}
+	accelerated.Build()
+
+	for i := 0; i < 5000; i++ {
+		r := Ray{Origin: randomPoint().Scale(2), Direction: RandomOnUnitSphere(sampler)}
+		want, wantHit := linear.Hit(r, 0.001, math.Inf(1))
+		got, gotHit := accelerated.Hit(r, 0.001, math.Inf(1))
+		if wantHit != gotHit {
+			t.Fatalf("ray %v: linear hit %v, bvh hit %v", r, wantHit, gotHit)
+		}
+		if wantHit && math.Abs(want.T-got.T) > 1e-9 {
+			t.Fatalf("ray %v: linear t %v, bvh t %v", r, want.T, got.T)
+		}
+	}
+}
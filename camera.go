@@ -1,8 +1,7 @@
-package main
+package raytracer
 
 import (
 	"math"
-	"math/rand"
 )
 
 // Camera is a 3d camera
@@ -48,8 +47,8 @@ func NewCamera(position, lookAt, up Vector3, verticalFov, aperture, focusDistanc
 }
 
 // GetRay returns a ray going from the camera's position into the scene based on the given u and v
-func (c Camera) GetRay(s, t float64, rnd *rand.Rand) Ray {
-	random := RandomOnUnitDisk(rnd).Scale(c.lensRadius)
+func (c Camera) GetRay(s, t float64, sampler Sampler) Ray {
+	random := RandomOnUnitDisk(sampler).Scale(c.lensRadius)
 	offset := c.u.Scale(random.X).Add(c.v.Scale(random.Y))
 	return Ray{
 		Origin: c.position.Add(offset),
@@ -60,3 +59,8 @@ func (c Camera) GetRay(s, t float64, rnd *rand.Rand) Ray {
 			Subtract(offset),
 	}
 }
+
+// viewDepth returns the distance of the point from the camera along the direction it's looking in
+func (c Camera) viewDepth(p Vector3) float64 {
+	return c.position.Subtract(p).Dot(c.w)
+}
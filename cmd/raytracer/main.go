@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"okacat.com/raytracer"
+)
+
+func main() {
+	defaults := raytracer.DefaultOptions()
+	width := flag.Int("width", defaults.Width, "width of the image in pixels")
+	height := flag.Int("height", defaults.Height, "height of the image in pixels")
+	samples := flag.Int("samples", defaults.SamplesPerPixel, "samples per pixel, the most a pixel gets with -adaptive-threshold")
+	adaptiveThreshold := flag.Float64("adaptive-threshold", defaults.AdaptiveThreshold, "stop sampling pixels whose relative error is below this, 0 samples every pixel -samples times")
+	minSamples := flag.Int("min-samples", defaults.MinSamples, "samples every pixel gets before -adaptive-threshold can stop it")
+	heatmap := flag.String("heatmap", "", "output file for an image of the samples taken per pixel, from blue for none to red for -samples")
+	sampler := flag.String("sampler", defaults.Sampler.String(), "sampler of the random numbers: independent, stratified, halton or sobol")
+	filter := flag.String("filter", defaults.Filter.String(), "reconstruction filter weighing samples into the pixels around them: box, tent, gaussian, mitchell or lanczos")
+	filterRadius := flag.Float64("filter-radius", defaults.FilterRadius, "radius of the -filter in pixels, 0 picks the filter's default")
+	aovNames := flag.String("aovs", "", "comma separated passes to render alongside the image: normal, albedo, depth, position, object-id, material-id, direct, indirect or emission, written as layers of an .exr -out or as files next to it")
+	bounces := flag.Int("bounces", defaults.MaxBounces, "maximum number of bounces per ray")
+	lightSampling := flag.Bool("light-sampling", defaults.LightSampling, "sample lights directly at every diffuse bounce")
+	threads := flag.Int("threads", defaults.Threads, "number of render threads")
+	tileSize := flag.Int("tile-size", defaults.TileSize, "side of the square tiles the threads render, in pixels")
+	progressive := flag.Bool("progressive", defaults.Progressive, "render the whole image one sample per pixel at a time")
+	checkpoint := flag.Int("checkpoint", 0, "write the image to -out every this many -progressive passes, 0 only writes it at the end")
+	scene := flag.String("scene", "cornell-box", "built-in scene to render (see -list-scenes) or path to a .json, .gltf or .glb scene file")
+	out := flag.String("out", "", "output file, .png, .hdr, .pfm or .exr, defaults to output/render<timestamp>.png")
+	toneMapOperator := flag.String("tonemap", defaults.ToneMapping.Operator.String(), "tone mapping of .png output: clamp, reinhard, reinhard-extended, aces or hable")
+	exposure := flag.Float64("exposure", defaults.ToneMapping.Exposure, "exposure adjustment of .png output in stops")
+	whitePoint := flag.Float64("white-point", defaults.ToneMapping.WhitePoint, "radiance mapped to white by reinhard-extended and hable, 0 picks the operator's default")
+	exrCompression := flag.String("exr-compression", "zip", "compression of .exr output, zip or none")
+	environment := flag.String("environment", "", "equirectangular .hdr or .pfm image lighting the scene, replacing its sky")
+	environmentRotation := flag.Float64("environment-rotation", 0, "rotation of the -environment image around the up axis in degrees")
+	environmentIntensity := flag.Float64("environment-intensity", 1, "scale of the -environment image's light")
+	sunSky := flag.Bool("sun-sky", false, "light the scene with a sunny sky, replacing its sky")
+	sunElevation := flag.Float64("sun-elevation", 45, "elevation of the -sun-sky sun above the horizon in degrees")
+	sunAzimuth := flag.Float64("sun-azimuth", 0, "azimuth of the -sun-sky sun in degrees, from -Z towards +X")
+	turbidity := flag.Float64("turbidity", 3, "haziness of the -sun-sky air, from 2 for a clear to 10 for a hazy sky")
+	seed := flag.Int64("seed", defaults.Seed, "random seed, the same seed renders the same image")
+	listScenes := flag.Bool("list-scenes", false, "list the built-in scenes and exit")
+	flag.Parse()
+
+	if *listScenes {
+		for _, name := range raytracer.TestWorldNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	operator, err := raytracer.ParseToneMapOperator(*toneMapOperator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	samplerType, err := raytracer.ParseSamplerType(*sampler)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filterType, err := raytracer.ParseFilterType(*filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var aovs []raytracer.AOV
+	if *aovNames != "" {
+		for _, name := range strings.Split(*aovNames, ",") {
+			aov, err := raytracer.ParseAOV(strings.TrimSpace(name))
+			if err != nil {
+				log.Fatal(err)
+			}
+			aovs = append(aovs, aov)
+		}
+	}
+
+	options := raytracer.Options{
+		Width:             *width,
+		Height:            *height,
+		SamplesPerPixel:   *samples,
+		AdaptiveThreshold: *adaptiveThreshold,
+		MinSamples:        *minSamples,
+		MaxBounces:        *bounces,
+		LightSampling:     *lightSampling,
+		Threads:           *threads,
+		TileSize:          *tileSize,
+		Progressive:       *progressive,
+		Sampler:           samplerType,
+		Filter:            filterType,
+		FilterRadius:      *filterRadius,
+		AOVs:              aovs,
+		Seed:              *seed,
+		ToneMapping: raytracer.ToneMapping{
+			Operator:   operator,
+			Exposure:   *exposure,
+			WhitePoint: *whitePoint,
+		},
+		Progress: newProgressPrinter(),
+	}
+	if *out == "" {
+		*out = filepath.Join("output", fmt.Sprintf("render%v.png", time.Now().Unix()))
+	}
+	// fail before rendering rather than after
+	for _, filename := range []string{*out, *heatmap} {
+		if filename == "" {
+			continue
+		}
+		if err := checkOutputFormat(filename); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *heatmap != "" {
+		options.Heatmap = func(img *raytracer.FloatImage) {
+			if err := saveImageAs(img, *heatmap, raytracer.ToneMapping{}, *exrCompression, nil); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	var aovImages map[raytracer.AOV]*raytracer.FloatImage
+	options.AOVImages = func(images map[raytracer.AOV]*raytracer.FloatImage) {
+		aovImages = images
+	}
+	if *checkpoint > 0 {
+		options.CheckpointInterval = *checkpoint
+		options.Checkpoint = func(img *raytracer.FloatImage, passes int) {
+			if err := saveImageAs(img, *out, options.ToneMapping, *exrCompression, nil); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("wrote %v after %v/%v passes\n", *out, passes, options.SamplesPerPixel)
+		}
+	}
+	fmt.Printf("number of available CPUs: %v, spawning %v threads\n", runtime.NumCPU(), options.Threads)
+
+	world, err := loadWorld(*scene, options.Width, options.Height)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *environment != "" {
+		if world.Environment, err = raytracer.ReadEnvironmentLight(*environment, *environmentRotation, *environmentIntensity); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *sunSky {
+		world.Environment = raytracer.NewSunSky(*sunElevation, *sunAzimuth, *turbidity, 1)
+	}
+
+	// the first interrupt stops the render and writes what's done so far, the second one exits right away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		fmt.Println("interrupted, finishing the tiles being rendered")
+		cancel()
+	}()
+
+	startTime := time.Now()
+
+	img, err := raytracer.RenderHDR(ctx, &world, options)
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("the render was interrupted, writing the partial image")
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("render took ", time.Since(startTime).Round(time.Millisecond))
+	if err := saveImageAs(img, *out, options.ToneMapping, *exrCompression, aovImages); err != nil {
+		log.Fatal(err)
+	}
+	if strings.ToLower(filepath.Ext(*out)) != ".exr" {
+		// other formats have a single layer, every pass gets a file of its own
+		for aov, aovImage := range aovImages {
+			if err := saveImageAs(aovImage, aovFileName(*out, aov), raytracer.ToneMapping{}, *exrCompression, nil); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// aovFileName returns the name of the file of a pass written next to the image, like render.normal.png for render.png
+func aovFileName(out string, aov raytracer.AOV) string {
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "." + aov.String() + ext
+}
+
+// loadWorld loads a scene file if scene is a .json, .gltf or .glb path, the built-in test world named scene otherwise
+func loadWorld(scene string, width, height int) (raytracer.World, error) {
+	switch strings.ToLower(filepath.Ext(scene)) {
+	case ".json":
+		return raytracer.LoadScene(scene, width, height)
+	case ".gltf", ".glb":
+		return raytracer.LoadGLTF(scene, width, height)
+	}
+	return raytracer.TestWorld(scene, width, height)
+}
+
+// newProgressPrinter returns a Progress callback that prints the rendered tiles whenever another percent is done
+func newProgressPrinter() func(tilesCompleted, totalTiles int) {
+	lastPercent := -1.0
+	return func(tilesCompleted, totalTiles int) {
+		percent := math.Floor(100 * float64(tilesCompleted) / float64(totalTiles))
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+		fmt.Printf("rendered %v/%v tiles [%v%%]\n", tilesCompleted, totalTiles, percent)
+	}
+}
+
+// saveImageAs writes the image in the format matching the file's extension, tone mapped if it's a .png
+// The passes are written as layers of .exr files and ignored for the other formats
+func saveImageAs(img *raytracer.FloatImage, filename string, toneMapping raytracer.ToneMapping, exrCompression string,
+	aovs map[raytracer.AOV]*raytracer.FloatImage) error {
+	var compression raytracer.EXRCompression
+	switch exrCompression {
+	case "zip":
+		compression = raytracer.EXRZIPCompression
+	case "none":
+		compression = raytracer.EXRNoCompression
+	default:
+		return fmt.Errorf("unknown EXR compression %q", exrCompression)
+	}
+
+	if err := checkOutputFormat(filename); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0775); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		err = png.Encode(f, img.ToRGBA(toneMapping))
+	case ".hdr":
+		err = raytracer.WriteRadianceHDR(f, img)
+	case ".pfm":
+		err = raytracer.WritePFM(f, img)
+	case ".exr":
+		if len(aovs) > 0 {
+			err = raytracer.WriteEXRLayers(f, img, aovs, compression)
+		} else {
+			err = raytracer.WriteEXR(f, img, compression)
+		}
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkOutputFormat returns an error if saveImageAs can't write a file with the extension of filename
+func checkOutputFormat(filename string) error {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".png", ".hdr", ".pfm", ".exr":
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q of %v, use .png, .hdr, .pfm or .exr", ext, filename)
+	}
+}
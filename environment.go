@@ -0,0 +1,244 @@
+package raytracer
+
+import (
+	"math"
+	"sort"
+)
+
+// EnvironmentLight lights the world from every direction with an equirectangular image, for rays that
+// don't hit anything. The middle of the image is seen looking down -Z, with its top row straight up
+// Directions are importance sampled by the luminance of the pixels
+type EnvironmentLight struct {
+	image     *FloatImage
+	intensity float64
+	// the sine and cosine of the rotation around Y
+	sinRotation, cosRotation float64
+	distribution             distribution2D
+	averageLuminance         float64
+	// sun is a disk sampled on its own, its radiance is 0 without one
+	sun sunDisk
+}
+
+// sunDisk is a small, bright disk of directions in the sky
+type sunDisk struct {
+	direction Vector3
+	// cosAngle is the cosine of the disk's angular radius
+	cosAngle float64
+	radiance Vector3
+	// prob is the probability of sampling the sun rather than the image
+	prob float64
+}
+
+// covers returns whether the unit direction points into the disk
+func (s sunDisk) covers(direction Vector3) bool {
+	return !s.radiance.IsNearZero() && direction.Dot(s.direction) >= s.cosAngle
+}
+
+// solidAngle returns the solid angle the disk covers
+func (s sunDisk) solidAngle() float64 {
+	return 2.0 * math.Pi * (1.0 - s.cosAngle)
+}
+
+// NewEnvironmentLight creates an environment light from an equirectangular image turned by rotation degrees
+// around Y and scaled by intensity
+func NewEnvironmentLight(img *FloatImage, rotation, intensity float64) *EnvironmentLight {
+	radians := rotation * math.Pi / 180.0
+	e := &EnvironmentLight{
+		image:       img,
+		intensity:   intensity,
+		sinRotation: math.Sin(radians),
+		cosRotation: math.Cos(radians),
+	}
+
+	// pixels near the poles cover a smaller solid angle and are picked less often
+	weights := make([][]float64, img.Height)
+	totalWeight, totalSine := 0.0, 0.0
+	for y := range weights {
+		sinTheta := math.Sin(math.Pi * (float64(y) + 0.5) / float64(img.Height))
+		weights[y] = make([]float64, img.Width)
+		for x := range weights[y] {
+			weights[y][x] = img.At(x, y).Luminance() * intensity * sinTheta
+			totalWeight += weights[y][x]
+		}
+		totalSine += sinTheta * float64(img.Width)
+	}
+	e.distribution = newDistribution2D(weights)
+	if totalSine > 0 {
+		e.averageLuminance = totalWeight / totalSine
+	}
+	return e
+}
+
+// ReadEnvironmentLight reads an equirectangular .hdr or .pfm image into an environment light
+func ReadEnvironmentLight(filePath string, rotation, intensity float64) (*EnvironmentLight, error) {
+	img, err := ReadFloatImage(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvironmentLight(img, rotation, intensity), nil
+}
+
+// Radiance returns the light arriving from direction
+func (e *EnvironmentLight) Radiance(direction Vector3) Vector3 {
+	u, v := e.toImage(direction)
+	x := Clamp(math.Floor(u*float64(e.image.Width)), 0, float64(e.image.Width-1))
+	y := Clamp(math.Floor(v*float64(e.image.Height)), 0, float64(e.image.Height-1))
+	radiance := e.image.At(int(x), int(y)).Scale(e.intensity)
+	if e.sun.covers(direction.Unit()) {
+		radiance = radiance.Add(e.sun.radiance)
+	}
+	return radiance
+}
+
+// sample returns a unit direction picked proportionally to the light arriving from it,
+// and the pdf of picking it per unit solid angle
+func (e *EnvironmentLight) sample(sampler Sampler) (Vector3, float64) {
+	pickSun := sampler.Get1D() < e.sun.prob
+	u1, u2 := sampler.Get2D()
+	if pickSun {
+		// uniformly in the cone of the sun
+		cosTheta := 1.0 - u1*(1.0-e.sun.cosAngle)
+		sinTheta := math.Sqrt(math.Max(0, 1.0-cosTheta*cosTheta))
+		phi := 2.0 * math.Pi * u2
+		u, v := orthonormalBasis(e.sun.direction)
+		direction := u.Scale(math.Cos(phi) * sinTheta).
+			Add(v.Scale(math.Sin(phi) * sinTheta)).
+			Add(e.sun.direction.Scale(cosTheta)).
+			Unit()
+		return direction, e.pdf(direction)
+	}
+	u, v, _ := e.distribution.sample(u1, u2)
+	direction := e.fromImage(u, v)
+	return direction, e.pdf(direction)
+}
+
+// pdf returns the pdf of sample picking direction
+func (e *EnvironmentLight) pdf(direction Vector3) float64 {
+	u, v := e.toImage(direction)
+	sinTheta := math.Sin(math.Pi * v)
+	pdf := 0.0
+	if sinTheta > 0 {
+		pdf = (1.0 - e.sun.prob) * e.distribution.pdf(u, v) / (2.0 * math.Pi * math.Pi * sinTheta)
+	}
+	if e.sun.prob > 0 && e.sun.covers(direction.Unit()) {
+		pdf += e.sun.prob / e.sun.solidAngle()
+	}
+	return pdf
+}
+
+// power returns the power of the environment falling on a disk of worldRadius
+func (e *EnvironmentLight) power(worldRadius float64) float64 {
+	averageLuminance := e.averageLuminance + e.sun.radiance.Luminance()*e.sun.solidAngle()/(4.0*math.Pi)
+	return math.Pi * worldRadius * worldRadius * averageLuminance
+}
+
+// toImage returns the image coordinates in [0..1] of a direction, v goes down from the top
+func (e *EnvironmentLight) toImage(direction Vector3) (float64, float64) {
+	d := direction.Unit()
+	// turn the direction back by the rotation
+	x := d.X*e.cosRotation - d.Z*e.sinRotation
+	z := d.X*e.sinRotation + d.Z*e.cosRotation
+	u := 0.5 + math.Atan2(x, -z)/(2.0*math.Pi)
+	v := math.Acos(Clamp(d.Y, -1, 1)) / math.Pi
+	return u - math.Floor(u), v
+}
+
+// fromImage returns the unit direction of the image coordinates u, v
+func (e *EnvironmentLight) fromImage(u, v float64) Vector3 {
+	phi := 2.0 * math.Pi * (u - 0.5)
+	theta := math.Pi * v
+	x, y, z := math.Sin(theta)*math.Sin(phi), math.Cos(theta), -math.Sin(theta)*math.Cos(phi)
+	return Vector3{x*e.cosRotation + z*e.sinRotation, y, -x*e.sinRotation + z*e.cosRotation}
+}
+
+// distribution1D is a piecewise constant distribution over [0..1] with a step per value
+// Source: https://www.pbr-book.org/3ed-2018/Monte_Carlo_Integration/Sampling_Random_Variables
+type distribution1D struct {
+	values []float64
+	// cdf has one more entry than values, from 0 to 1
+	cdf []float64
+	// integral is the average of the values
+	integral float64
+}
+
+// newDistribution1D creates a distribution proportional to the values, which must not be negative
+// If they're all 0, every step is equally likely
+func newDistribution1D(values []float64) distribution1D {
+	n := len(values)
+	d := distribution1D{values: values, cdf: make([]float64, n+1)}
+	for i, value := range values {
+		d.cdf[i+1] = d.cdf[i] + value/float64(n)
+	}
+	d.integral = d.cdf[n]
+	for i := 1; i <= n; i++ {
+		if d.integral > 0 {
+			d.cdf[i] /= d.integral
+		} else {
+			d.cdf[i] = float64(i) / float64(n)
+		}
+	}
+	return d
+}
+
+// sample returns the point in [0..1) u maps to, its pdf and the index of its step
+func (d distribution1D) sample(u float64) (float64, float64, int) {
+	n := len(d.values)
+	index := sort.SearchFloat64s(d.cdf, u) - 1
+	if index < 0 {
+		index = 0
+	}
+	for index < n-1 && d.cdf[index+1] <= u {
+		index++
+	}
+	width := d.cdf[index+1] - d.cdf[index]
+	offset := 0.0
+	if width > 0 {
+		offset = (u - d.cdf[index]) / width
+	}
+	x := math.Min((float64(index)+offset)/float64(n), math.Nextafter(1, 0))
+	return x, d.pdf(index), index
+}
+
+// pdf returns the density of the step at index
+func (d distribution1D) pdf(index int) float64 {
+	if d.integral > 0 {
+		return d.values[index] / d.integral
+	}
+	return 1
+}
+
+// index returns the step of a point in [0..1]
+func (d distribution1D) index(x float64) int {
+	return int(Clamp(math.Floor(x*float64(len(d.values))), 0, float64(len(d.values)-1)))
+}
+
+// distribution2D is a piecewise constant distribution over [0..1]², picking a row first and then a column in it
+type distribution2D struct {
+	rows     []distribution1D
+	marginal distribution1D
+}
+
+// newDistribution2D creates a distribution proportional to the values, given row by row
+func newDistribution2D(values [][]float64) distribution2D {
+	d := distribution2D{rows: make([]distribution1D, len(values))}
+	integrals := make([]float64, len(values))
+	for y, row := range values {
+		d.rows[y] = newDistribution1D(row)
+		integrals[y] = d.rows[y].integral
+	}
+	d.marginal = newDistribution1D(integrals)
+	return d
+}
+
+// sample returns the point u1 and u2 map to, as a column and a row coordinate, and its pdf
+func (d distribution2D) sample(u1, u2 float64) (float64, float64, float64) {
+	v, rowPDF, y := d.marginal.sample(u2)
+	u, columnPDF, _ := d.rows[y].sample(u1)
+	return u, v, rowPDF * columnPDF
+}
+
+// pdf returns the density of the point at column coordinate u and row coordinate v
+func (d distribution2D) pdf(u, v float64) float64 {
+	y := d.marginal.index(v)
+	return d.marginal.pdf(y) * d.rows[y].pdf(d.rows[y].index(u))
+}
@@ -0,0 +1,88 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+// newTestEnvironmentImage returns a dim equirectangular image with a bright patch above the horizon
+func newTestEnvironmentImage() *FloatImage {
+	img := NewFloatImage(32, 16)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			img.Set(x, y, Vector3{0.2, 0.3, 0.4})
+			if x >= 20 && x < 24 && y >= 3 && y < 6 {
+				img.Set(x, y, Vector3{40, 35, 30})
+			}
+		}
+	}
+	return img
+}
+
+func TestEnvironmentSampleMatchesPDF(t *testing.T) {
+	e := NewEnvironmentLight(newTestEnvironmentImage(), 30, 2)
+	sampler := newIndependentSampler(1)
+	for i := 0; i < 1000; i++ {
+		direction, pdf := e.sample(sampler)
+		if pdf <= 0 {
+			continue
+		}
+		if got := e.pdf(direction); math.Abs(got-pdf) > 1e-6*pdf {
+			t.Fatalf("sample picked %v with pdf %v, pdf gives %v", direction, pdf, got)
+		}
+		if u, v := e.toImage(direction); e.fromImage(u, v).Subtract(direction).Length() > 1e-9 {
+			t.Fatalf("%v maps to %v %v, which maps back to %v", direction, u, v, e.fromImage(u, v))
+		}
+	}
+
+	// The pdf integrates to 1 over the sphere, summed over a grid of directions
+	const thetaSteps, phiSteps = 400, 800
+	integral := 0.0
+	for i := 0; i < thetaSteps; i++ {
+		theta := (float64(i) + 0.5) * math.Pi / thetaSteps
+		for j := 0; j < phiSteps; j++ {
+			phi := (float64(j) + 0.5) * 2 * math.Pi / phiSteps
+			direction := Vector3{math.Sin(theta) * math.Cos(phi), math.Cos(theta), math.Sin(theta) * math.Sin(phi)}
+			integral += e.pdf(direction) * math.Sin(theta) * (math.Pi / thetaSteps) * (2 * math.Pi / phiSteps)
+		}
+	}
+	if math.Abs(integral-1) > 0.01 {
+		t.Errorf("pdf integrates to %v, want 1", integral)
+	}
+}
+
+func TestEnvironmentRotation(t *testing.T) {
+	img := NewFloatImage(4, 2)
+	img.Set(2, 0, Vector3{1, 0, 0}) // the middle of the image, looking down -Z and a bit up
+	e := NewEnvironmentLight(img, 90, 1)
+	// turned 90 degrees around Y, -Z turns to -X
+	if got := e.Radiance(Vector3{-1, 0.5, -0.3}); got != (Vector3{1, 0, 0}) {
+		t.Errorf("radiance towards -X is %v, want the red pixel turned there", got)
+	}
+	if got := e.Radiance(Vector3{0, 0.5, -1}); got != (Vector3{0, 0, 0}) {
+		t.Errorf("radiance towards -Z is %v, want black", got)
+	}
+}
+
+func TestEnvironmentSamplingConvergesToSameImage(t *testing.T) {
+	world := newLightTestWorld()
+	world.Hittables = world.Hittables[:2]
+	world.Environment = NewEnvironmentLight(newTestEnvironmentImage(), 0, 1)
+	world.Build()
+	options := Options{MaxBounces: 4}
+	const samples = 400000
+
+	want, bsdfVariance := meanRadiance(&world, &options, samples)
+	options.LightSampling = true
+	got, lightVariance := meanRadiance(&world, &options, samples)
+
+	for axis := 0; axis < 3; axis++ {
+		if relative := math.Abs(got.Axis(axis)-want.Axis(axis)) / want.Axis(axis); relative > 0.03 {
+			t.Errorf("mean radiance with environment sampling is %v, without %v", got, want)
+			break
+		}
+	}
+	if lightVariance >= bsdfVariance {
+		t.Errorf("environment sampling variance %v isn't lower than BSDF sampling variance %v", lightVariance, bsdfVariance)
+	}
+}
@@ -0,0 +1,130 @@
+package raytracer
+
+import (
+	"fmt"
+	"math"
+)
+
+// FilterType picks the reconstruction filter that weighs the samples into the pixels around them
+type FilterType int
+
+// The available reconstruction filters
+const (
+	// BoxFilter weighs every sample within the radius the same, with the default radius of 0.5 a sample only counts for its own pixel
+	BoxFilter FilterType = iota
+	// TentFilter weighs samples linearly less the further they are, down to 0 at the radius
+	TentFilter
+	// GaussianFilter weighs samples by a Gaussian with a standard deviation of a third of the radius, shifted down to 0 at the radius
+	GaussianFilter
+	// MitchellFilter is the Mitchell-Netravali cubic with B = C = 1/3, which sharpens with small negative lobes
+	MitchellFilter
+	// LanczosFilter is the sinc function windowed by a sinc stretched to the radius, sharp with ringing around edges
+	LanczosFilter
+)
+
+var filterTypeNames = []string{"box", "tent", "gaussian", "mitchell", "lanczos"}
+
+// ParseFilterType returns the filter type with the name returned by its String method
+func ParseFilterType(name string) (FilterType, error) {
+	for i, filterName := range filterTypeNames {
+		if name == filterName {
+			return FilterType(i), nil
+		}
+	}
+	return BoxFilter, fmt.Errorf("unknown filter %q", name)
+}
+
+// String returns the name of the filter type
+func (t FilterType) String() string {
+	if t < 0 || int(t) >= len(filterTypeNames) {
+		return fmt.Sprintf("FilterType(%d)", int(t))
+	}
+	return filterTypeNames[t]
+}
+
+// DefaultRadius returns the radius in pixels the filter type is used with when no other is given
+func (t FilterType) DefaultRadius() float64 {
+	switch t {
+	case TentFilter:
+		return 1
+	case GaussianFilter:
+		return 1.5
+	case MitchellFilter:
+		return 2
+	case LanczosFilter:
+		return 3
+	default:
+		return 0.5
+	}
+}
+
+// filter weighs a sample into a pixel by the offset between them, in pixels along x and y
+type filter struct {
+	filterType FilterType
+	radius     float64
+}
+
+// newFilter returns a filter of the type, a radius <= 0 uses the type's default radius
+func newFilter(t FilterType, radius float64) filter {
+	if radius <= 0 {
+		radius = t.DefaultRadius()
+	}
+	return filter{filterType: t, radius: radius}
+}
+
+// weight returns the weight of a sample dx, dy pixels away, the filters are separable
+func (f filter) weight(dx, dy float64) float64 {
+	return f.weight1D(dx) * f.weight1D(dy)
+}
+
+func (f filter) weight1D(d float64) float64 {
+	d = math.Abs(d)
+	if d > f.radius {
+		return 0
+	}
+	switch f.filterType {
+	case TentFilter:
+		return f.radius - d
+	case GaussianFilter:
+		sigma := f.radius / 3.0
+		return math.Max(gaussian(d, sigma)-gaussian(f.radius, sigma), 0)
+	case MitchellFilter:
+		return mitchell1D(2.0*d/f.radius, 1.0/3.0, 1.0/3.0)
+	case LanczosFilter:
+		return sinc(d) * sinc(d/f.radius)
+	default:
+		return 1
+	}
+}
+
+// margin returns how many pixels past its own pixel a sample reaches
+func (f filter) margin() int {
+	return int(math.Ceil(f.radius - 0.5))
+}
+
+// pixelRange returns the first and the last pixel along an axis whose centers are within the radius of the position p,
+// a pixel whose center is just at the radius below p isn't, so a box filter of radius 0.5 only counts a sample for its own pixel
+func (f filter) pixelRange(p float64) (int, int) {
+	return int(math.Floor(p-f.radius-0.5)) + 1, int(math.Floor(p + f.radius - 0.5))
+}
+
+func gaussian(x, sigma float64) float64 {
+	return math.Exp(-x * x / (2.0 * sigma * sigma))
+}
+
+// mitchell1D returns the Mitchell-Netravali cubic at x in [0..2]
+// Source: https://www.pbr-book.org/3ed-2018/Sampling_and_Reconstruction/Image_Reconstruction
+func mitchell1D(x, b, c float64) float64 {
+	if x > 1 {
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6.0
+	}
+	return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6.0
+}
+
+// sinc returns sin(pi x) / (pi x)
+func sinc(x float64) float64 {
+	if math.Abs(x) < 1e-5 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
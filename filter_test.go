@@ -0,0 +1,133 @@
+package raytracer
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var allFilterTypes = []FilterType{BoxFilter, TentFilter, GaussianFilter, MitchellFilter, LanczosFilter}
+
+func TestFilterWeights(t *testing.T) {
+	for _, filterType := range allFilterTypes {
+		for _, radius := range []float64{0, 0.8, 2.5} {
+			f := newFilter(filterType, radius)
+			if radius == 0 && f.radius != filterType.DefaultRadius() {
+				t.Errorf("%v: the radius is %v, want the default %v", filterType, f.radius, filterType.DefaultRadius())
+			}
+			center := f.weight(0, 0)
+			if center <= 0 {
+				t.Errorf("%v with radius %v: the weight in the middle is %v", filterType, f.radius, center)
+			}
+			for _, d := range []float64{0.1, 0.3, 0.7, 1.2, 2.2, 3.1} {
+				w := f.weight(d, 0.2)
+				if w > center {
+					t.Errorf("%v with radius %v: the weight at %v is %v, more than in the middle", filterType, f.radius, d, w)
+				}
+				if w != f.weight(-d, -0.2) || w != f.weight(0.2, d) {
+					t.Errorf("%v with radius %v: the weights around %v aren't symmetric", filterType, f.radius, d)
+				}
+				if d > f.radius && w != 0 {
+					t.Errorf("%v with radius %v: the weight at %v past the radius is %v", filterType, f.radius, d, w)
+				}
+			}
+			// the pixels a sample reaches are within the margin of its own
+			for _, p := range []float64{3, 3.25, 3.5, 3.999} {
+				first, last := f.pixelRange(p)
+				if first < 3-f.margin() || last > 3+f.margin() || first > 3 || last < 3 {
+					t.Errorf("%v with radius %v: a sample at %v reaches pixels %d to %d, more than %d from pixel 3",
+						filterType, f.radius, p, first, last, f.margin())
+				}
+			}
+		}
+	}
+
+	// a box of radius 0.5 only counts a sample for its own pixel
+	box := newFilter(BoxFilter, 0)
+	for _, p := range []float64{3, 3.5, 3.999} {
+		if first, last := box.pixelRange(p); first != 3 || last != 3 {
+			t.Errorf("box: a sample at %v reaches pixels %d to %d", p, first, last)
+		}
+	}
+}
+
+// TestFiltersKeepFlatImagesFlat renders a uniform environment, which every filter develops into the same color up to the
+// edges of the image, and which is the same with any number of threads
+func TestFiltersKeepFlatImagesFlat(t *testing.T) {
+	img := NewFloatImage(1, 1)
+	img.Set(0, 0, Vector3{0.2, 0.5, 1})
+	w := World{
+		Camera:      NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 60, 0, 1, 20, 12),
+		Environment: NewEnvironmentLight(img, 0, 1),
+	}
+	options := DefaultOptions()
+	options.Width, options.Height = 20, 12
+	options.SamplesPerPixel = 4
+	options.TileSize = 8
+
+	for _, filterType := range allFilterTypes {
+		options.Filter = filterType
+		options.Threads = 1
+		got, err := RenderHDR(context.Background(), &w, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < got.Height; y++ {
+			for x := 0; x < got.Width; x++ {
+				if c := got.At(x, y); c.Subtract(Vector3{0.2, 0.5, 1}).Length() > 1e-5 {
+					t.Fatalf("%v: pixel %d, %d is %v", filterType, x, y, c)
+				}
+			}
+		}
+		options.Threads = 3
+		if threaded, err := RenderHDR(context.Background(), &w, options); err != nil || !reflect.DeepEqual(threaded, got) {
+			t.Errorf("%v: rendering with 3 threads differs from rendering with 1 thread", filterType)
+		}
+	}
+}
+
+// TestFiltersSmoothEdges renders a white upper and a black lower half, the box keeps the edge sharp and wider filters
+// spread it into the rows around it
+func TestFiltersSmoothEdges(t *testing.T) {
+	img := NewFloatImage(1, 2)
+	img.Set(0, 0, Vector3{1, 1, 1})
+	w := World{
+		Camera:      NewCamera(Vector3{0, 0, 0}, Vector3{0, 0, -1}, Vector3{0, 1, 0}, 90, 0, 1, 4, 8),
+		Environment: NewEnvironmentLight(img, 0, 1),
+	}
+	options := DefaultOptions()
+	options.Width, options.Height = 4, 8
+	options.SamplesPerPixel = 16
+
+	for _, test := range []struct {
+		filterType FilterType
+		// the brightness of the rows from the top
+		want [8]float64
+	}{
+		{BoxFilter, [8]float64{1, 1, 1, 1, 0, 0, 0, 0}},
+		{TentFilter, [8]float64{1, 1, 1, 0.875, 0.125, 0, 0, 0}},
+	} {
+		options.Filter = test.filterType
+		got, err := RenderHDR(context.Background(), &w, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y, want := range test.want {
+			if c := got.At(1, y); math.Abs(c.Y-want) > 0.05 {
+				t.Errorf("%v: row %d is %v, want %v", test.filterType, y, c.Y, want)
+			}
+		}
+	}
+}
+
+func TestParseFilterType(t *testing.T) {
+	for _, filterType := range allFilterTypes {
+		if got, err := ParseFilterType(filterType.String()); err != nil || got != filterType {
+			t.Errorf("ParseFilterType(%q) returned %v, %v", filterType.String(), got, err)
+		}
+	}
+	if _, err := ParseFilterType("sinc"); err == nil {
+		t.Error("an unknown filter parsed")
+	}
+}
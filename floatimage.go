@@ -0,0 +1,52 @@
+package raytracer
+
+import (
+	"image"
+)
+
+// FloatImage is an image with linear float32 RGB pixels, light above 1.0 is kept as is
+type FloatImage struct {
+	Width, Height int
+	// Pix holds the R, G and B components of the pixels row by row, starting from the top left
+	Pix []float32
+}
+
+// NewFloatImage returns a black image of width x height pixels
+func NewFloatImage(width, height int) *FloatImage {
+	return &FloatImage{
+		Width:  width,
+		Height: height,
+		Pix:    make([]float32, 3*width*height),
+	}
+}
+
+// At returns the color of the pixel at x, y
+func (f *FloatImage) At(x, y int) Vector3 {
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		return Vector3{0, 0, 0}
+	}
+	i := 3 * (y*f.Width + x)
+	return Vector3{float64(f.Pix[i]), float64(f.Pix[i+1]), float64(f.Pix[i+2])}
+}
+
+// Set sets the color of the pixel at x, y, pixels outside of the image are ignored
+func (f *FloatImage) Set(x, y int, c Vector3) {
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		return
+	}
+	i := 3 * (y*f.Width + x)
+	f.Pix[i] = float32(c.X)
+	f.Pix[i+1] = float32(c.Y)
+	f.Pix[i+2] = float32(c.Z)
+}
+
+// ToRGBA converts the image to 8 bit sRGB for display with the tone mapping
+func (f *FloatImage) ToRGBA(toneMapping ToneMapping) *image.RGBA {
+	img := createImage(f.Width, f.Height)
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			img.Set(x, y, toneMapping.Apply(f.At(x, y)).ToColor())
+		}
+	}
+	return img
+}
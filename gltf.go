@@ -0,0 +1,901 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// gltfDocument holds the parts of a glTF 2.0 file the raytracer uses, see https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	Scene       *int                           `json:"scene"`
+	Scenes      []gltfScene                    `json:"scenes"`
+	Nodes       []gltfNode                     `json:"nodes"`
+	Meshes      []gltfMesh                     `json:"meshes"`
+	Accessors   []gltfAccessor                 `json:"accessors"`
+	BufferViews []gltfBufferView               `json:"bufferViews"`
+	Buffers     []gltfBuffer                   `json:"buffers"`
+	Materials   []gltfMaterial                 `json:"materials"`
+	Textures    []gltfTexture                  `json:"textures"`
+	Images      []gltfImage                    `json:"images"`
+	Cameras     []gltfCamera                   `json:"cameras"`
+	Extensions  gltfRootExtensions             `json:"extensions"`
+	Required    []string                       `json:"extensionsRequired"`
+	buffers     [][]byte                       // the contents of Buffers
+	textures    map[gltfImageKey]*ImageTexture // the images loaded so far
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Children    []int     `json:"children"`
+	Mesh        *int      `json:"mesh"`
+	Camera      *int      `json:"camera"`
+	Matrix      []float64 `json:"matrix"`
+	Translation []float64 `json:"translation"`
+	Rotation    []float64 `json:"rotation"`
+	Scale       []float64 `json:"scale"`
+	Extensions  struct {
+		Light *struct {
+			Light int `json:"light"`
+		} `json:"KHR_lights_punctual"`
+	} `json:"extensions"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfMaterial struct {
+	PBR struct {
+		BaseColorFactor  []float64        `json:"baseColorFactor"`
+		BaseColorTexture *gltfTextureInfo `json:"baseColorTexture"`
+		MetallicFactor   *float64         `json:"metallicFactor"`
+		RoughnessFactor  *float64         `json:"roughnessFactor"`
+		// MetallicRoughnessTexture holds the roughness in its green channel and the metalness in its blue one
+		MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	EmissiveFactor []float64 `json:"emissiveFactor"`
+	Extensions     struct {
+		EmissiveStrength *struct {
+			EmissiveStrength float64 `json:"emissiveStrength"`
+		} `json:"KHR_materials_emissive_strength"`
+		Transmission *struct {
+			TransmissionFactor float64 `json:"transmissionFactor"`
+		} `json:"KHR_materials_transmission"`
+		IOR *struct {
+			IOR float64 `json:"ior"`
+		} `json:"KHR_materials_ior"`
+	} `json:"extensions"`
+}
+
+// gltfImageKey identifies a loaded image, the same image may hold colors or linear data for different materials
+type gltfImageKey struct {
+	source int
+	linear bool
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri"`
+	BufferView *int   `json:"bufferView"`
+}
+
+// gltfCamera is a perspective or orthographic camera, only perspective ones are supported
+type gltfCamera struct {
+	Perspective *struct {
+		YFov float64 `json:"yfov"`
+	} `json:"perspective"`
+}
+
+type gltfRootExtensions struct {
+	LightsPunctual struct {
+		Lights []gltfLight `json:"lights"`
+	} `json:"KHR_lights_punctual"`
+}
+
+type gltfLight struct {
+	Type      string    `json:"type"`
+	Color     []float64 `json:"color"`
+	Intensity *float64  `json:"intensity"`
+	Spot      struct {
+		InnerConeAngle float64  `json:"innerConeAngle"`
+		OuterConeAngle *float64 `json:"outerConeAngle"`
+	} `json:"spot"`
+}
+
+// gltfSupportedExtensions are the extensions LoadGLTF understands, files requiring others are rejected
+var gltfSupportedExtensions = map[string]bool{
+	"KHR_lights_punctual":             true,
+	"KHR_materials_emissive_strength": true,
+	"KHR_materials_transmission":      true,
+	"KHR_materials_ior":               true,
+}
+
+const (
+	glbMagic     = 0x46546c67 // "glTF"
+	glbChunkJSON = 0x4e4f534a // "JSON"
+	glbChunkBIN  = 0x004e4942 // "BIN\x00"
+)
+
+// LoadGLTF reads a glTF 2.0 .gltf or .glb file and returns the World of its default scene,
+// its camera is set up for an image of width x height pixels
+// Meshes, node transforms, the first perspective camera, KHR_lights_punctual lights and
+// metallic-roughness materials are imported. Scenes without a camera are framed from the front,
+// scenes without lights get the default sky
+func LoadGLTF(filePath string, width, height int) (World, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return World{}, err
+	}
+	world, err := parseGLTF(data, filepath.Dir(filePath), float64(width), float64(height))
+	if err != nil {
+		return World{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return world, nil
+}
+
+// parseGLTF parses .gltf or .glb data, external files are relative to dir
+func parseGLTF(data []byte, dir string, width, height float64) (World, error) {
+	jsonData := data
+	var binChunk []byte
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == glbMagic {
+		var err error
+		if jsonData, binChunk, err = parseGLB(data); err != nil {
+			return World{}, err
+		}
+	}
+
+	var doc gltfDocument
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return World{}, err
+	}
+	if !strings.HasPrefix(doc.Asset.Version, "2.") {
+		return World{}, fmt.Errorf("unsupported glTF version %q", doc.Asset.Version)
+	}
+	for _, extension := range doc.Required {
+		if !gltfSupportedExtensions[extension] {
+			return World{}, fmt.Errorf("unsupported required extension %s", extension)
+		}
+	}
+	if err := doc.loadBuffers(dir, binChunk); err != nil {
+		return World{}, err
+	}
+	doc.textures = make(map[gltfImageKey]*ImageTexture)
+	return doc.toWorld(dir, width, height)
+}
+
+// parseGLB splits a binary glTF file into its JSON and binary chunks
+func parseGLB(data []byte) ([]byte, []byte, error) {
+	if len(data) < 12 {
+		return nil, nil, fmt.Errorf("truncated .glb header")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != 2 {
+		return nil, nil, fmt.Errorf("unsupported .glb version %d", version)
+	}
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, fmt.Errorf(".glb is %d bytes long, header says %d", len(data), length)
+	}
+
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= length; {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		start := offset + 8
+		if start+chunkLength > length {
+			return nil, nil, fmt.Errorf("truncated .glb chunk at byte %d", offset)
+		}
+		switch chunkType {
+		case glbChunkJSON:
+			jsonChunk = data[start : start+chunkLength]
+		case glbChunkBIN:
+			if binChunk == nil {
+				binChunk = data[start : start+chunkLength]
+			}
+		}
+		offset = start + chunkLength
+	}
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf(".glb has no JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// loadBuffers reads the buffers from data URIs, files relative to dir or the .glb binary chunk
+func (doc *gltfDocument) loadBuffers(dir string, binChunk []byte) error {
+	doc.buffers = make([][]byte, len(doc.Buffers))
+	for i, buffer := range doc.Buffers {
+		var data []byte
+		if buffer.URI == "" {
+			if binChunk == nil {
+				return fmt.Errorf("buffer %d has no uri and there's no .glb binary chunk", i)
+			}
+			data = binChunk
+		} else {
+			var err error
+			if data, err = readGLTFURI(dir, buffer.URI); err != nil {
+				return fmt.Errorf("buffer %d: %w", i, err)
+			}
+		}
+		if len(data) < buffer.ByteLength {
+			return fmt.Errorf("buffer %d is %d bytes long, expected %d", i, len(data), buffer.ByteLength)
+		}
+		doc.buffers[i] = data
+	}
+	return nil
+}
+
+// readGLTFURI returns the contents of a data URI or of a file relative to dir
+func readGLTFURI(dir, uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("only base64 data URIs are supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	path, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(resolvePath(dir, filepath.FromSlash(path)))
+}
+
+func (doc *gltfDocument) toWorld(dir string, width, height float64) (World, error) {
+	if doc.Scene != nil && (*doc.Scene < 0 || *doc.Scene >= len(doc.Scenes)) {
+		return World{}, fmt.Errorf("scene %d doesn't exist", *doc.Scene)
+	}
+	var roots []int
+	switch {
+	case doc.Scene != nil:
+		roots = doc.Scenes[*doc.Scene].Nodes
+	case len(doc.Scenes) > 0:
+		roots = doc.Scenes[0].Nodes
+	default:
+		// Without scenes every node that isn't a child is a root
+		isChild := make([]bool, len(doc.Nodes))
+		for _, node := range doc.Nodes {
+			for _, child := range node.Children {
+				if child >= 0 && child < len(doc.Nodes) {
+					isChild[child] = true
+				}
+			}
+		}
+		for i := range doc.Nodes {
+			if !isChild[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	builder := gltfWorldBuilder{doc: doc, dir: dir, visiting: make(map[int]bool)}
+	for _, root := range roots {
+		if err := builder.addNode(root, IdentityMatrix()); err != nil {
+			return World{}, err
+		}
+	}
+
+	world := World{
+		Hittables: builder.hittables,
+		Lights:    builder.lights,
+	}
+	if len(world.Lights) == 0 && !builder.hasEmission {
+		world.SkyColorAbove = Vector3{0.5, 0.7, 1.0}
+		world.SkyColorBelow = Vector3{1.0, 1.0, 1.0}
+	}
+	if builder.camera != nil {
+		world.Camera = builder.camera.toCamera(width, height)
+	} else {
+		world.Camera = frameBox(hittablesBox(world.Hittables), width, height)
+	}
+	return world, nil
+}
+
+// gltfPlacedCamera is a glTF camera with the transform of its node
+type gltfPlacedCamera struct {
+	transform Matrix4
+	yFov      float64
+}
+
+// toCamera returns the camera looking down the node's -Z axis with its Y axis up
+func (c gltfPlacedCamera) toCamera(width, height float64) Camera {
+	position := c.transform.TransformPoint(Vector3{0, 0, 0})
+	forward := c.transform.TransformDirection(Vector3{0, 0, -1}).Unit()
+	up := c.transform.TransformDirection(Vector3{0, 1, 0}).Unit()
+	verticalFov := c.yFov * 180.0 / math.Pi
+	return NewCamera(position, position.Add(forward), up, verticalFov, 0.0, 1.0, width, height)
+}
+
+// frameBox returns a camera in front of the box, on the +Z side, that sees all of it
+func frameBox(box AABB, width, height float64) Camera {
+	verticalFov := 40.0
+	center := Vector3{0, 0, 0}
+	radius := 1.0
+	if d := box.diagonal(); d > 0 {
+		center = box.Centroid()
+		radius = 0.5 * d
+	}
+	distance := radius / math.Sin(verticalFov*math.Pi/360.0)
+	position := center.Add(Vector3{0, 0, distance})
+	return NewCamera(position, center, Vector3{0, 1, 0}, verticalFov, 0.0, distance, width, height)
+}
+
+// gltfWorldBuilder collects the contents of the node hierarchy
+type gltfWorldBuilder struct {
+	doc         *gltfDocument
+	dir         string
+	hittables   []Hittable
+	lights      []DeltaLight
+	camera      *gltfPlacedCamera
+	hasEmission bool
+	// visiting holds the nodes on the path to the current one, to catch cycles
+	visiting map[int]bool
+}
+
+func (b *gltfWorldBuilder) addNode(index int, parent Matrix4) error {
+	if index < 0 || index >= len(b.doc.Nodes) {
+		return fmt.Errorf("node %d doesn't exist", index)
+	}
+	if b.visiting[index] {
+		return fmt.Errorf("node %d is its own ancestor", index)
+	}
+	b.visiting[index] = true
+	defer delete(b.visiting, index)
+
+	node := b.doc.Nodes[index]
+	local, err := node.localTransform()
+	if err != nil {
+		return fmt.Errorf("node %d: %w", index, err)
+	}
+	transform := parent.Multiply(local)
+
+	if node.Mesh != nil {
+		if err := b.addMesh(*node.Mesh, transform); err != nil {
+			return fmt.Errorf("node %d: %w", index, err)
+		}
+	}
+	if node.Camera != nil && b.camera == nil {
+		if *node.Camera < 0 || *node.Camera >= len(b.doc.Cameras) {
+			return fmt.Errorf("node %d: camera %d doesn't exist", index, *node.Camera)
+		}
+		if perspective := b.doc.Cameras[*node.Camera].Perspective; perspective != nil {
+			b.camera = &gltfPlacedCamera{transform: transform, yFov: perspective.YFov}
+		}
+	}
+	if node.Extensions.Light != nil {
+		if err := b.addLight(node.Extensions.Light.Light, transform); err != nil {
+			return fmt.Errorf("node %d: %w", index, err)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := b.addNode(child, transform); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localTransform returns the node's matrix, or its translation, rotation and scale combined
+func (n gltfNode) localTransform() (Matrix4, error) {
+	if n.Matrix != nil {
+		if len(n.Matrix) != 16 {
+			return Matrix4{}, fmt.Errorf("matrix has %d elements, expected 16", len(n.Matrix))
+		}
+		var m Matrix4
+		for column := 0; column < 4; column++ {
+			for row := 0; row < 4; row++ {
+				m[row][column] = n.Matrix[column*4+row] // glTF matrices are column major
+			}
+		}
+		return m, nil
+	}
+
+	transform := IdentityMatrix()
+	if n.Translation != nil {
+		if len(n.Translation) != 3 {
+			return Matrix4{}, fmt.Errorf("translation has %d elements, expected 3", len(n.Translation))
+		}
+		transform = transform.Multiply(TranslationMatrix(Vector3{n.Translation[0], n.Translation[1], n.Translation[2]}))
+	}
+	if n.Rotation != nil {
+		if len(n.Rotation) != 4 {
+			return Matrix4{}, fmt.Errorf("rotation has %d elements, expected 4", len(n.Rotation))
+		}
+		transform = transform.Multiply(QuaternionMatrix(n.Rotation[0], n.Rotation[1], n.Rotation[2], n.Rotation[3]))
+	}
+	if n.Scale != nil {
+		if len(n.Scale) != 3 {
+			return Matrix4{}, fmt.Errorf("scale has %d elements, expected 3", len(n.Scale))
+		}
+		transform = transform.Multiply(ScaleMatrix(Vector3{n.Scale[0], n.Scale[1], n.Scale[2]}))
+	}
+	return transform, nil
+}
+
+func (b *gltfWorldBuilder) addMesh(index int, transform Matrix4) error {
+	if index < 0 || index >= len(b.doc.Meshes) {
+		return fmt.Errorf("mesh %d doesn't exist", index)
+	}
+	normalMatrix := transform.NormalMatrix()
+	for i, primitive := range b.doc.Meshes[index].Primitives {
+		triangles, err := b.doc.primitiveTriangles(primitive)
+		if err != nil {
+			return fmt.Errorf("mesh %d primitive %d: %w", index, i, err)
+		}
+		material, err := b.material(primitive.Material)
+		if err != nil {
+			return fmt.Errorf("mesh %d primitive %d: %w", index, i, err)
+		}
+		if e, ok := material.(emitter); ok && e.emission().Luminance() > 0 {
+			b.hasEmission = true
+		}
+		for _, tri := range triangles {
+			tri.V0 = transform.TransformPoint(tri.V0)
+			tri.V1 = transform.TransformPoint(tri.V1)
+			tri.V2 = transform.TransformPoint(tri.V2)
+			tri.N0 = normalMatrix.TransformDirection(tri.N0).Unit()
+			tri.N1 = normalMatrix.TransformDirection(tri.N1).Unit()
+			tri.N2 = normalMatrix.TransformDirection(tri.N2).Unit()
+			tri.Material = material
+			b.hittables = append(b.hittables, tri)
+		}
+	}
+	return nil
+}
+
+// primitiveTriangles returns the triangles of the primitive in object space, without material
+// Primitives made of points or lines have no triangles
+func (doc *gltfDocument) primitiveTriangles(p gltfPrimitive) ([]Triangle, error) {
+	mode := 4
+	if p.Mode != nil {
+		mode = *p.Mode
+	}
+	if mode < 4 || mode > 6 {
+		return nil, nil
+	}
+
+	positionAccessor, ok := p.Attributes["POSITION"]
+	if !ok {
+		return nil, fmt.Errorf("primitive has no POSITION attribute")
+	}
+	positions, err := doc.readVectors(positionAccessor, "VEC3")
+	if err != nil {
+		return nil, fmt.Errorf("POSITION: %w", err)
+	}
+	var normals, texCoords []Vector3
+	if accessor, ok := p.Attributes["NORMAL"]; ok {
+		if normals, err = doc.readVectors(accessor, "VEC3"); err != nil {
+			return nil, fmt.Errorf("NORMAL: %w", err)
+		}
+	}
+	if accessor, ok := p.Attributes["TEXCOORD_0"]; ok {
+		if texCoords, err = doc.readVectors(accessor, "VEC2"); err != nil {
+			return nil, fmt.Errorf("TEXCOORD_0: %w", err)
+		}
+	}
+	if (normals != nil && len(normals) != len(positions)) || (texCoords != nil && len(texCoords) != len(positions)) {
+		return nil, fmt.Errorf("attributes have different counts")
+	}
+
+	indices := make([]int, len(positions))
+	if p.Indices != nil {
+		if indices, err = doc.readIndices(*p.Indices); err != nil {
+			return nil, fmt.Errorf("indices: %w", err)
+		}
+	} else {
+		for i := range indices {
+			indices[i] = i
+		}
+	}
+	for _, index := range indices {
+		if index < 0 || index >= len(positions) {
+			return nil, fmt.Errorf("index %d out of range, %d vertices", index, len(positions))
+		}
+	}
+
+	var corners [][3]int
+	switch mode {
+	case 4: // triangles
+		for i := 0; i+2 < len(indices); i += 3 {
+			corners = append(corners, [3]int{indices[i], indices[i+1], indices[i+2]})
+		}
+	case 5: // triangle strip, every other triangle is flipped to keep the winding
+		for i := 0; i+2 < len(indices); i++ {
+			if i%2 == 0 {
+				corners = append(corners, [3]int{indices[i], indices[i+1], indices[i+2]})
+			} else {
+				corners = append(corners, [3]int{indices[i+1], indices[i], indices[i+2]})
+			}
+		}
+	case 6: // triangle fan
+		for i := 1; i+1 < len(indices); i++ {
+			corners = append(corners, [3]int{indices[0], indices[i], indices[i+1]})
+		}
+	}
+
+	triangles := make([]Triangle, 0, len(corners))
+	for _, c := range corners {
+		tri := Triangle{V0: positions[c[0]], V1: positions[c[1]], V2: positions[c[2]]}
+		faceNormal := tri.V1.Subtract(tri.V0).Cross(tri.V2.Subtract(tri.V0))
+		if faceNormal.LengthSquared() == 0 {
+			continue // Degenerate triangle, rays can't hit it
+		}
+		if normals != nil {
+			tri.N0, tri.N1, tri.N2 = normals[c[0]], normals[c[1]], normals[c[2]]
+		} else {
+			faceNormal = faceNormal.Unit()
+			tri.N0, tri.N1, tri.N2 = faceNormal, faceNormal, faceNormal
+		}
+		if texCoords != nil {
+			// glTF texture coordinates start at the top of the image
+			tri.UV0 = Vector3{texCoords[c[0]].X, 1 - texCoords[c[0]].Y, 0}
+			tri.UV1 = Vector3{texCoords[c[1]].X, 1 - texCoords[c[1]].Y, 0}
+			tri.UV2 = Vector3{texCoords[c[2]].X, 1 - texCoords[c[2]].Y, 0}
+		}
+		triangles = append(triangles, tri)
+	}
+	return triangles, nil
+}
+
+// gltfComponentCounts are the number of components of the accessor types
+var gltfComponentCounts = map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4}
+
+// gltfComponentSizes are the byte sizes of the accessor component types
+var gltfComponentSizes = map[int]int{
+	5120: 1, // BYTE
+	5121: 1, // UNSIGNED_BYTE
+	5122: 2, // SHORT
+	5123: 2, // UNSIGNED_SHORT
+	5125: 4, // UNSIGNED_INT
+	5126: 4, // FLOAT
+}
+
+// readAccessor returns the components of the accessor's elements, normalized integers are mapped to [0..1] or [-1..1]
+func (doc *gltfDocument) readAccessor(index int, accessorType string) ([]float64, error) {
+	if index < 0 || index >= len(doc.Accessors) {
+		return nil, fmt.Errorf("accessor %d doesn't exist", index)
+	}
+	a := doc.Accessors[index]
+	if a.Type != accessorType {
+		return nil, fmt.Errorf("accessor %d is %s, expected %s", index, a.Type, accessorType)
+	}
+	if a.Sparse != nil {
+		return nil, fmt.Errorf("accessor %d is sparse, which isn't supported", index)
+	}
+	components := gltfComponentCounts[a.Type]
+	size, ok := gltfComponentSizes[a.ComponentType]
+	if !ok {
+		return nil, fmt.Errorf("accessor %d has unknown component type %d", index, a.ComponentType)
+	}
+	if a.Count < 0 || a.ByteOffset < 0 {
+		return nil, fmt.Errorf("accessor %d has a negative count or byte offset", index)
+	}
+	// without a buffer view the values are zeros that only sparse accessors replace
+	if a.BufferView == nil {
+		return nil, fmt.Errorf("accessor %d has no buffer view", index)
+	}
+
+	if *a.BufferView < 0 || *a.BufferView >= len(doc.BufferViews) {
+		return nil, fmt.Errorf("buffer view %d doesn't exist", *a.BufferView)
+	}
+	view := doc.BufferViews[*a.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(doc.buffers) {
+		return nil, fmt.Errorf("buffer %d doesn't exist", view.Buffer)
+	}
+	buffer := doc.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return nil, fmt.Errorf("buffer view %d has a negative byte offset, length or stride", *a.BufferView)
+	}
+	if view.ByteOffset > len(buffer) || view.ByteLength > len(buffer)-view.ByteOffset {
+		return nil, fmt.Errorf("buffer view %d is outside of its buffer", *a.BufferView)
+	}
+	data := buffer[view.ByteOffset : view.ByteOffset+view.ByteLength]
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = components * size
+	}
+	// the elements have to fit into the view before they're allocated, checked without overflowing on huge counts
+	elementSize := components * size
+	if a.Count > 0 && (a.ByteOffset > len(data)-elementSize || a.Count-1 > (len(data)-elementSize-a.ByteOffset)/stride) {
+		return nil, fmt.Errorf("accessor %d is outside of its buffer view", index)
+	}
+	values := make([]float64, a.Count*components)
+
+	for i := 0; i < a.Count; i++ {
+		for j := 0; j < components; j++ {
+			offset := a.ByteOffset + i*stride + j*size
+			values[i*components+j] = gltfComponent(data[offset:], a.ComponentType, a.Normalized)
+		}
+	}
+	return values, nil
+}
+
+func gltfComponent(data []byte, componentType int, normalized bool) float64 {
+	switch componentType {
+	case 5120:
+		value := float64(int8(data[0]))
+		if normalized {
+			return math.Max(value/127.0, -1.0)
+		}
+		return value
+	case 5121:
+		value := float64(data[0])
+		if normalized {
+			return value / 255.0
+		}
+		return value
+	case 5122:
+		value := float64(int16(binary.LittleEndian.Uint16(data)))
+		if normalized {
+			return math.Max(value/32767.0, -1.0)
+		}
+		return value
+	case 5123:
+		value := float64(binary.LittleEndian.Uint16(data))
+		if normalized {
+			return value / 65535.0
+		}
+		return value
+	case 5125:
+		return float64(binary.LittleEndian.Uint32(data))
+	default:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+}
+
+// readVectors returns the VEC2 or VEC3 elements of the accessor, VEC2 elements have Z = 0
+func (doc *gltfDocument) readVectors(index int, accessorType string) ([]Vector3, error) {
+	values, err := doc.readAccessor(index, accessorType)
+	if err != nil {
+		return nil, err
+	}
+	components := gltfComponentCounts[accessorType]
+	vectors := make([]Vector3, len(values)/components)
+	for i := range vectors {
+		v := values[i*components : (i+1)*components]
+		vectors[i].X, vectors[i].Y = v[0], v[1]
+		if components > 2 {
+			vectors[i].Z = v[2]
+		}
+	}
+	return vectors, nil
+}
+
+func (doc *gltfDocument) readIndices(index int) ([]int, error) {
+	values, err := doc.readAccessor(index, "SCALAR")
+	if err != nil {
+		return nil, err
+	}
+	if t := doc.Accessors[index].ComponentType; t != 5121 && t != 5123 && t != 5125 {
+		return nil, fmt.Errorf("indices have component type %d, expected an unsigned integer", t)
+	}
+	indices := make([]int, len(values))
+	for i, v := range values {
+		indices[i] = int(v)
+	}
+	return indices, nil
+}
+
+// material maps a glTF material onto a Principled material with the same metalness, roughness, transmission and
+// emission, the emissive texture isn't read, only the emissive factor
+// Primitives without a material get a light gray Principled material
+func (b *gltfWorldBuilder) material(index *int) (Material, error) {
+	if index == nil {
+		return Principled{BaseColor: Vector3{0.8, 0.8, 0.8}, Roughness: 1, Specular: 0.5}, nil
+	}
+	if *index < 0 || *index >= len(b.doc.Materials) {
+		return nil, fmt.Errorf("material %d doesn't exist", *index)
+	}
+	m := b.doc.Materials[*index]
+
+	// the glTF dielectrics reflect 4% at normal incidence, which is a Specular of 0.5
+	material := Principled{BaseColor: Vector3{1, 1, 1}, Metallic: 1, Roughness: 1, Specular: 0.5}
+	if len(m.EmissiveFactor) == 3 {
+		material.Emission = Vector3{m.EmissiveFactor[0], m.EmissiveFactor[1], m.EmissiveFactor[2]}
+		if m.Extensions.EmissiveStrength != nil {
+			material.Emission = material.Emission.Scale(m.Extensions.EmissiveStrength.EmissiveStrength)
+		}
+	}
+	if len(m.PBR.BaseColorFactor) >= 3 {
+		material.BaseColor = Vector3{m.PBR.BaseColorFactor[0], m.PBR.BaseColorFactor[1], m.PBR.BaseColorFactor[2]}
+	}
+	if m.PBR.BaseColorTexture != nil {
+		imageTexture, err := b.doc.texture(m.PBR.BaseColorTexture.Index, b.dir, false)
+		if err != nil {
+			return nil, fmt.Errorf("material %d: %w", *index, err)
+		}
+		material.BaseColorTexture = imageTexture
+		if material.BaseColor != (Vector3{1, 1, 1}) {
+			material.BaseColorTexture = tintedTexture{Texture: imageTexture, Tint: material.BaseColor}
+		}
+	}
+
+	if m.PBR.MetallicFactor != nil {
+		material.Metallic = *m.PBR.MetallicFactor
+	}
+	if m.PBR.RoughnessFactor != nil {
+		material.Roughness = *m.PBR.RoughnessFactor
+	}
+	if m.PBR.MetallicRoughnessTexture != nil {
+		imageTexture, err := b.doc.texture(m.PBR.MetallicRoughnessTexture.Index, b.dir, true)
+		if err != nil {
+			return nil, fmt.Errorf("material %d: %w", *index, err)
+		}
+		material.RoughnessTexture = channelTexture{Texture: imageTexture, Channel: 1, Scale: material.Roughness}
+		material.MetallicTexture = channelTexture{Texture: imageTexture, Channel: 2, Scale: material.Metallic}
+	}
+
+	if m.Extensions.Transmission != nil {
+		material.Transmission = m.Extensions.Transmission.TransmissionFactor
+	}
+	if m.Extensions.IOR != nil && m.Extensions.IOR.IOR >= 1 {
+		material.IndexOfRefraction = m.Extensions.IOR.IOR
+	}
+	return material, nil
+}
+
+// texture returns the image of the glTF texture, images are decoded once
+func (doc *gltfDocument) texture(index int, dir string, linear bool) (*ImageTexture, error) {
+	if index < 0 || index >= len(doc.Textures) || doc.Textures[index].Source == nil {
+		return nil, fmt.Errorf("texture %d doesn't exist or has no image", index)
+	}
+	source := *doc.Textures[index].Source
+	key := gltfImageKey{source: source, linear: linear}
+	if t, ok := doc.textures[key]; ok {
+		return t, nil
+	}
+	if source < 0 || source >= len(doc.Images) {
+		return nil, fmt.Errorf("image %d doesn't exist", source)
+	}
+
+	img := doc.Images[source]
+	var data []byte
+	var err error
+	switch {
+	case img.BufferView != nil:
+		if *img.BufferView < 0 || *img.BufferView >= len(doc.BufferViews) {
+			return nil, fmt.Errorf("image %d: buffer view %d doesn't exist", source, *img.BufferView)
+		}
+		view := doc.BufferViews[*img.BufferView]
+		if view.Buffer < 0 || view.Buffer >= len(doc.buffers) || view.ByteOffset+view.ByteLength > len(doc.buffers[view.Buffer]) {
+			return nil, fmt.Errorf("image %d is outside of its buffer", source)
+		}
+		data = doc.buffers[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength]
+	case img.URI != "":
+		if data, err = readGLTFURI(dir, img.URI); err != nil {
+			return nil, fmt.Errorf("image %d: %w", source, err)
+		}
+	default:
+		return nil, fmt.Errorf("image %d has no data", source)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image %d: %w", source, err)
+	}
+	decode := srgbToLinear
+	if linear {
+		decode = func(c float64) float64 { return c }
+	}
+	t := newImageTexture(decoded, decode)
+	doc.textures[key] = t
+	return t, nil
+}
+
+// tintedTexture multiplies a texture by a color, like the glTF base color factor
+type tintedTexture struct {
+	Texture Texture
+	Tint    Vector3
+}
+
+// Value returns the texture's value multiplied by the tint
+func (t tintedTexture) Value(u, v float64, p Vector3) Vector3 {
+	return t.Texture.Value(u, v, p).MultiplyComponents(t.Tint)
+}
+
+// channelTexture is one channel of a texture as a gray value, scaled like the glTF metallic and roughness factors
+type channelTexture struct {
+	Texture Texture
+	// Channel is 0 for red, 1 for green and 2 for blue
+	Channel int
+	Scale   float64
+}
+
+// Value returns the texture's channel times the scale in every component
+func (t channelTexture) Value(u, v float64, p Vector3) Vector3 {
+	c := t.Texture.Value(u, v, p)
+	value := [3]float64{c.X, c.Y, c.Z}[t.Channel] * t.Scale
+	return Vector3{value, value, value}
+}
+
+// addLight adds a KHR_lights_punctual light placed by the node's transform, lights shine down their -Z axis
+func (b *gltfWorldBuilder) addLight(index int, transform Matrix4) error {
+	lights := b.doc.Extensions.LightsPunctual.Lights
+	if index < 0 || index >= len(lights) {
+		return fmt.Errorf("light %d doesn't exist", index)
+	}
+	l := lights[index]
+	color := Vector3{1, 1, 1}
+	if len(l.Color) == 3 {
+		color = Vector3{l.Color[0], l.Color[1], l.Color[2]}
+	}
+	intensity := 1.0
+	if l.Intensity != nil {
+		intensity = *l.Intensity
+	}
+	position := transform.TransformPoint(Vector3{0, 0, 0})
+	direction := transform.TransformDirection(Vector3{0, 0, -1}).Unit()
+
+	switch l.Type {
+	case "point":
+		b.lights = append(b.lights, PointLight{Position: position, Intensity: color.Scale(intensity)})
+	case "spot":
+		outer := math.Pi / 4.0
+		if l.Spot.OuterConeAngle != nil {
+			outer = *l.Spot.OuterConeAngle
+		}
+		b.lights = append(b.lights, SpotLight{
+			Position:       position,
+			Direction:      direction,
+			Intensity:      color.Scale(intensity),
+			InnerConeAngle: l.Spot.InnerConeAngle * 180.0 / math.Pi,
+			OuterConeAngle: outer * 180.0 / math.Pi,
+		})
+	case "directional":
+		b.lights = append(b.lights, DirectionalLight{Direction: direction, Irradiance: color.Scale(intensity)})
+	default:
+		return fmt.Errorf("light %d has unknown type %q", index, l.Type)
+	}
+	return nil
+}
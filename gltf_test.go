@@ -0,0 +1,283 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestGLTF returns a glTF document with a textured unit quad in the XY plane, moved 2 units down -Z
+// and turned to face +X, a camera at the origin looking down -Z and a point light above it,
+// and the buffer it refers to
+func newTestGLTF(t *testing.T) (map[string]interface{}, []byte) {
+	var buffer bytes.Buffer
+	write := func(data interface{}) {
+		if err := binary.Write(&buffer, binary.LittleEndian, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write([]float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}) // positions, 48 bytes
+	write([]float32{0, 1, 1, 1, 1, 0, 0, 0})             // texture coordinates, 32 bytes
+	write([]uint16{0, 1, 2, 0, 2, 3})                    // indices, 12 bytes
+
+	var imageData bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 255, 255, 255})
+	img.Set(1, 0, color.RGBA{0, 102, 51, 255})
+	if err := png.Encode(&imageData, img); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := map[string]interface{}{
+		"asset":          map[string]interface{}{"version": "2.0"},
+		"extensionsUsed": []string{"KHR_lights_punctual"},
+		"scene":          0,
+		"scenes":         []interface{}{map[string]interface{}{"nodes": []int{0, 1}}},
+		"nodes": []interface{}{
+			map[string]interface{}{
+				"translation": []float64{0, 0, -2},
+				"children":    []int{2},
+			},
+			map[string]interface{}{"camera": 0},
+			map[string]interface{}{
+				// 90 degrees around Y
+				"rotation": []float64{0, math.Sqrt2 / 2, 0, math.Sqrt2 / 2},
+				"mesh":     0,
+				"children": []int{3},
+			},
+			map[string]interface{}{
+				"matrix":     []float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 3, 0, 1},
+				"extensions": map[string]interface{}{"KHR_lights_punctual": map[string]interface{}{"light": 0}},
+			},
+		},
+		"cameras": []interface{}{map[string]interface{}{
+			"type":        "perspective",
+			"perspective": map[string]interface{}{"yfov": math.Pi / 2, "znear": 0.1},
+		}},
+		"meshes": []interface{}{map[string]interface{}{
+			"primitives": []interface{}{map[string]interface{}{
+				"attributes": map[string]int{"POSITION": 0, "TEXCOORD_0": 1},
+				"indices":    2,
+				"material":   0,
+			}},
+		}},
+		"materials": []interface{}{map[string]interface{}{
+			"pbrMetallicRoughness": map[string]interface{}{
+				"baseColorFactor":  []float64{0.5, 0.5, 0.5, 1},
+				"baseColorTexture": map[string]int{"index": 0},
+				"metallicFactor":   1,
+				"roughnessFactor":  0.5,
+				// the same image holds the roughness in green and the metalness in blue, as linear values
+				"metallicRoughnessTexture": map[string]int{"index": 0},
+			},
+		}},
+		"textures": []interface{}{map[string]int{"source": 0}},
+		"images": []interface{}{map[string]string{
+			"uri": "data:image/png;base64," + base64.StdEncoding.EncodeToString(imageData.Bytes()),
+		}},
+		"accessors": []interface{}{
+			map[string]interface{}{"bufferView": 0, "componentType": 5126, "count": 4, "type": "VEC3"},
+			map[string]interface{}{"bufferView": 1, "componentType": 5126, "count": 4, "type": "VEC2"},
+			map[string]interface{}{"bufferView": 2, "componentType": 5123, "count": 6, "type": "SCALAR"},
+		},
+		"bufferViews": []interface{}{
+			map[string]int{"buffer": 0, "byteOffset": 0, "byteLength": 48},
+			map[string]int{"buffer": 0, "byteOffset": 48, "byteLength": 32},
+			map[string]int{"buffer": 0, "byteOffset": 80, "byteLength": 12},
+		},
+		"extensions": map[string]interface{}{"KHR_lights_punctual": map[string]interface{}{
+			"lights": []interface{}{map[string]interface{}{"type": "point", "color": []float64{1, 0.5, 0.25}, "intensity": 10}},
+		}},
+	}
+	return doc, buffer.Bytes()
+}
+
+func TestLoadGLTF(t *testing.T) {
+	doc, buffer := newTestGLTF(t)
+	doc["buffers"] = []interface{}{map[string]interface{}{
+		"uri":        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buffer),
+		"byteLength": len(buffer),
+	}}
+	gltfData, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := parseGLTF(gltfData, ".", 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(world.Hittables) != 2 {
+		t.Fatalf("got %d hittables, want the 2 triangles of the quad", len(world.Hittables))
+	}
+	tri := world.Hittables[1].(Triangle)
+	// The second triangle has the corners 0, 2 and 3 of the quad turned around Y and moved down -Z
+	wantVertices := []Vector3{{0, 0, -2}, {0, 1, -3}, {0, 1, -2}}
+	for i, got := range []Vector3{tri.V0, tri.V1, tri.V2} {
+		if got.Subtract(wantVertices[i]).Length() > 1e-6 {
+			t.Errorf("vertex %d is %v, want %v", i, got, wantVertices[i])
+		}
+	}
+	if tri.N0.Subtract(Vector3{1, 0, 0}).Length() > 1e-6 {
+		t.Errorf("flat normal is %v, want 1 0 0", tri.N0)
+	}
+	if tri.UV1 != (Vector3{1, 1, 0}) {
+		t.Errorf("texture coordinate is %v, want 1 1 0 with v flipped", tri.UV1)
+	}
+	material, ok := tri.Material.(Principled)
+	if !ok {
+		t.Fatalf("material is %T, want Principled", tri.Material)
+	}
+	if got := material.BaseColorTexture.Value(0.25, 0.75, Vector3{}); got != (Vector3{0.5, 0.5, 0.5}) {
+		t.Errorf("top left of the tinted texture is %v, want 0.5 0.5 0.5", got)
+	}
+	if got := material.RoughnessTexture.Value(0.75, 0.75, Vector3{}); math.Abs(got.X-0.2) > 1e-9 {
+		t.Errorf("top right roughness is %v, want 0.4 times the roughness factor 0.5", got.X)
+	}
+	if got := material.MetallicTexture.Value(0.75, 0.75, Vector3{}); math.Abs(got.X-0.2) > 1e-9 {
+		t.Errorf("top right metalness is %v, want 0.2", got.X)
+	}
+
+	wantLight := PointLight{Position: Vector3{0, 3, -2}, Intensity: Vector3{10, 5, 2.5}}
+	if len(world.Lights) != 1 || world.Lights[0].(PointLight).Position.Subtract(wantLight.Position).Length() > 1e-6 ||
+		world.Lights[0].(PointLight).Intensity != wantLight.Intensity {
+		t.Errorf("lights are %v, want %v", world.Lights, wantLight)
+	}
+	if world.SkyColorAbove != (Vector3{}) {
+		t.Errorf("sky is %v, want black in a scene with lights", world.SkyColorAbove)
+	}
+	if ray := world.Camera.GetRay(0.5, 0.5, newIndependentSampler(1)); ray.Direction.Unit().Subtract(Vector3{0, 0, -1}).Length() > 1e-6 {
+		t.Errorf("camera looks along %v, want 0 0 -1", ray.Direction.Unit())
+	}
+
+	// The same scene as a .glb, with the buffer in the binary chunk
+	doc["buffers"] = []interface{}{map[string]int{"byteLength": len(buffer)}}
+	glbJSON, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(glbJSON)%4 != 0 {
+		glbJSON = append(glbJSON, ' ')
+	}
+	for len(buffer)%4 != 0 {
+		buffer = append(buffer, 0)
+	}
+	var glb bytes.Buffer
+	binary.Write(&glb, binary.LittleEndian, []uint32{glbMagic, 2, uint32(12 + 8 + len(glbJSON) + 8 + len(buffer))})
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(glbJSON)), glbChunkJSON})
+	glb.Write(glbJSON)
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(buffer)), glbChunkBIN})
+	glb.Write(buffer)
+	glbWorld, err := parseGLTF(glb.Bytes(), ".", 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(glbWorld, world) {
+		t.Error("the .glb world differs from the .gltf world")
+	}
+}
+
+// TestLoadGLTFEmission loads an emissive material, which keeps its base color and roughness and lights the scene
+func TestLoadGLTFEmission(t *testing.T) {
+	doc, buffer := newTestGLTF(t)
+	doc["buffers"] = []interface{}{map[string]interface{}{
+		"uri":        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buffer),
+		"byteLength": len(buffer),
+	}}
+	doc["materials"] = []interface{}{map[string]interface{}{
+		"pbrMetallicRoughness": map[string]interface{}{"baseColorFactor": []float64{0.5, 0.25, 1, 1}, "roughnessFactor": 0.5},
+		"emissiveFactor":       []float64{1, 0.5, 0},
+		"extensions":           map[string]interface{}{"KHR_materials_emissive_strength": map[string]float64{"emissiveStrength": 4}},
+	}}
+	gltfData, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := parseGLTF(gltfData, ".", 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	material, ok := world.Hittables[0].(Triangle).Material.(Principled)
+	if !ok {
+		t.Fatalf("material is %T, want Principled", world.Hittables[0].(Triangle).Material)
+	}
+	if material.BaseColor != (Vector3{0.5, 0.25, 1}) || material.Roughness != 0.5 || material.Emission != (Vector3{4, 2, 0}) {
+		t.Errorf("material has the base color %v, roughness %v and emission %v, want 0.5 0.25 1, 0.5 and 4 2 0",
+			material.BaseColor, material.Roughness, material.Emission)
+	}
+	world.Build()
+	if len(world.lights) != 2 {
+		t.Errorf("got %d area lights, want the 2 triangles of the quad", len(world.lights))
+	}
+}
+
+// TestLoadGLTFMalformed loads documents with indexes, counts and offsets that don't fit, which fail with errors
+func TestLoadGLTFMalformed(t *testing.T) {
+	accessor := func(doc map[string]interface{}, i int) map[string]interface{} {
+		return doc["accessors"].([]interface{})[i].(map[string]interface{})
+	}
+	tests := []struct {
+		name   string
+		modify func(doc map[string]interface{})
+		want   string
+	}{
+		{"negative scene", func(doc map[string]interface{}) { doc["scene"] = -1 }, "scene -1 doesn't exist"},
+		{"missing scene", func(doc map[string]interface{}) { doc["scene"] = 1 }, "scene 1 doesn't exist"},
+		{"negative count", func(doc map[string]interface{}) { accessor(doc, 0)["count"] = -1 },
+			"accessor 0 has a negative count"},
+		{"huge count", func(doc map[string]interface{}) { accessor(doc, 0)["count"] = int64(1) << 60 },
+			"accessor 0 is outside of its buffer view"},
+		{"negative accessor offset", func(doc map[string]interface{}) { accessor(doc, 0)["byteOffset"] = -12 },
+			"accessor 0 has a negative count or byte offset"},
+		{"negative view offset", func(doc map[string]interface{}) {
+			doc["bufferViews"].([]interface{})[0].(map[string]int)["byteOffset"] = -4
+		}, "buffer view 0 has a negative byte offset"},
+		{"no buffer view", func(doc map[string]interface{}) { delete(accessor(doc, 0), "bufferView") },
+			"accessor 0 has no buffer view"},
+	}
+	for _, test := range tests {
+		doc, buffer := newTestGLTF(t)
+		doc["buffers"] = []interface{}{map[string]interface{}{
+			"uri":        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buffer),
+			"byteLength": len(buffer),
+		}}
+		test.modify(doc)
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseGLTF(data, ".", 100, 100); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestLoadGLTFErrors(t *testing.T) {
+	doc, _ := newTestGLTF(t)
+	doc["buffers"] = []interface{}{map[string]interface{}{"uri": "data:application/octet-stream;base64,AAAA", "byteLength": 3}}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseGLTF(data, ".", 100, 100); err == nil || !strings.Contains(err.Error(), "outside of its buffer") {
+		t.Errorf("reading past the end of a buffer gave error %v", err)
+	}
+
+	doc["extensionsRequired"] = []string{"KHR_draco_mesh_compression"}
+	data, err = json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseGLTF(data, ".", 100, 100); err == nil || !strings.Contains(err.Error(), "KHR_draco_mesh_compression") {
+		t.Errorf("requiring an unsupported extension gave error %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package raytracer
+
+import (
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "overwrite the golden images in testdata/golden with the current renders")
+
+// goldenTolerance is the largest root mean square error between a render and its golden image,
+// with color components in [0..1]
+const goldenTolerance = 0.01
+
+func newGoldenWorldSphere(width, height float64) (World, error) {
+	position := Vector3{0, 0.5, 2}
+	lookAt := Vector3{0, 0.4, 0}
+	camera := NewCamera(position, lookAt, Vector3{0, 1, 0}, 45.0, 0.0, 1.0, width, height)
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Sphere{Position: Vector3{0, 0.5, 0}, Radius: 0.5, Material: Lambertian{Color: Vector3{0.8, 0.3, 0.3}}},
+			Sphere{Position: Vector3{0, -100, 0}, Radius: 100, Material: Lambertian{
+				Texture: CheckerTexture{Even: SolidColor{Vector3{0.2, 0.2, 0.2}}, Odd: SolidColor{Vector3{0.8, 0.8, 0.8}}, Scale: 200},
+			}},
+		},
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+func newGoldenWorldTriangle(width, height float64) (World, error) {
+	position := Vector3{0, 0.3, 2}
+	lookAt := Vector3{0, 0.3, 0}
+	camera := NewCamera(position, lookAt, Vector3{0, 1, 0}, 50.0, 0.0, 1.0, width, height)
+	normal := Vector3{0, 0, 1}
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Triangle{
+				V0: Vector3{-1, -0.5, -0.5}, V1: Vector3{1, -0.5, -0.5}, V2: Vector3{0, 1.2, -0.5},
+				N0: normal, N1: normal, N2: normal,
+				Material: Lambertian{Color: Vector3{0.3, 0.8, 0.3}},
+			},
+			Triangle{
+				V0: Vector3{-0.3, -0.2, 0}, V1: Vector3{0.3, -0.2, 0}, V2: Vector3{0, 0.3, 0.2},
+				N0: normal, N1: normal, N2: normal,
+				Material: Metal{Color: Vector3{0.9, 0.9, 0.9}, Glosiness: 0.9},
+			},
+			Sphere{Position: Vector3{0.6, 1.2, 0.8}, Radius: 0.15, Material: Light{Emission: Vector3{20, 18, 15}}},
+		},
+		SkyColorAbove: Vector3{0.3, 0.35, 0.5},
+		SkyColorBelow: Vector3{0.1, 0.1, 0.1},
+	}, nil
+}
+
+func TestGoldenImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		newWorld func(width, height float64) (World, error)
+	}{
+		{"sphere", newGoldenWorldSphere},
+		{"triangle", newGoldenWorldTriangle},
+		{"cornell_box", newTestWorldCornellBox},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Width, options.Height = 48, 48
+			options.SamplesPerPixel = 16
+			options.MaxBounces = 8
+			options.Seed = 1
+			world, err := test.newWorld(float64(options.Width), float64(options.Height))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Render(context.Background(), &world, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenPath := filepath.Join("testdata", "golden", test.name+".png")
+			if *updateGolden {
+				if err := writeTestPNG(goldenPath, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readTestPNG(goldenPath)
+			if err != nil {
+				t.Fatalf("%v, run with -update to create it", err)
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("render is %v, golden image is %v", got.Bounds(), want.Bounds())
+			}
+			rmse, diff := compareImages(got, want)
+			if rmse > goldenTolerance {
+				failurePath := filepath.Join("testdata", "failures", test.name)
+				writeTestPNG(failurePath+"_got.png", got)
+				writeTestPNG(failurePath+"_diff.png", diff)
+				t.Errorf("render differs from %s with an RMSE of %.4f, more than %v, see %s_*.png",
+					goldenPath, rmse, goldenTolerance, failurePath)
+			}
+		})
+	}
+}
+
+// compareImages returns the root mean square error between the images and an image of their differences,
+// amplified so they're easy to spot
+func compareImages(a, b image.Image) (float64, *image.RGBA) {
+	bounds := a.Bounds()
+	diff := image.NewRGBA(bounds)
+	sumSquared := 0.0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r0, g0, b0, _ := a.At(x, y).RGBA()
+			r1, g1, b1, _ := b.At(x, y).RGBA()
+			d := Vector3{
+				X: (float64(r0) - float64(r1)) / 0xffff,
+				Y: (float64(g0) - float64(g1)) / 0xffff,
+				Z: (float64(b0) - float64(b1)) / 0xffff,
+			}
+			sumSquared += d.LengthSquared()
+			diff.Set(x, y, color.RGBA{
+				R: uint8(Clamp(math.Abs(d.X)*4, 0, 1) * 255),
+				G: uint8(Clamp(math.Abs(d.Y)*4, 0, 1) * 255),
+				B: uint8(Clamp(math.Abs(d.Z)*4, 0, 1) * 255),
+				A: 255,
+			})
+		}
+	}
+	return math.Sqrt(sumSquared / float64(3*bounds.Dx()*bounds.Dy())), diff
+}
+
+func readTestPNG(filePath string) (image.Image, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writeTestPNG(filePath string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0775); err != nil {
+		return err
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
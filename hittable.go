@@ -1,4 +1,4 @@
-package main
+package raytracer
 
 import (
 	"math"
@@ -7,14 +7,24 @@ import (
 // Hittable is an object that can be hit by a Ray
 type Hittable interface {
 	Hit(Ray, float64, float64) (*HitRecord, bool)
+	BoundingBox() AABB
 }
 
 // HitRecord holds information of a Ray hitting a Hittable object
+// U and V are the texture coordinates of the hit point
 type HitRecord struct {
 	Point, Normal Vector3
 	T             float64
+	U, V          float64
 	IsFrontFace   bool
 	Material      Material
+
+	// light is set if the hit is on a light that's sampled directly
+	light *areaLight
+	// medium is set if the hit is on the boundary of a Volume filled with it
+	medium *Medium
+	// objectID is the ID of the object hit and materialID the index of its material, see World.Build
+	objectID, materialID int
 }
 
 // NewHitRecord initializes a new HitRecord and returns it
@@ -62,14 +72,36 @@ func (s Sphere) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
 	hitPoint := r.At(root)
 	normal := hitPoint.Subtract(s.Position).Scale(1.0 / s.Radius)
 	hitRecord := NewHitRecord(hitPoint, normal, r, root, s.Material)
+	hitRecord.U, hitRecord.V = sphereUV(normal)
 	return &hitRecord, true
 }
 
+// sphereUV returns the texture coordinates of a point on the unit sphere,
+// u goes around the Y axis starting from -X and v goes from -Y to +Y
+// Source: https://raytracing.github.io/books/RayTracingTheNextWeek.html#texturemapping
+func sphereUV(p Vector3) (float64, float64) {
+	theta := math.Acos(Clamp(-p.Y, -1, 1))
+	phi := math.Atan2(-p.Z, p.X) + math.Pi
+	return phi / (2.0 * math.Pi), theta / math.Pi
+}
+
+// BoundingBox returns the box enclosing the sphere
+func (s Sphere) BoundingBox() AABB {
+	radius := Vector3{s.Radius, s.Radius, s.Radius}
+	return AABB{
+		Min: s.Position.Subtract(radius),
+		Max: s.Position.Add(radius),
+	}
+}
+
 // Triangle is a Hittable object
 type Triangle struct {
 	V0, V1, V2 Vector3
 	N0, N1, N2 Vector3
-	Material   Material
+	// UV0, UV1 and UV2 are the texture coordinates of the vertices in X and Y,
+	// if they're all zero the barycentric coordinates of the hit are used instead
+	UV0, UV1, UV2 Vector3
+	Material      Material
 }
 
 // Hit returns the record of the hit if hit and a boolean denoting if the object was hit
@@ -95,7 +127,7 @@ func (tri Triangle) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
 		return nil, false
 	}
 	t := f * edge2.Dot(q)
-	if t > epsilon {
+	if t > epsilon && t >= tMin && t <= tMax {
 		// normal := edge1.Cross(edge2).Unit()
 		normal := tri.N0.Scale(1.0 - u - v).
 			Add(tri.N1.Scale(u)).
@@ -103,7 +135,22 @@ func (tri Triangle) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
 			Unit()
 		hitPoint := r.At(t)
 		hitRecord := NewHitRecord(hitPoint, normal, r, t, tri.Material)
+		hitRecord.U, hitRecord.V = u, v
+		if !tri.UV0.IsNearZero() || !tri.UV1.IsNearZero() || !tri.UV2.IsNearZero() {
+			uv := tri.UV0.Scale(1.0 - u - v).
+				Add(tri.UV1.Scale(u)).
+				Add(tri.UV2.Scale(v))
+			hitRecord.U, hitRecord.V = uv.X, uv.Y
+		}
 		return &hitRecord, true
 	}
 	return nil, false
 }
+
+// BoundingBox returns the box enclosing the triangle
+func (tri Triangle) BoundingBox() AABB {
+	return AABB{Min: tri.V0, Max: tri.V0}.
+		Extend(tri.V1).
+		Extend(tri.V2).
+		pad(0.0001)
+}
@@ -0,0 +1,408 @@
+package raytracer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteRadianceHDR writes the image in the Radiance .hdr format, with uncompressed RGBE pixels
+// Source: https://www.graphics.cornell.edu/~bjw/rgbe.html
+func WriteRadianceHDR(w io.Writer, img *FloatImage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", img.Height, img.Width)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			rgbe := toRGBE(img.At(x, y))
+			bw.Write(rgbe[:])
+		}
+	}
+	return bw.Flush()
+}
+
+// toRGBE encodes a color as 3 mantissas sharing an exponent, negative components are clamped to 0
+func toRGBE(c Vector3) [4]byte {
+	r, g, b := math.Max(c.X, 0), math.Max(c.Y, 0), math.Max(c.Z, 0)
+	v := math.Max(r, math.Max(g, b))
+	if v < 1e-32 {
+		return [4]byte{0, 0, 0, 0}
+	}
+	mantissa, exponent := math.Frexp(v)
+	scale := mantissa * 256.0 / v
+	return [4]byte{byte(r * scale), byte(g * scale), byte(b * scale), byte(exponent + 128)}
+}
+
+// WritePFM writes the image in the Portable Float Map format, little endian and with 3 channels
+// Source: http://www.pauldebevec.com/Research/HDR/PFM/
+func WritePFM(w io.Writer, img *FloatImage) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", img.Width, img.Height)
+	// scanlines go from the bottom to the top
+	for y := img.Height - 1; y >= 0; y-- {
+		row := img.Pix[3*y*img.Width : 3*(y+1)*img.Width]
+		if err := binary.Write(bw, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadFloatImage reads a Radiance .hdr or a .pfm image, picked by the file's extension
+func ReadFloatImage(filePath string) (*FloatImage, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var img *FloatImage
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".hdr":
+		img, err = ReadRadianceHDR(file)
+	case ".pfm":
+		img, err = ReadPFM(file)
+	default:
+		return nil, fmt.Errorf("%s: unsupported image format, expected .hdr or .pfm", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return img, nil
+}
+
+// ReadRadianceHDR reads a Radiance .hdr image with flat or run length encoded RGBE pixels,
+// stored from the top row down
+func ReadRadianceHDR(r io.Reader) (*FloatImage, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(magic, "#?") {
+		return nil, fmt.Errorf("not a Radiance .hdr file")
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported pixel format %q", strings.TrimPrefix(line, "FORMAT="))
+		}
+	}
+	var width, height int
+	if _, err := fmt.Fscanf(br, "-Y %d +X %d\n", &height, &width); err != nil {
+		return nil, fmt.Errorf("unsupported resolution, only -Y height +X width is: %w", err)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	img := NewFloatImage(width, height)
+	scanline := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		if err := readRGBEScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("row %d: %w", y, err)
+		}
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fromRGBE(scanline[4*x:4*x+4]))
+		}
+	}
+	return img, nil
+}
+
+// readRGBEScanline reads a row of pixels into scanline as R, G, B and E bytes,
+// run length encoded rows store each component separately
+func readRGBEScanline(br *bufio.Reader, scanline []byte) error {
+	width := len(scanline) / 4
+	if _, err := io.ReadFull(br, scanline[:4]); err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || scanline[0] != 2 || scanline[1] != 2 || scanline[2]&0x80 != 0 {
+		_, err := io.ReadFull(br, scanline[4:])
+		return err
+	}
+	if int(scanline[2])<<8|int(scanline[3]) != width {
+		return fmt.Errorf("run length encoded row has the wrong width")
+	}
+	component := make([]byte, width)
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				// a run of the same value
+				count -= 128
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+int(count) > width {
+					return fmt.Errorf("run goes past the end of the row")
+				}
+				for i := 0; i < int(count); i++ {
+					component[x+i] = value
+				}
+			} else {
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("invalid run length %d", count)
+				}
+				if _, err := io.ReadFull(br, component[x:x+int(count)]); err != nil {
+					return err
+				}
+			}
+			x += int(count)
+		}
+		for x := 0; x < width; x++ {
+			scanline[4*x+c] = component[x]
+		}
+	}
+	return nil
+}
+
+// fromRGBE decodes 3 mantissas sharing an exponent into a color
+func fromRGBE(rgbe []byte) Vector3 {
+	if rgbe[3] == 0 {
+		return Vector3{0, 0, 0}
+	}
+	scale := math.Ldexp(1.0, int(rgbe[3])-(128+8))
+	return Vector3{(float64(rgbe[0]) + 0.5) * scale, (float64(rgbe[1]) + 0.5) * scale, (float64(rgbe[2]) + 0.5) * scale}
+}
+
+// ReadPFM reads a color (PF) or grayscale (Pf) Portable Float Map, a negative scale means little endian pixels
+func ReadPFM(r io.Reader) (*FloatImage, error) {
+	br := bufio.NewReader(r)
+	var magic string
+	var width, height int
+	var scale float64
+	if _, err := fmt.Fscan(br, &magic, &width, &height, &scale); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	channels := 3
+	switch magic {
+	case "PF":
+	case "Pf":
+		channels = 1
+	default:
+		return nil, fmt.Errorf("not a .pfm file")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	// a single whitespace character separates the header from the pixels
+	if _, err := br.ReadByte(); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	img := NewFloatImage(width, height)
+	row := make([]float32, channels*width)
+	// scanlines go from the bottom to the top
+	for y := height - 1; y >= 0; y-- {
+		if err := binary.Read(br, order, row); err != nil {
+			return nil, fmt.Errorf("row %d: %w", y, err)
+		}
+		for x := 0; x < width; x++ {
+			if channels == 1 {
+				v := float64(row[x])
+				img.Set(x, y, Vector3{v, v, v})
+			} else {
+				img.Set(x, y, Vector3{float64(row[3*x]), float64(row[3*x+1]), float64(row[3*x+2])})
+			}
+		}
+	}
+	return img, nil
+}
+
+// EXRCompression is the compression used for the pixels of an OpenEXR file
+type EXRCompression byte
+
+// The supported OpenEXR compressions
+const (
+	EXRNoCompression  EXRCompression = 0
+	EXRZIPCompression EXRCompression = 3
+)
+
+// exrChannel is a named channel of 32 bit float pixels, row by row from the top
+type exrChannel struct {
+	name   string
+	pixels []float32
+}
+
+// WriteEXR writes the image as a single part, scanline OpenEXR file with 32 bit float R, G and B channels
+// Source: https://openexr.com/en/latest/OpenEXRFileLayout.html
+func WriteEXR(w io.Writer, img *FloatImage, compression EXRCompression) error {
+	return writeEXRChannels(w, img.Width, img.Height, exrImageChannels("", img, false), compression)
+}
+
+// WriteEXRLayers writes the image like WriteEXR with a layer for every pass, which have to be the same size as the image
+// The channels of a layer are named after its pass, like normal.R, normal.G and normal.B, single valued passes only have
+// a Y channel like depth.Y
+func WriteEXRLayers(w io.Writer, img *FloatImage, aovs map[AOV]*FloatImage, compression EXRCompression) error {
+	channels := exrImageChannels("", img, false)
+	for aov, layer := range aovs {
+		if layer.Width != img.Width || layer.Height != img.Height {
+			return fmt.Errorf("the %v layer is %dx%d, the image %dx%d", aov, layer.Width, layer.Height, img.Width, img.Height)
+		}
+		channels = append(channels, exrImageChannels(aov.String()+".", layer, aov.SingleValued())...)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].name < channels[j].name })
+	return writeEXRChannels(w, img.Width, img.Height, channels, compression)
+}
+
+// exrImageChannels returns the B, G and R channels of the image, or only a Y channel with its red component if gray,
+// with their names prefixed
+func exrImageChannels(prefix string, img *FloatImage, gray bool) []exrChannel {
+	channels := []exrChannel{{name: prefix + "B"}, {name: prefix + "G"}, {name: prefix + "R"}}
+	if gray {
+		channels = []exrChannel{{name: prefix + "Y"}}
+	}
+	for c := range channels {
+		// B, G and R are the components 2, 1 and 0, Y is the first
+		component := 2 - c
+		if gray {
+			component = 0
+		}
+		channels[c].pixels = make([]float32, img.Width*img.Height)
+		for i := range channels[c].pixels {
+			channels[c].pixels[i] = img.Pix[3*i+component]
+		}
+	}
+	return channels
+}
+
+// writeEXRChannels writes the channels, which have to be sorted by name, into an OpenEXR file
+func writeEXRChannels(w io.Writer, width, height int, channels []exrChannel, compression EXRCompression) error {
+	linesPerChunk := 1
+	switch compression {
+	case EXRNoCompression:
+	case EXRZIPCompression:
+		linesPerChunk = 16
+	default:
+		return fmt.Errorf("unsupported EXR compression %d", compression)
+	}
+
+	var header bytes.Buffer
+	header.Write([]byte{0x76, 0x2f, 0x31, 0x01})
+	binary.Write(&header, binary.LittleEndian, uint32(2))
+
+	var channelList bytes.Buffer
+	for _, c := range channels {
+		channelList.WriteString(c.name)
+		channelList.WriteByte(0)
+		binary.Write(&channelList, binary.LittleEndian, int32(2)) // FLOAT
+		channelList.Write([]byte{0, 0, 0, 0})                     // pLinear and reserved
+		binary.Write(&channelList, binary.LittleEndian, [2]int32{1, 1})
+	}
+	channelList.WriteByte(0)
+
+	window := [4]int32{0, 0, int32(width - 1), int32(height - 1)}
+	writeEXRAttribute(&header, "channels", "chlist", channelList.Bytes())
+	writeEXRAttribute(&header, "compression", "compression", []byte{byte(compression)})
+	writeEXRAttribute(&header, "dataWindow", "box2i", window)
+	writeEXRAttribute(&header, "displayWindow", "box2i", window)
+	writeEXRAttribute(&header, "lineOrder", "lineOrder", []byte{0}) // INCREASING_Y
+	writeEXRAttribute(&header, "pixelAspectRatio", "float", float32(1))
+	writeEXRAttribute(&header, "screenWindowCenter", "v2f", [2]float32{0, 0})
+	writeEXRAttribute(&header, "screenWindowWidth", "float", float32(1))
+	header.WriteByte(0)
+
+	var chunks [][]byte
+	for firstLine := 0; firstLine < height; firstLine += linesPerChunk {
+		lastLine := firstLine + linesPerChunk
+		if lastLine > height {
+			lastLine = height
+		}
+		var raw bytes.Buffer
+		for y := firstLine; y < lastLine; y++ {
+			for _, c := range channels {
+				binary.Write(&raw, binary.LittleEndian, c.pixels[y*width:(y+1)*width])
+			}
+		}
+		data := raw.Bytes()
+		if compression == EXRZIPCompression {
+			compressed, err := exrZIPCompress(data)
+			if err != nil {
+				return err
+			}
+			// chunks that don't get smaller are stored uncompressed
+			if len(compressed) < len(data) {
+				data = compressed
+			}
+		}
+		var chunk bytes.Buffer
+		binary.Write(&chunk, binary.LittleEndian, [2]int32{int32(firstLine), int32(len(data))})
+		chunk.Write(data)
+		chunks = append(chunks, chunk.Bytes())
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header.Bytes())
+	offset := uint64(header.Len() + 8*len(chunks))
+	for _, chunk := range chunks {
+		binary.Write(bw, binary.LittleEndian, offset)
+		offset += uint64(len(chunk))
+	}
+	for _, chunk := range chunks {
+		bw.Write(chunk)
+	}
+	return bw.Flush()
+}
+
+func writeEXRAttribute(header *bytes.Buffer, name, attributeType string, value interface{}) {
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, value)
+	header.WriteString(name)
+	header.WriteByte(0)
+	header.WriteString(attributeType)
+	header.WriteByte(0)
+	binary.Write(header, binary.LittleEndian, int32(data.Len()))
+	header.Write(data.Bytes())
+}
+
+// exrZIPCompress splits the bytes into two interleaved halves, delta encodes them and deflates the result
+// Source: https://github.com/AcademySoftwareFoundation/openexr/blob/main/src/lib/OpenEXR/ImfZip.cpp
+func exrZIPCompress(raw []byte) ([]byte, error) {
+	reordered := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			reordered[i/2] = b
+		} else {
+			reordered[half+i/2] = b
+		}
+	}
+	previous := int(reordered[0])
+	for i := 1; i < len(reordered); i++ {
+		current := int(reordered[i])
+		reordered[i] = byte(current - previous + 128 + 256)
+		previous = current
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(reordered); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
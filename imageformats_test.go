@@ -0,0 +1,324 @@
+package raytracer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newGradientImage(width, height int) *FloatImage {
+	img := NewFloatImage(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, Vector3{float64(x) * 0.5, float64(y) * 2.0, float64(x+y) / 100.0})
+		}
+	}
+	return img
+}
+
+func TestWritePFM(t *testing.T) {
+	img := newGradientImage(5, 3)
+	var buf bytes.Buffer
+	if err := WritePFM(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(&buf)
+	var width, height int
+	var scale float64
+	if _, err := fmt.Fscanf(reader, "PF\n%d %d\n%f\n", &width, &height, &scale); err != nil {
+		t.Fatal(err)
+	}
+	if width != 5 || height != 3 || scale != -1.0 {
+		t.Fatalf("header is %d %d %v", width, height, scale)
+	}
+	pixels := make([]float32, 3*width*height)
+	if err := binary.Read(reader, binary.LittleEndian, pixels); err != nil {
+		t.Fatal(err)
+	}
+	// the first row in the file is the bottom one
+	if got, want := pixels[3*1], img.Pix[3*(2*width+1)]; got != want {
+		t.Errorf("red of the bottom row's second pixel is %v, want %v", got, want)
+	}
+}
+
+func TestWriteRadianceHDR(t *testing.T) {
+	img := newGradientImage(4, 2)
+	var buf bytes.Buffer
+	if err := WriteRadianceHDR(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 2 +X 4\n"
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("unexpected header %q", data[:len(header)])
+	}
+	pixels := data[len(header):]
+	if len(pixels) != 4*4*2 {
+		t.Fatalf("got %d bytes of pixels, want %d", len(pixels), 4*4*2)
+	}
+	for i := 0; i < 8; i++ {
+		rgbe := pixels[4*i : 4*i+4]
+		want := img.At(i%4, i/4)
+		scale := math.Ldexp(1.0, int(rgbe[3])-(128+8))
+		got := Vector3{float64(rgbe[0]) * scale, float64(rgbe[1]) * scale, float64(rgbe[2]) * scale}
+		if rgbe[3] != 0 && got.Subtract(want).Length() > want.Length()/64 {
+			t.Errorf("pixel %d decodes to %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWriteEXR(t *testing.T) {
+	img := newGradientImage(7, 37)
+	for _, compression := range []EXRCompression{EXRNoCompression, EXRZIPCompression} {
+		var buf bytes.Buffer
+		if err := WriteEXR(&buf, img, compression); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readTestEXR(buf.Bytes(), img.Width, img.Height)
+		if err != nil {
+			t.Fatalf("compression %d: %v", compression, err)
+		}
+		for i := range img.Pix {
+			if got.Pix[i] != img.Pix[i] {
+				t.Fatalf("compression %d: component %d is %v, want %v", compression, i, got.Pix[i], img.Pix[i])
+			}
+		}
+	}
+}
+
+func TestWriteEXRLayers(t *testing.T) {
+	img := newGradientImage(7, 37)
+	normal, depth := newGradientImage(7, 37), NewFloatImage(7, 37)
+	for i := range normal.Pix {
+		normal.Pix[i] = -normal.Pix[i]
+	}
+	for y := 0; y < depth.Height; y++ {
+		for x := 0; x < depth.Width; x++ {
+			d := float64(y*depth.Width + x)
+			depth.Set(x, y, Vector3{d, d, d})
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteEXRLayers(&buf, img, map[AOV]*FloatImage{AOVNormal: normal, AOVDepth: depth}, EXRZIPCompression); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := readTestEXRChannels(buf.Bytes(), img.Width, img.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"B", "G", "R", "depth.Y", "normal.B", "normal.G", "normal.R"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("the file has the channels %v, want %v", names, want)
+	}
+	for i := 0; i < img.Width*img.Height; i++ {
+		if channels["G"][i] != img.Pix[3*i+1] || channels["normal.R"][i] != normal.Pix[3*i] || channels["depth.Y"][i] != float32(i) {
+			t.Fatalf("pixel %d is %v, %v and %v", i, channels["G"][i], channels["normal.R"][i], channels["depth.Y"][i])
+		}
+	}
+
+	if err := WriteEXRLayers(&buf, img, map[AOV]*FloatImage{AOVAlbedo: NewFloatImage(3, 3)}, EXRZIPCompression); err == nil {
+		t.Error("a layer of another size was written")
+	}
+}
+
+// readTestEXR decodes the EXR files written by WriteEXR, following the OpenEXR file layout
+func readTestEXR(data []byte, width, height int) (*FloatImage, error) {
+	channels, err := readTestEXRChannels(data, width, height)
+	if err != nil {
+		return nil, err
+	}
+	img := NewFloatImage(width, height)
+	for c, name := range []string{"R", "G", "B"} {
+		for i, value := range channels[name] {
+			img.Pix[3*i+c] = value
+		}
+	}
+	return img, nil
+}
+
+// readTestEXRChannels decodes the channels of the EXR files written by WriteEXR and WriteEXRLayers by their names
+func readTestEXRChannels(data []byte, width, height int) (map[string][]float32, error) {
+	reader := bytes.NewReader(data)
+	var magic, version uint32
+	binary.Read(reader, binary.LittleEndian, &magic)
+	binary.Read(reader, binary.LittleEndian, &version)
+	if magic != 20000630 || version != 2 {
+		return nil, fmt.Errorf("bad magic %v or version %v", magic, version)
+	}
+
+	readString := func() string {
+		var s []byte
+		for {
+			b, _ := reader.ReadByte()
+			if b == 0 {
+				return string(s)
+			}
+			s = append(s, b)
+		}
+	}
+	compression := byte(0)
+	var names []string
+	for {
+		name := readString()
+		if name == "" {
+			break
+		}
+		readString()
+		var size int32
+		binary.Read(reader, binary.LittleEndian, &size)
+		value := make([]byte, size)
+		reader.Read(value)
+		if name == "compression" {
+			compression = value[0]
+		}
+		if name == "channels" {
+			// every channel is its name and 16 bytes of its type and sampling, the list ends with a 0
+			for i := 0; value[i] != 0; i += 16 {
+				end := bytes.IndexByte(value[i:], 0)
+				names = append(names, string(value[i:i+end]))
+				i += end + 1
+			}
+		}
+	}
+
+	linesPerChunk := 1
+	if compression == byte(EXRZIPCompression) {
+		linesPerChunk = 16
+	}
+	chunkCount := (height + linesPerChunk - 1) / linesPerChunk
+	offsets := make([]uint64, chunkCount)
+	binary.Read(reader, binary.LittleEndian, offsets)
+
+	channels := make(map[string][]float32, len(names))
+	for _, name := range names {
+		channels[name] = make([]float32, width*height)
+	}
+	for _, offset := range offsets {
+		chunk := bytes.NewReader(data[offset:])
+		var firstLine, size int32
+		binary.Read(chunk, binary.LittleEndian, &firstLine)
+		binary.Read(chunk, binary.LittleEndian, &size)
+		pixelData := make([]byte, size)
+		chunk.Read(pixelData)
+
+		lines := linesPerChunk
+		if int(firstLine)+lines > height {
+			lines = height - int(firstLine)
+		}
+		rawSize := lines * width * len(names) * 4
+		if int(size) < rawSize {
+			zr, err := zlib.NewReader(bytes.NewReader(pixelData))
+			if err != nil {
+				return nil, err
+			}
+			predicted, err := ioutil.ReadAll(zr)
+			if err != nil {
+				return nil, err
+			}
+			for i := 1; i < len(predicted); i++ {
+				predicted[i] = byte(int(predicted[i-1]) + int(predicted[i]) - 128)
+			}
+			half := (len(predicted) + 1) / 2
+			pixelData = make([]byte, len(predicted))
+			for i := range pixelData {
+				if i%2 == 0 {
+					pixelData[i] = predicted[i/2]
+				} else {
+					pixelData[i] = predicted[half+i/2]
+				}
+			}
+		}
+
+		values := make([]float32, rawSize/4)
+		binary.Read(bytes.NewReader(pixelData), binary.LittleEndian, values)
+		for line := 0; line < lines; line++ {
+			for c, name := range names {
+				for x := 0; x < width; x++ {
+					channels[name][(int(firstLine)+line)*width+x] = values[(line*len(names)+c)*width+x]
+				}
+			}
+		}
+	}
+	return channels, nil
+}
+
+func TestReadPFM(t *testing.T) {
+	img := newGradientImage(5, 3)
+	var buf bytes.Buffer
+	if err := WritePFM(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPFM(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != img.Width || got.Height != img.Height || !reflect.DeepEqual(got.Pix, img.Pix) {
+		t.Errorf("read %dx%d image %v, want %v", got.Width, got.Height, got.Pix, img.Pix)
+	}
+
+	// A big endian grayscale image, the bottom row first
+	var gray bytes.Buffer
+	gray.WriteString("Pf\n2 2\n1.0\n")
+	binary.Write(&gray, binary.BigEndian, []float32{1, 2, 3, 4})
+	got, err = ReadPFM(&gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := got.At(1, 0); top != (Vector3{4, 4, 4}) {
+		t.Errorf("top right pixel of the grayscale image is %v, want 4 4 4", top)
+	}
+}
+
+func TestReadRadianceHDR(t *testing.T) {
+	img := newGradientImage(4, 2)
+	var buf bytes.Buffer
+	if err := WriteRadianceHDR(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRadianceHDR(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			if want := img.At(x, y); got.At(x, y).Subtract(want).Length() > want.Length()/64+1e-30 {
+				t.Errorf("pixel %d %d is %v, want %v", x, y, got.At(x, y), want)
+			}
+		}
+	}
+
+	// A run length encoded row of 8 pixels: a run of 8 for red, 4 literals and a run of 4 for green,
+	// a run of 8 for blue and the exponent
+	var rle bytes.Buffer
+	rle.WriteString("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 8\n")
+	rle.Write([]byte{2, 2, 0, 8})
+	rle.Write([]byte{128 + 8, 128})
+	rle.Write([]byte{4, 0, 64, 128, 255, 128 + 4, 32})
+	rle.Write([]byte{128 + 8, 0})
+	rle.Write([]byte{128 + 8, 129})
+	got, err = ReadRadianceHDR(&rle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Vector3{1.0 + 1.0/256, 0.5 + 1.0/256, 1.0 / 256}); got.At(1, 0) != want {
+		t.Errorf("second pixel is %v, want %v", got.At(1, 0), want)
+	}
+	if want := (Vector3{1.0 + 1.0/256, 0.25 + 1.0/256, 1.0 / 256}); got.At(7, 0) != want {
+		t.Errorf("last pixel is %v, want %v", got.At(7, 0), want)
+	}
+
+	if _, err := ReadRadianceHDR(bytes.NewReader([]byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x00\x00\x00\x00"))); err == nil {
+		t.Error("reading an XYZE image didn't fail")
+	}
+}
@@ -0,0 +1,67 @@
+package raytracer
+
+import (
+	"fmt"
+)
+
+// Instance is a Hittable placed in the world with a transform, so one mesh can be shared by many copies
+// Wrap meshes in a BVHNode first, the Instance only has a single bounding box
+// Lights inside an Instance aren't sampled directly, they only light the world when rays hit them
+type Instance struct {
+	object                           Hittable
+	transform, inverse, normalMatrix Matrix4
+	box                              AABB
+}
+
+// NewInstance returns the object transformed by transform, fails if the transform can't be inverted
+func NewInstance(object Hittable, transform Matrix4) (Instance, error) {
+	inverse, ok := transform.Inverse()
+	if !ok {
+		return Instance{}, fmt.Errorf("instance transform can't be inverted")
+	}
+
+	box := EmptyAABB()
+	objectBox := object.BoundingBox()
+	if objectBox.Min.X <= objectBox.Max.X {
+		for corner := 0; corner < 8; corner++ {
+			p := objectBox.Min
+			for axis := 0; axis < 3; axis++ {
+				if corner&(1<<axis) != 0 {
+					p = p.setAxis(axis, objectBox.Max.Axis(axis))
+				}
+			}
+			box = box.Extend(transform.TransformPoint(p))
+		}
+	}
+
+	return Instance{
+		object:       object,
+		transform:    transform,
+		inverse:      inverse,
+		normalMatrix: inverse.Transpose(),
+		box:          box,
+	}, nil
+}
+
+// Hit transforms the ray into the object's space and the hit back out of it
+// The direction isn't normalized, so t is the same in both spaces
+func (i Instance) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	objectRay := Ray{
+		Origin:    i.inverse.TransformPoint(r.Origin),
+		Direction: i.inverse.TransformDirection(r.Direction),
+	}
+	hitRecord, hit := i.object.Hit(objectRay, tMin, tMax)
+	if !hit {
+		return nil, false
+	}
+	hitRecord.Point = r.At(hitRecord.T)
+	// the normal keeps facing against the ray, the transformed normal and ray have the same dot product sign
+	hitRecord.Normal = i.normalMatrix.TransformDirection(hitRecord.Normal).Unit()
+	hitRecord.light = nil
+	return hitRecord, true
+}
+
+// BoundingBox returns the box enclosing the transformed box of the object
+func (i Instance) BoundingBox() AABB {
+	return i.box
+}
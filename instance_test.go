@@ -0,0 +1,56 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInstance(t *testing.T) {
+	// A unit sphere stretched along X into an ellipsoid and moved 5 units down -Z
+	sphere := Sphere{Radius: 1, Material: Lambertian{}}
+	instance, err := NewInstance(sphere, TranslationMatrix(Vector3{0, 0, -5}).Multiply(ScaleMatrix(Vector3{2, 1, 1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	box := instance.BoundingBox()
+	if box.Min.Subtract(Vector3{-2, -1, -6}).Length() > 1e-9 || box.Max.Subtract(Vector3{2, 1, -4}).Length() > 1e-9 {
+		t.Errorf("bounding box is %v, want -2 -1 -6 to 2 1 -4", box)
+	}
+
+	tests := []struct {
+		ray         Ray
+		t           float64
+		point       Vector3
+		normal      Vector3
+		isFrontFace bool
+	}{
+		{Ray{Vector3{0, 0, 0}, Vector3{0, 0, -1}}, 4, Vector3{0, 0, -4}, Vector3{0, 0, 1}, true},
+		{Ray{Vector3{-10, 0, -5}, Vector3{1, 0, 0}}, 8, Vector3{-2, 0, -5}, Vector3{-1, 0, 0}, true},
+		// The normal of the ellipsoid x²/4 + y² + z² = 1 at x = 1 is its gradient x/2, 2y, 2z
+		{Ray{Vector3{1, 10, -5}, Vector3{0, -2, 0}}, (10 - math.Sqrt(0.75)) / 2, Vector3{1, math.Sqrt(0.75), -5},
+			Vector3{0.5, 2 * math.Sqrt(0.75), 0}.Unit(), true},
+		// From the inside the normal faces against the ray
+		{Ray{Vector3{0, 0, -5}, Vector3{1, 0, 0}}, 2, Vector3{2, 0, -5}, Vector3{-1, 0, 0}, false},
+	}
+	for i, test := range tests {
+		hitRecord, hit := instance.Hit(test.ray, 0.001, math.Inf(1))
+		if !hit {
+			t.Errorf("ray %d missed", i)
+			continue
+		}
+		if math.Abs(hitRecord.T-test.t) > 1e-9 || hitRecord.Point.Subtract(test.point).Length() > 1e-9 ||
+			hitRecord.Normal.Subtract(test.normal).Length() > 1e-9 || hitRecord.IsFrontFace != test.isFrontFace {
+			t.Errorf("ray %d hit at t %v, %v with normal %v and front face %v, want t %v, %v with normal %v and front face %v",
+				i, hitRecord.T, hitRecord.Point, hitRecord.Normal, hitRecord.IsFrontFace,
+				test.t, test.point, test.normal, test.isFrontFace)
+		}
+	}
+	if _, hit := instance.Hit(Ray{Vector3{0, 1.5, 0}, Vector3{0, 0, -1}}, 0.001, math.Inf(1)); hit {
+		t.Error("ray above the ellipsoid hit it")
+	}
+
+	if _, err := NewInstance(sphere, ScaleMatrix(Vector3{1, 0, 1})); err == nil {
+		t.Error("flattening transform made an instance")
+	}
+}
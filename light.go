@@ -0,0 +1,338 @@
+package raytracer
+
+import (
+	"math"
+	"sort"
+)
+
+// shadowEpsilon shortens shadow rays so they don't hit the light they're aimed at
+const shadowEpsilon = 1e-4
+
+// lightShape is a Hittable whose surface can be sampled, so it can be used as a light source
+type lightShape interface {
+	Hittable
+	// sampleDirection returns a unit direction from origin towards a random point on the shape,
+	// the distance to that point and the pdf of choosing the direction per unit solid angle
+	sampleDirection(origin Vector3, sampler Sampler) (Vector3, float64, float64)
+	// directionPDF returns the pdf of sampleDirection choosing the direction that hit the shape at h
+	directionPDF(origin Vector3, h *HitRecord) float64
+	area() float64
+}
+
+// areaLight wraps a Hittable with an emissive material, hits on it are marked so rayColor
+// can weigh them against direct light samples
+type areaLight struct {
+	shape        lightShape
+	emission     Vector3
+	selectionPDF float64
+}
+
+// Hit returns the shape's hit, marked as a hit on this light
+func (l *areaLight) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	hitRecord, hit := l.shape.Hit(r, tMin, tMax)
+	if hit {
+		hitRecord.light = l
+	}
+	return hitRecord, hit
+}
+
+// BoundingBox returns the box enclosing the light's shape
+func (l *areaLight) BoundingBox() AABB {
+	return l.shape.BoundingBox()
+}
+
+// pdf returns the pdf of sampling the direction that hit this light at h with World.sampleLight
+func (l *areaLight) pdf(origin Vector3, h *HitRecord) float64 {
+	return l.selectionPDF * l.shape.directionPDF(origin, h)
+}
+
+// buildLights collects the Hittables with an emissive material and wraps them in areaLights,
+// returns the Hittables to put into the BVH
+func (w *World) buildLights() []Hittable {
+	hittables := make([]Hittable, len(w.Hittables))
+	copy(hittables, w.Hittables)
+	w.lights = nil
+	w.deltaLights = nil
+	w.lightCDF = nil
+
+	// lights are picked proportionally to the power they emit
+	totalPower := 0.0
+	for i, h := range hittables {
+		shape, ok := h.(lightShape)
+		if !ok {
+			continue
+		}
+		var material Material
+		switch s := shape.(type) {
+		case Triangle:
+			material = s.Material
+		case Sphere:
+			material = s.Material
+		}
+		e, ok := material.(emitter)
+		if !ok || e.emission().Luminance() <= 0 {
+			continue
+		}
+		l := &areaLight{shape: shape, emission: e.emission()}
+		hittables[i] = l
+		w.lights = append(w.lights, l)
+		totalPower += l.power()
+		w.lightCDF = append(w.lightCDF, totalPower)
+	}
+
+	worldRadius := 0.0
+	if len(w.Lights) > 0 || w.Environment != nil {
+		worldRadius = 0.5 * hittablesBox(hittables).diagonal()
+	}
+	for _, l := range w.Lights {
+		power := l.power(worldRadius)
+		if power <= 0 {
+			continue
+		}
+		w.deltaLights = append(w.deltaLights, l)
+		totalPower += power
+		w.lightCDF = append(w.lightCDF, totalPower)
+	}
+	w.environmentSelectionPDF = 0
+	environmentPower := 0.0
+	if w.Environment != nil {
+		environmentPower = w.Environment.power(worldRadius)
+	}
+	if environmentPower > 0 && !math.IsInf(environmentPower, 0) {
+		totalPower += environmentPower
+		w.lightCDF = append(w.lightCDF, totalPower)
+		w.environmentSelectionPDF = environmentPower / totalPower
+	}
+
+	for i, l := range w.lights {
+		l.selectionPDF = l.power() / totalPower
+		w.lightCDF[i] /= totalPower
+	}
+	for i := len(w.lights); i < len(w.lightCDF); i++ {
+		w.lightCDF[i] /= totalPower
+	}
+	return hittables
+}
+
+// power returns the power emitted from one side of the light
+func (l *areaLight) power() float64 {
+	return math.Pi * l.shape.area() * l.emission.Luminance()
+}
+
+// hittablesBox returns the box enclosing all of the hittables
+func hittablesBox(hittables []Hittable) AABB {
+	box := EmptyAABB()
+	for _, h := range hittables {
+		box = box.Union(h.BoundingBox())
+	}
+	return box
+}
+
+// sampleLight returns the light arriving at the hit from a randomly picked light, scattered by the BSDF
+// and weighted with the power heuristic against the BSDF sampling the same direction
+// volume is the medium of the Volume the hit is in, nil outside of volumes
+func (w *World) sampleLight(r Ray, h *HitRecord, bsdf BSDF, volume *Medium, sampler Sampler) Vector3 {
+	if len(w.lightCDF) == 0 {
+		return Vector3{0, 0, 0}
+	}
+	index := sort.SearchFloat64s(w.lightCDF, sampler.Get1D())
+	if index >= len(w.lightCDF) {
+		index = len(w.lightCDF) - 1
+	}
+	if index >= len(w.lights)+len(w.deltaLights) {
+		return w.sampleEnvironment(r, h, bsdf, volume, sampler)
+	}
+	if index >= len(w.lights) {
+		selectionPDF := w.lightCDF[index]
+		if index > 0 {
+			selectionPDF -= w.lightCDF[index-1]
+		}
+		return w.sampleDeltaLight(r, h, bsdf, volume, w.deltaLights[index-len(w.lights)], selectionPDF)
+	}
+	light := w.lights[index]
+
+	direction, distance, directionPDF := light.shape.sampleDirection(h.Point, sampler)
+	if directionPDF <= 0 {
+		return Vector3{0, 0, 0}
+	}
+	lightPDF := light.selectionPDF * directionPDF
+
+	scattered, bsdfPDF := bsdf.Eval(r, *h, direction)
+	if scattered.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+
+	shadowRay := Ray{Origin: h.Point, Direction: direction}
+	transmittance := w.transmittance(shadowRay, distance*(1.0-shadowEpsilon), volume)
+	if transmittance.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+
+	weight := powerHeuristic(lightPDF, bsdfPDF)
+	return light.emission.MultiplyComponents(scattered).MultiplyComponents(transmittance).Scale(weight / lightPDF)
+}
+
+// sampleDeltaLight returns the light arriving at the hit from the delta light, scattered by the BSDF
+// The BSDF can't sample the light's direction, so there's nothing to weigh it against
+func (w *World) sampleDeltaLight(r Ray, h *HitRecord, bsdf BSDF, volume *Medium, light DeltaLight, selectionPDF float64) Vector3 {
+	direction, distance, incoming := light.illuminate(h.Point)
+	if incoming.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+	scattered, _ := bsdf.Eval(r, *h, direction)
+	if scattered.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+
+	shadowRay := Ray{Origin: h.Point, Direction: direction}
+	transmittance := w.transmittance(shadowRay, distance*(1.0-shadowEpsilon), volume)
+	if transmittance.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+	return incoming.MultiplyComponents(scattered).MultiplyComponents(transmittance).Scale(1.0 / selectionPDF)
+}
+
+// sampleEnvironment returns the light arriving at the hit from a direction of the Environment, scattered by the BSDF
+// and weighted with the power heuristic against the BSDF sampling the same direction
+func (w *World) sampleEnvironment(r Ray, h *HitRecord, bsdf BSDF, volume *Medium, sampler Sampler) Vector3 {
+	direction, directionPDF := w.Environment.sample(sampler)
+	if directionPDF <= 0 {
+		return Vector3{0, 0, 0}
+	}
+	lightPDF := w.environmentSelectionPDF * directionPDF
+
+	scattered, bsdfPDF := bsdf.Eval(r, *h, direction)
+	if scattered.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+
+	shadowRay := Ray{Origin: h.Point, Direction: direction}
+	transmittance := w.transmittance(shadowRay, math.Inf(1), volume)
+	if transmittance.IsNearZero() {
+		return Vector3{0, 0, 0}
+	}
+
+	weight := powerHeuristic(lightPDF, bsdfPDF)
+	return w.Environment.Radiance(direction).MultiplyComponents(scattered).MultiplyComponents(transmittance).Scale(weight / lightPDF)
+}
+
+// environmentPDF returns the pdf of sampleLight picking direction from the Environment
+func (w *World) environmentPDF(direction Vector3) float64 {
+	if w.Environment == nil || w.environmentSelectionPDF <= 0 {
+		return 0
+	}
+	return w.environmentSelectionPDF * w.Environment.pdf(direction)
+}
+
+// powerHeuristic returns the multiple importance sampling weight of a sample taken with pdf a,
+// given it could have been taken with pdf b as well
+func powerHeuristic(a, b float64) float64 {
+	a2 := a * a
+	return a2 / (a2 + b*b)
+}
+
+func (tri Triangle) area() float64 {
+	return tri.V1.Subtract(tri.V0).Cross(tri.V2.Subtract(tri.V0)).Length() * 0.5
+}
+
+func (tri Triangle) sampleDirection(origin Vector3, sampler Sampler) (Vector3, float64, float64) {
+	// uniform sampling of the triangle's area, source: https://www.pbr-book.org/3ed-2018/Monte_Carlo_Integration/2D_Sampling_with_Multidimensional_Transformations
+	u1, u2 := sampler.Get2D()
+	su := math.Sqrt(u1)
+	b0 := 1.0 - su
+	b1 := u2 * su
+	point := tri.V0.Scale(b0).Add(tri.V1.Scale(b1)).Add(tri.V2.Scale(1.0 - b0 - b1))
+
+	toPoint := point.Subtract(origin)
+	distance := toPoint.Length()
+	if distance == 0 {
+		return Vector3{0, 0, 0}, 0, 0
+	}
+	direction := toPoint.Scale(1.0 / distance)
+	normal := tri.V1.Subtract(tri.V0).Cross(tri.V2.Subtract(tri.V0)).Unit()
+	cosine := math.Abs(normal.Dot(direction))
+	if cosine < 1e-8 {
+		return Vector3{0, 0, 0}, 0, 0
+	}
+	return direction, distance, distance * distance / (cosine * tri.area())
+}
+
+func (tri Triangle) directionPDF(origin Vector3, h *HitRecord) float64 {
+	toPoint := h.Point.Subtract(origin)
+	distanceSquared := toPoint.LengthSquared()
+	normal := tri.V1.Subtract(tri.V0).Cross(tri.V2.Subtract(tri.V0)).Unit()
+	cosine := math.Abs(normal.Dot(toPoint.Unit()))
+	if cosine < 1e-8 {
+		return 0
+	}
+	return distanceSquared / (cosine * tri.area())
+}
+
+func (s Sphere) area() float64 {
+	return 4.0 * math.Pi * s.Radius * s.Radius
+}
+
+func (s Sphere) sampleDirection(origin Vector3, sampler Sampler) (Vector3, float64, float64) {
+	toCenter := s.Position.Subtract(origin)
+	distanceSquared := toCenter.LengthSquared()
+	radiusSquared := s.Radius * s.Radius
+
+	if distanceSquared <= radiusSquared {
+		// inside the sphere, sample its whole area
+		point := s.Position.Add(RandomOnUnitSphere(sampler).Scale(s.Radius))
+		toPoint := point.Subtract(origin)
+		distance := toPoint.Length()
+		if distance == 0 {
+			return Vector3{0, 0, 0}, 0, 0
+		}
+		direction := toPoint.Scale(1.0 / distance)
+		cosine := math.Abs(point.Subtract(s.Position).Scale(1.0 / s.Radius).Dot(direction))
+		if cosine < 1e-8 {
+			return Vector3{0, 0, 0}, 0, 0
+		}
+		return direction, distance, distance * distance / (cosine * s.area())
+	}
+
+	// outside the sphere, sample the cone of directions it covers
+	// source: https://raytracing.github.io/books/RayTracingTheRestOfYourLife.html#cleaninguppdfmanagement/samplingasphereobject
+	oneMinusCosThetaMax := s.coneSolidAngle(distanceSquared) / (2.0 * math.Pi)
+	u1, u2 := sampler.Get2D()
+	cosTheta := 1.0 - u1*oneMinusCosThetaMax
+	sinTheta := math.Sqrt(math.Max(0, 1.0-cosTheta*cosTheta))
+	phi := 2.0 * math.Pi * u2
+	w := toCenter.Scale(1.0 / math.Sqrt(distanceSquared))
+	u, v := orthonormalBasis(w)
+	direction := u.Scale(math.Cos(phi) * sinTheta).
+		Add(v.Scale(math.Sin(phi) * sinTheta)).
+		Add(w.Scale(cosTheta)).
+		Unit()
+
+	hitRecord, hit := s.Hit(Ray{Origin: origin, Direction: direction}, 0, math.Inf(1))
+	if !hit {
+		// grazing the silhouette, lost to rounding
+		return Vector3{0, 0, 0}, 0, 0
+	}
+	return direction, hitRecord.T, 1.0 / (2.0 * math.Pi * oneMinusCosThetaMax)
+}
+
+func (s Sphere) directionPDF(origin Vector3, h *HitRecord) float64 {
+	distanceSquared := s.Position.Subtract(origin).LengthSquared()
+	if distanceSquared <= s.Radius*s.Radius {
+		toPoint := h.Point.Subtract(origin)
+		normal := h.Point.Subtract(s.Position).Scale(1.0 / s.Radius)
+		cosine := math.Abs(normal.Dot(toPoint.Unit()))
+		if cosine < 1e-8 {
+			return 0
+		}
+		return toPoint.LengthSquared() / (cosine * s.area())
+	}
+	return 1.0 / s.coneSolidAngle(distanceSquared)
+}
+
+// coneSolidAngle returns the solid angle the sphere covers seen from distance sqrt(distanceSquared) from its center
+func (s Sphere) coneSolidAngle(distanceSquared float64) float64 {
+	sinThetaMaxSquared := s.Radius * s.Radius / distanceSquared
+	cosThetaMax := math.Sqrt(math.Max(0, 1.0-sinThetaMaxSquared))
+	// 1 - cos written so it doesn't lose precision for small, far away spheres
+	return 2.0 * math.Pi * sinThetaMaxSquared / (1.0 + cosThetaMax)
+}
@@ -0,0 +1,107 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func newLightTestWorld() World {
+	position := Vector3{0, 1, 3}
+	lookAt := Vector3{0, 0.5, 0}
+	camera := NewCamera(position, lookAt, Vector3{0, 1, 0}, 60.0, 0.0, 1.0, 1, 1)
+	white := Lambertian{Color: Vector3{0.7, 0.7, 0.7}}
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Sphere{Position: Vector3{0, -1000, 0}, Radius: 1000, Material: white},
+			Sphere{Position: Vector3{0, 0.5, 0}, Radius: 0.5, Material: Lambertian{Color: Vector3{0.8, 0.3, 0.3}}},
+			Sphere{Position: Vector3{1.5, 2, 0.5}, Radius: 0.25, Material: Light{Emission: Vector3{8, 8, 8}}},
+			Triangle{
+				V0: Vector3{-2, 0, -1}, V1: Vector3{-2, 2, -1}, V2: Vector3{-1, 1, -0.5},
+				N0: Vector3{1, 0, 0}, N1: Vector3{1, 0, 0}, N2: Vector3{1, 0, 0},
+				Material: Light{Emission: Vector3{2, 3, 4}},
+			},
+		},
+	}
+}
+
+func meanRadiance(w *World, options *Options, samples int) (Vector3, float64) {
+	sampler := newIndependentSampler(7)
+	sum := Vector3{0, 0, 0}
+	sumSquared := 0.0
+	for i := 0; i < samples; i++ {
+		ray := w.Camera.GetRay(sampler.Get1D(), sampler.Get1D(), sampler)
+		color := rayColor(ray, w, options, sampler, nil)
+		sum = sum.Add(color)
+		sumSquared += color.Luminance() * color.Luminance()
+	}
+	mean := sum.Scale(1.0 / float64(samples))
+	variance := sumSquared/float64(samples) - mean.Luminance()*mean.Luminance()
+	return mean, variance
+}
+
+func TestLightSamplingConvergesToSameImage(t *testing.T) {
+	world := newLightTestWorld()
+	world.Build()
+	options := Options{MaxBounces: 4}
+	const samples = 200000
+
+	want, bsdfVariance := meanRadiance(&world, &options, samples)
+	options.LightSampling = true
+	got, lightVariance := meanRadiance(&world, &options, samples)
+
+	for axis := 0; axis < 3; axis++ {
+		if relative := math.Abs(got.Axis(axis)-want.Axis(axis)) / want.Axis(axis); relative > 0.03 {
+			t.Errorf("mean radiance with light sampling is %v, without %v", got, want)
+			break
+		}
+	}
+	if lightVariance >= bsdfVariance {
+		t.Errorf("light sampling variance %v isn't lower than BSDF sampling variance %v", lightVariance, bsdfVariance)
+	}
+}
+
+func TestDeltaLights(t *testing.T) {
+	white := Lambertian{Color: Vector3{0.5, 0.5, 0.5}}
+	floor := Triangle{
+		V0: Vector3{-10, 0, -10}, V1: Vector3{0, 0, 10}, V2: Vector3{10, 0, -10},
+		N0: Vector3{0, 1, 0}, N1: Vector3{0, 1, 0}, N2: Vector3{0, 1, 0},
+		Material: white,
+	}
+	blocker := Sphere{Position: Vector3{3, 1, 0}, Radius: 0.5, Material: white}
+	// straight above the floor Scatter has a pdf of 7 / (2 pi), and Eval returns it times the albedo
+	overhead := 0.5 * hemisphereOffsetPdf(1)
+	tests := []struct {
+		name  string
+		light DeltaLight
+		point Vector3
+		want  float64
+	}{
+		// albedo * pdf * intensity / distance^2
+		{"point", PointLight{Position: Vector3{0, 2, 0}, Intensity: Vector3{8, 8, 8}}, Vector3{0, 0, 0}, overhead * 2},
+		{"point shadowed", PointLight{Position: Vector3{3, 2, 0}, Intensity: Vector3{8, 8, 8}}, Vector3{3, 0, 0}, 0},
+		{"spot inside cone", SpotLight{
+			Position: Vector3{0, 2, 0}, Direction: Vector3{0, -1, 0}, Intensity: Vector3{8, 8, 8},
+			InnerConeAngle: 10, OuterConeAngle: 20,
+		}, Vector3{0, 0, 0}, overhead * 2},
+		{"spot outside cone", SpotLight{
+			Position: Vector3{0, 2, 0}, Direction: Vector3{0, -1, 0}, Intensity: Vector3{8, 8, 8},
+			InnerConeAngle: 10, OuterConeAngle: 20,
+		}, Vector3{-2, 0, 0}, 0},
+		// albedo * pdf * irradiance, 30 degrees from the normal
+		{"directional", DirectionalLight{Direction: Vector3{-1, -math.Sqrt(3), 0}, Irradiance: Vector3{4, 4, 4}}, Vector3{-2, 0, 0},
+			0.5 * hemisphereOffsetPdf(math.Sqrt(3)/2) * 4},
+		// Scatter never goes further than 45 degrees from the normal
+		{"directional grazing", DirectionalLight{Direction: Vector3{-math.Sqrt(3), -1, 0}, Irradiance: Vector3{4, 4, 4}}, Vector3{-2, 0, 0}, 0},
+	}
+	for _, test := range tests {
+		w := World{Hittables: []Hittable{floor, blocker}, Lights: []DeltaLight{test.light}}
+		w.Build()
+		ray := Ray{Origin: test.point.Add(Vector3{0, 1, 0}), Direction: Vector3{0, -1, 0}}
+		hitRecord := NewHitRecord(test.point, Vector3{0, 1, 0}, ray, 1, white)
+		got := w.sampleLight(ray, &hitRecord, white, nil, newIndependentSampler(1))
+		if math.Abs(got.X-test.want) > 1e-9 {
+			t.Errorf("%s light gives %v, want %v", test.name, got.X, test.want)
+		}
+	}
+}
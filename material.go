@@ -1,25 +1,34 @@
-package main
+package raytracer
 
 import (
 	"math"
-	"math/rand"
 )
 
 // Material determines how rays scatter
 type Material interface {
-	Scatter(Ray, HitRecord, *rand.Rand) (*Ray, Vector3, bool)
-	Emit(Ray, HitRecord, *rand.Rand) Vector3
+	Scatter(Ray, HitRecord, Sampler) (*Ray, Vector3, bool)
+	Emit(Ray, HitRecord, Sampler) Vector3
+}
+
+// BSDF is implemented by materials that scatter light over a range of directions
+// Their scattering can be evaluated for any direction, which lets rayColor sample lights directly
+type BSDF interface {
+	// Eval returns the fraction of light arriving from direction that gets scattered back along the ray,
+	// multiplied by the cosine of the angle between direction and the normal,
+	// and the pdf of Scatter choosing direction
+	Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64)
 }
 
 // Lambertian is a diffuse material
 type Lambertian struct {
 	Color Vector3
+	// Texture overrides Color if set
+	Texture Texture
 }
 
 // Scatter returns the scattered ray and it's attenuation
-func (l Lambertian) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3, bool) {
-	scatterDirection := h.Normal.Add(RandomInUnitHemisphere(h.Normal, rnd))
-	// scatterDirection := h.Normal.Add(RandomInUnitSphere(rnd))
+func (l Lambertian) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
+	scatterDirection := h.Normal.Add(RandomInUnitHemisphere(h.Normal, sampler))
 
 	// Catch degenerate scatter direction
 	if scatterDirection.IsNearZero() {
@@ -30,51 +39,85 @@ func (l Lambertian) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3,
 		Origin:    h.Point,
 		Direction: scatterDirection,
 	}
-	return &scatteredRay, l.Color, true
+	return &scatteredRay, textureOrColor(l.Texture, l.Color, h), true
+}
+
+// Eval returns the color times the pdf of Scatter, which weighs every direction it picks by just the color
+func (l Lambertian) Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64) {
+	pdf := hemisphereOffsetPdf(h.Normal.Dot(direction.Unit()))
+	return textureOrColor(l.Texture, l.Color, h).Scale(pdf), pdf
+}
+
+// hemisphereOffsetPdf returns the pdf of the direction of the normal plus a random vector in the unit hemisphere,
+// by the cosine of its angle with the normal
+// The directions are within 45 degrees of the normal, at a distance r in (1/cosine, 2 cosine) from the point,
+// the volume of the hemisphere is 2/3 pi, so the pdf is the integral of r^2 / (2/3 pi) over that range
+func hemisphereOffsetPdf(cosine float64) float64 {
+	if cosine <= math.Sqrt2/2 {
+		return 0
+	}
+	return (8*cosine*cosine*cosine - 1/(cosine*cosine*cosine)) / (2 * math.Pi)
 }
 
 // Emit returns black, since Lambertian doesn't emit light
-func (l Lambertian) Emit(r Ray, h HitRecord, rnd *rand.Rand) Vector3 {
+func (l Lambertian) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
 	return Vector3{0, 0, 0}
 }
 
+// albedo returns the diffuse color at the hit
+func (l Lambertian) albedo(h HitRecord) Vector3 {
+	return textureOrColor(l.Texture, l.Color, h)
+}
+
 // Metal is a reflective material
 type Metal struct {
 	Color     Vector3
 	Glosiness float64
+	// Texture overrides Color if set
+	Texture Texture
 }
 
 // Scatter returns the scattered ray and it's attenuation
-func (m Metal) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3, bool) {
+func (m Metal) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
 	reflected := r.Direction.
 		Unit().
 		Reflect(h.Normal).
-		Add(RandomInUnitSphere(rnd).Scale(1.0 - m.Glosiness))
+		Add(RandomInUnitSphere(sampler).Scale(1.0 - m.Glosiness))
 	scatteredRay := Ray{
 		Origin:    h.Point,
 		Direction: reflected,
 	}
 	hasScattered := reflected.Dot(h.Normal) > 0
-	return &scatteredRay, m.Color, hasScattered
+	return &scatteredRay, textureOrColor(m.Texture, m.Color, h), hasScattered
 }
 
 // Emit returns black, since Metal doesn't emit light
-func (m Metal) Emit(r Ray, h HitRecord, rnd *rand.Rand) Vector3 {
+func (m Metal) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
 	return Vector3{0, 0, 0}
 }
 
+// albedo returns the color reflected at the hit
+func (m Metal) albedo(h HitRecord) Vector3 {
+	return textureOrColor(m.Texture, m.Color, h)
+}
+
 // Dielectric is a transparent material than refracts light
 type Dielectric struct {
 	IndexOfRefraction float64
 }
 
 // Emit returns black, since Dielectric doesn't emit light
-func (d Dielectric) Emit(r Ray, h HitRecord, rnd *rand.Rand) Vector3 {
+func (d Dielectric) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
 	return Vector3{0, 0, 0}
 }
 
+// albedo is white, glass doesn't absorb any light
+func (d Dielectric) albedo(h HitRecord) Vector3 {
+	return Vector3{1, 1, 1}
+}
+
 // Scatter returns the scattered ray and it's attenuation
-func (d Dielectric) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3, bool) {
+func (d Dielectric) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
 	refractionRatio := d.IndexOfRefraction
 	if h.IsFrontFace {
 		refractionRatio = 1.0 / d.IndexOfRefraction
@@ -86,7 +129,7 @@ func (d Dielectric) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3,
 
 	var newDirection Vector3
 	cannotRefract := refractionRatio*sinTheta > 1.0
-	if cannotRefract || reflectance(cosTheta, refractionRatio) > rnd.Float64() {
+	if cannotRefract || reflectance(cosTheta, refractionRatio) > sampler.Get1D() {
 		newDirection = unitDirection.Reflect(h.Normal)
 	} else {
 		newDirection = unitDirection.Refract(h.Normal, refractionRatio)
@@ -105,15 +148,34 @@ type Light struct {
 }
 
 // Scatter returns nil and false since Light doesn't bounce or refract rays
-func (l Light) Scatter(r Ray, h HitRecord, rnd *rand.Rand) (*Ray, Vector3, bool) {
+func (l Light) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
 	return nil, Vector3{0, 0, 0}, false
 }
 
 // Emit returns the light's emission, components can be > 1.0
-func (l Light) Emit(r Ray, h HitRecord, rnd *rand.Rand) Vector3 {
+func (l Light) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
+	return l.Emission
+}
+
+// emission returns the light's emission
+func (l Light) emission() Vector3 {
 	return l.Emission
 }
 
+// emitter is implemented by materials that give off the same light everywhere, World.Build turns the spheres and
+// triangles with one that isn't black into lights
+type emitter interface {
+	emission() Vector3
+}
+
+// textureOrColor returns the texture's value at the hit or the color if there's no texture
+func textureOrColor(t Texture, color Vector3, h HitRecord) Vector3 {
+	if t == nil {
+		return color
+	}
+	return t.Value(h.U, h.V, h.Point)
+}
+
 func reflectance(cosine, coefficient float64) float64 {
 	// Schlick's approximation
 	r0 := (1.0 - coefficient) / (1.0 + coefficient)
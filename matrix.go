@@ -0,0 +1,145 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// Matrix4 is a 4x4 matrix for affine transforms, stored row by row
+// It transforms column vectors, so a.Multiply(b) applies b first and then a
+type Matrix4 [4][4]float64
+
+// IdentityMatrix returns the transform that changes nothing
+func IdentityMatrix() Matrix4 {
+	return Matrix4{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// TranslationMatrix returns the transform that moves points by offset
+func TranslationMatrix(offset Vector3) Matrix4 {
+	return Matrix4{
+		{1, 0, 0, offset.X},
+		{0, 1, 0, offset.Y},
+		{0, 0, 1, offset.Z},
+		{0, 0, 0, 1},
+	}
+}
+
+// ScaleMatrix returns the transform that scales along the axes by the components of scale
+func ScaleMatrix(scale Vector3) Matrix4 {
+	return Matrix4{
+		{scale.X, 0, 0, 0},
+		{0, scale.Y, 0, 0},
+		{0, 0, scale.Z, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// RotationMatrix returns the transform that rotates counter-clockwise around the axis by degrees
+func RotationMatrix(axis Vector3, degrees float64) Matrix4 {
+	halfAngle := degrees * math.Pi / 360.0
+	a := axis.Unit().Scale(math.Sin(halfAngle))
+	return QuaternionMatrix(a.X, a.Y, a.Z, math.Cos(halfAngle))
+}
+
+// QuaternionMatrix returns the rotation of the unit quaternion x, y, z, w
+func QuaternionMatrix(x, y, z, w float64) Matrix4 {
+	return Matrix4{
+		{1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w), 0},
+		{2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w), 0},
+		{2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y), 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Multiply returns the matrix product m * n
+func (m Matrix4) Multiply(n Matrix4) Matrix4 {
+	var product Matrix4
+	for row := 0; row < 4; row++ {
+		for column := 0; column < 4; column++ {
+			for i := 0; i < 4; i++ {
+				product[row][column] += m[row][i] * n[i][column]
+			}
+		}
+	}
+	return product
+}
+
+// Transpose returns the matrix mirrored along its diagonal
+func (m Matrix4) Transpose() Matrix4 {
+	var transposed Matrix4
+	for row := 0; row < 4; row++ {
+		for column := 0; column < 4; column++ {
+			transposed[row][column] = m[column][row]
+		}
+	}
+	return transposed
+}
+
+// Inverse returns the inverse of the matrix and true, or false if the matrix can't be inverted
+func (m Matrix4) Inverse() (Matrix4, bool) {
+	// Gauss-Jordan elimination with partial pivoting
+	a := m
+	inverse := IdentityMatrix()
+	for column := 0; column < 4; column++ {
+		pivot := column
+		for row := column + 1; row < 4; row++ {
+			if math.Abs(a[row][column]) > math.Abs(a[pivot][column]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][column]) < 1e-12 {
+			return Matrix4{}, false
+		}
+		a[column], a[pivot] = a[pivot], a[column]
+		inverse[column], inverse[pivot] = inverse[pivot], inverse[column]
+
+		scale := 1.0 / a[column][column]
+		for i := 0; i < 4; i++ {
+			a[column][i] *= scale
+			inverse[column][i] *= scale
+		}
+		for row := 0; row < 4; row++ {
+			if row == column {
+				continue
+			}
+			factor := a[row][column]
+			for i := 0; i < 4; i++ {
+				a[row][i] -= factor * a[column][i]
+				inverse[row][i] -= factor * inverse[column][i]
+			}
+		}
+	}
+	return inverse, true
+}
+
+// TransformPoint returns the point transformed by the matrix, translation included
+func (m Matrix4) TransformPoint(p Vector3) Vector3 {
+	return Vector3{
+		m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z + m[0][3],
+		m[1][0]*p.X + m[1][1]*p.Y + m[1][2]*p.Z + m[1][3],
+		m[2][0]*p.X + m[2][1]*p.Y + m[2][2]*p.Z + m[2][3],
+	}
+}
+
+// TransformDirection returns the direction transformed by the matrix, without translation
+func (m Matrix4) TransformDirection(d Vector3) Vector3 {
+	return Vector3{
+		m[0][0]*d.X + m[0][1]*d.Y + m[0][2]*d.Z,
+		m[1][0]*d.X + m[1][1]*d.Y + m[1][2]*d.Z,
+		m[2][0]*d.X + m[2][1]*d.Y + m[2][2]*d.Z,
+	}
+}
+
+// NormalMatrix returns the matrix that transforms normals, the transposed inverse of m
+// Directions transformed by it stay perpendicular to surfaces transformed by m
+func (m Matrix4) NormalMatrix() Matrix4 {
+	inverse, ok := m.Inverse()
+	if !ok {
+		return IdentityMatrix()
+	}
+	return inverse.Transpose()
+}
@@ -0,0 +1,43 @@
+package raytracer
+
+import (
+	"testing"
+)
+
+func TestMatrixTransforms(t *testing.T) {
+	m := TranslationMatrix(Vector3{1, 2, 3}).
+		Multiply(RotationMatrix(Vector3{0, 0, 1}, 90)).
+		Multiply(ScaleMatrix(Vector3{2, 2, 2}))
+
+	if got, want := m.TransformPoint(Vector3{1, 0, 0}), (Vector3{1, 4, 3}); got.Subtract(want).Length() > 1e-9 {
+		t.Errorf("transformed point is %v, want %v", got, want)
+	}
+	if got, want := m.TransformDirection(Vector3{0, 1, 0}), (Vector3{-2, 0, 0}); got.Subtract(want).Length() > 1e-9 {
+		t.Errorf("transformed direction is %v, want %v", got, want)
+	}
+
+	inverse, ok := m.Inverse()
+	if !ok {
+		t.Fatal("matrix can't be inverted")
+	}
+	identity := IdentityMatrix()
+	product := m.Multiply(inverse)
+	for row := 0; row < 4; row++ {
+		for column := 0; column < 4; column++ {
+			if d := product[row][column] - identity[row][column]; d > 1e-9 || d < -1e-9 {
+				t.Fatalf("matrix times its inverse is %v, want the identity", product)
+			}
+		}
+	}
+	if _, ok := ScaleMatrix(Vector3{1, 0, 1}).Inverse(); ok {
+		t.Error("inverted a matrix that flattens the Y axis")
+	}
+
+	// A plane sloped by a non-uniform scale keeps its normal perpendicular
+	squash := ScaleMatrix(Vector3{1, 0.5, 1})
+	along := squash.TransformDirection(Vector3{1, 1, 0})
+	normal := squash.NormalMatrix().TransformDirection(Vector3{1, -1, 0})
+	if d := along.Dot(normal); d > 1e-9 || d < -1e-9 {
+		t.Errorf("transformed normal %v isn't perpendicular to %v", normal, along)
+	}
+}
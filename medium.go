@@ -0,0 +1,166 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// Medium is a homogeneous participating medium like fog, smoke or murky water
+// Its coefficients are per unit of distance, light keeps exp(-(Absorption+Scattering)*distance) of itself
+// going through it
+type Medium struct {
+	Absorption, Scattering Vector3
+	// Anisotropy is the g of the Henyey-Greenstein phase function, from -1 scattering light back
+	// to 1 scattering it forward, 0 scatters it equally in all directions
+	Anisotropy float64
+}
+
+// extinction returns the fraction of light absorbed or scattered per unit of distance
+func (m *Medium) extinction() Vector3 {
+	return m.Absorption.Add(m.Scattering)
+}
+
+// transmittance returns the fraction of light getting through distance units of the medium
+func (m *Medium) transmittance(distance float64) Vector3 {
+	extinction := m.extinction()
+	transmittance := func(coefficient float64) float64 {
+		if coefficient <= 0 {
+			return 1
+		}
+		return math.Exp(-coefficient * distance)
+	}
+	return Vector3{transmittance(extinction.X), transmittance(extinction.Y), transmittance(extinction.Z)}
+}
+
+// sampleDistance picks how far light gets through the medium before it's scattered, given it leaves after tMax
+// Returns the distance, the weight of the path up to there and whether it's scattered before tMax
+// The distance is sampled for a random color channel, weighted by the pdf of all of them
+// Source: https://www.pbr-book.org/3ed-2018/Light_Transport_II_Volume_Rendering/Sampling_Volume_Scattering
+func (m *Medium) sampleDistance(tMax float64, sampler Sampler) (float64, Vector3, bool) {
+	extinction := m.extinction()
+	u1, u2 := sampler.Get2D()
+	coefficient := extinction.Axis(int(math.Min(u1*3.0, 2)))
+	distance := math.Inf(1)
+	if coefficient > 0 {
+		distance = -math.Log(1.0-u2) / coefficient
+	}
+	scattered := distance < tMax
+	if !scattered {
+		distance = tMax
+	}
+
+	transmittance := m.transmittance(distance)
+	density := transmittance
+	if scattered {
+		density = extinction.MultiplyComponents(transmittance)
+	}
+	pdf := (density.X + density.Y + density.Z) / 3.0
+	if pdf <= 0 {
+		return distance, Vector3{0, 0, 0}, scattered
+	}
+	weight := transmittance.Scale(1.0 / pdf)
+	if scattered {
+		weight = weight.MultiplyComponents(m.Scattering)
+	}
+	return distance, weight, scattered
+}
+
+// Volume fills a closed Boundary, like a sphere or a closed mesh with outward facing triangles, with a Medium
+// The camera has to be outside of volumes and volumes can't overlap
+type Volume struct {
+	Boundary Hittable
+	Medium   *Medium
+}
+
+// Hit returns the boundary's hit, rays cross it into or out of the medium without scattering
+func (v Volume) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	hitRecord, hit := v.Boundary.Hit(r, tMin, tMax)
+	if hit {
+		hitRecord.medium = v.Medium
+		hitRecord.light = nil
+	}
+	return hitRecord, hit
+}
+
+// BoundingBox returns the box enclosing the boundary
+func (v Volume) BoundingBox() AABB {
+	return v.Boundary.BoundingBox()
+}
+
+// henyeyGreenstein is the phase function of a medium, the distribution of the directions light scatters into
+// Source: https://www.pbr-book.org/3ed-2018/Volume_Scattering/Phase_Functions
+type henyeyGreenstein struct {
+	g float64
+}
+
+// Eval returns the phase function for light from direction scattered back along the ray,
+// which is also the pdf of sample choosing direction
+func (p henyeyGreenstein) Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64) {
+	pdf := p.pdf(r.Direction.Unit().Dot(direction.Unit()))
+	return Vector3{pdf, pdf, pdf}, pdf
+}
+
+// pdf returns the density of scattering into a direction at cosTheta to the direction light was going
+func (p henyeyGreenstein) pdf(cosTheta float64) float64 {
+	denominator := 1.0 + p.g*p.g - 2.0*p.g*cosTheta
+	return (1.0 - p.g*p.g) / (4.0 * math.Pi * denominator * math.Sqrt(denominator))
+}
+
+// sample returns a unit direction to scatter light going in the unit direction forward into
+func (p henyeyGreenstein) sample(forward Vector3, sampler Sampler) Vector3 {
+	u, v := sampler.Get2D()
+	var cosTheta float64
+	if math.Abs(p.g) < 1e-3 {
+		cosTheta = 1.0 - 2.0*u
+	} else {
+		s := (1.0 - p.g*p.g) / (1.0 - p.g + 2.0*p.g*u)
+		cosTheta = Clamp((1.0+p.g*p.g-s*s)/(2.0*p.g), -1, 1)
+	}
+	sinTheta := math.Sqrt(math.Max(0, 1.0-cosTheta*cosTheta))
+	phi := 2.0 * math.Pi * v
+	a, b := orthonormalBasis(forward)
+	return a.Scale(math.Cos(phi) * sinTheta).
+		Add(b.Scale(math.Sin(phi) * sinTheta)).
+		Add(forward.Scale(cosTheta)).
+		Unit()
+}
+
+// mediumSegment returns the medium a ray goes through between tMin and tMax and the part of the ray in it,
+// volume is the medium of the Volume the ray is in, nil outside of volumes where the Fog is if there's one
+func (w *World) mediumSegment(r Ray, volume *Medium, tMin, tMax float64) (*Medium, float64, float64, bool) {
+	if volume != nil {
+		return volume, tMin, tMax, tMax > tMin
+	}
+	if w.Fog == nil {
+		return nil, 0, 0, false
+	}
+	t0, t1, ok := w.fogBox.clip(r, tMin, tMax)
+	return w.Fog, t0, t1, ok && t1 > t0
+}
+
+// transmittance returns the fraction of light getting through along the ray up to tMax, 0 if something
+// other than the boundary of a volume is in the way. volume is the medium the ray starts in
+func (w *World) transmittance(r Ray, tMax float64, volume *Medium) Vector3 {
+	transmittance := Vector3{1, 1, 1}
+	start := 0.0
+	for {
+		hitRecord, hit := w.Hit(r, start+0.001, tMax)
+		end := tMax
+		if hit {
+			end = hitRecord.T
+		}
+		if medium, t0, t1, ok := w.mediumSegment(r, volume, start, end); ok {
+			transmittance = transmittance.MultiplyComponents(medium.transmittance(t1 - t0))
+		}
+		if !hit {
+			return transmittance
+		}
+		if hitRecord.medium == nil {
+			return Vector3{0, 0, 0}
+		}
+		volume = nil
+		if hitRecord.IsFrontFace {
+			volume = hitRecord.medium
+		}
+		start = hitRecord.T
+	}
+}
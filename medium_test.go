@@ -0,0 +1,175 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHenyeyGreensteinSampleMatchesPDF(t *testing.T) {
+	forward := Vector3{1, 2, -0.5}.Unit()
+	for _, g := range []float64{-0.7, 0, 0.3, 0.9} {
+		p := henyeyGreenstein{g: g}
+
+		// the pdf integrates to 1 over the sphere
+		const steps = 10000
+		integral := 0.0
+		for i := 0; i < steps; i++ {
+			cosTheta := -1.0 + 2.0*(float64(i)+0.5)/steps
+			integral += 2.0 * math.Pi * p.pdf(cosTheta) * 2.0 / steps
+		}
+		if math.Abs(integral-1) > 1e-3 {
+			t.Errorf("g %v: the pdf integrates to %v", g, integral)
+		}
+
+		// the average cosine of the samples is g, and they fall into slices of cosines as often as the pdf says
+		sampler := newIndependentSampler(1)
+		const samples, slices = 200000, 8
+		var counts [slices]int
+		sumCos := 0.0
+		for i := 0; i < samples; i++ {
+			direction := p.sample(forward, sampler)
+			cosTheta := direction.Dot(forward)
+			sumCos += cosTheta
+			counts[int(Clamp(math.Floor((cosTheta+1)/2*slices), 0, slices-1))]++
+		}
+		if got := sumCos / samples; math.Abs(got-g) > 0.01 {
+			t.Errorf("g %v: the average cosine of the samples is %v", g, got)
+		}
+		for i, count := range counts {
+			want := 0.0
+			for j := 0; j < steps/slices; j++ {
+				cosTheta := -1.0 + 2.0*(float64(i)+(float64(j)+0.5)/(steps/slices))/slices
+				want += 2.0 * math.Pi * p.pdf(cosTheta) * 2.0 / steps
+			}
+			if got := float64(count) / samples; math.Abs(got-want) > 0.005 {
+				t.Errorf("g %v: %v of the samples are in slice %d, want %v", g, got, i, want)
+			}
+		}
+
+		// Eval with a ray going along forward matches the pdf
+		direction := Vector3{0, 1, 1}.Unit()
+		value, pdf := p.Eval(Ray{Direction: forward.Scale(3)}, HitRecord{}, direction)
+		if want := p.pdf(forward.Dot(direction)); math.Abs(pdf-want) > 1e-12 || math.Abs(value.Y-want) > 1e-12 {
+			t.Errorf("g %v: Eval returned %v and pdf %v, want %v", g, value, pdf, want)
+		}
+	}
+}
+
+func TestMediumSampleDistance(t *testing.T) {
+	m := &Medium{Absorption: Vector3{0.1, 0.5, 0}, Scattering: Vector3{0.6, 0.2, 1.5}}
+	const tMax = 1.3
+	sampler := newIndependentSampler(1)
+	const samples = 400000
+	passed, scattered := Vector3{0, 0, 0}, Vector3{0, 0, 0}
+	for i := 0; i < samples; i++ {
+		distance, weight, isScattered := m.sampleDistance(tMax, sampler)
+		if isScattered {
+			if distance < 0 || distance >= tMax {
+				t.Fatalf("scattered at %v, outside of [0..%v)", distance, tMax)
+			}
+			scattered = scattered.Add(weight.Scale(1.0 / samples))
+		} else {
+			passed = passed.Add(weight.Scale(1.0 / samples))
+		}
+	}
+
+	// the light getting through, and the light scattered on the way, which is the scattering of the light lost
+	transmittance := m.transmittance(tMax)
+	extinction := m.extinction()
+	wantScattered := Vector3{
+		m.Scattering.X / extinction.X * (1 - transmittance.X),
+		m.Scattering.Y / extinction.Y * (1 - transmittance.Y),
+		m.Scattering.Z / extinction.Z * (1 - transmittance.Z),
+	}
+	if passed.Subtract(transmittance).Length() > 0.01 {
+		t.Errorf("the weight of passing through is %v, want %v", passed, transmittance)
+	}
+	if scattered.Subtract(wantScattered).Length() > 0.01 {
+		t.Errorf("the weight of scattering is %v, want %v", scattered, wantScattered)
+	}
+}
+
+func TestWorldTransmittance(t *testing.T) {
+	smoke := &Medium{Absorption: Vector3{0.5, 1, 2}}
+	fog := &Medium{Scattering: Vector3{0.1, 0.1, 0.1}}
+	white := Lambertian{Color: Vector3{1, 1, 1}}
+	w := World{
+		Hittables: []Hittable{
+			Volume{Boundary: Sphere{Position: Vector3{0, 0, -3}, Radius: 1, Material: white}, Medium: smoke},
+			Sphere{Position: Vector3{0, 0, -8}, Radius: 1, Material: white},
+			// the fog box reaches from 5 to -9 along Z
+			Sphere{Position: Vector3{0, 0, 4}, Radius: 1, Material: white},
+		},
+	}
+	w.Build()
+
+	r := Ray{Direction: Vector3{0, 0, -1}}
+	for _, test := range []struct {
+		name string
+		fog  *Medium
+		tMax float64
+		want Vector3
+	}{
+		{"through the volume", nil, 5, smoke.transmittance(2)},
+		{"to the middle of the volume", nil, 3, smoke.transmittance(1)},
+		{"blocked by a sphere", nil, 10, Vector3{0, 0, 0}},
+		{"through fog and the volume", fog, 5, smoke.transmittance(2).MultiplyComponents(fog.transmittance(3))},
+	} {
+		w.Fog = test.fog
+		if got := w.transmittance(r, test.tMax, nil); got.Subtract(test.want).Length() > 1e-9 {
+			t.Errorf("%s: transmittance is %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// starting inside of the volume
+	w.Fog = nil
+	inside := Ray{Origin: Vector3{0, 0, -3}, Direction: Vector3{0, 1, 0}}
+	if got, want := w.transmittance(inside, 10, smoke), smoke.transmittance(1); got.Subtract(want).Length() > 1e-9 {
+		t.Errorf("transmittance out of the volume is %v, want %v", got, want)
+	}
+}
+
+// TestVolumeConservesEnergy renders a medium that scatters without absorbing in a white environment,
+// which looks white as all of the light gets out again
+func TestVolumeConservesEnergy(t *testing.T) {
+	img := NewFloatImage(1, 1)
+	img.Set(0, 0, Vector3{1, 1, 1})
+	options := DefaultOptions()
+	options.MaxBounces = 200
+
+	for _, test := range []struct {
+		name          string
+		anisotropy    float64
+		fog           bool
+		lightSampling bool
+	}{
+		{"isotropic", 0, false, false},
+		{"isotropic with light sampling", 0, false, true},
+		{"forward scattering with light sampling", 0.6, false, true},
+		{"in fog with light sampling", -0.3, true, true},
+	} {
+		medium := &Medium{Scattering: Vector3{1.5, 1, 0.5}, Anisotropy: test.anisotropy}
+		w := World{
+			Hittables:   []Hittable{Volume{Boundary: Sphere{Position: Vector3{0, 0, -3}, Radius: 1}, Medium: medium}},
+			Environment: NewEnvironmentLight(img, 0, 1),
+		}
+		if test.fog {
+			// the fog fills the box around the sphere, which is left empty
+			w.Hittables = []Hittable{Volume{Boundary: Sphere{Position: Vector3{0, 0, -3}, Radius: 1}, Medium: &Medium{}}}
+			w.Fog = medium
+		}
+		w.Build()
+		options.LightSampling = test.lightSampling
+
+		sampler := newIndependentSampler(1)
+		const samples = 20000
+		got := Vector3{0, 0, 0}
+		for i := 0; i < samples; i++ {
+			direction := Vector3{sampler.Get1D()*0.6 - 0.3, sampler.Get1D()*0.6 - 0.3, -1}
+			got = got.Add(rayColor(Ray{Direction: direction}, &w, &options, sampler, nil).Scale(1.0 / samples))
+		}
+		if got.Subtract(Vector3{1, 1, 1}).Length() > 0.03 {
+			t.Errorf("%s: the medium's color is %v, want white", test.name, got)
+		}
+	}
+}
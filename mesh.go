@@ -0,0 +1,21 @@
+package raytracer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ReadMesh reads a .obj, .ply or .stl file, picked by its extension, with a single material
+func ReadMesh(filePath string, material Material) ([]Triangle, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".obj":
+		return ReadObj(filePath, material)
+	case ".ply":
+		return ReadPly(filePath, material)
+	case ".stl":
+		return ReadStl(filePath, material)
+	default:
+		return nil, fmt.Errorf("%s: unknown mesh format, expected .obj, .ply or .stl", filePath)
+	}
+}
@@ -0,0 +1,304 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// minAlpha keeps the GGX distribution from becoming a spike that can't be evaluated
+const minAlpha = 1e-3
+
+// RoughConductor is a metal whose surface is made of microfacets oriented by the GGX distribution
+type RoughConductor struct {
+	// Color is the reflectance at normal incidence, Fresnel reflectance is approximated with Schlick's
+	Color Vector3
+	// Texture overrides Color if set
+	Texture Texture
+	// Roughness goes from 0 for a mirror to 1 for a matte surface
+	Roughness float64
+	// RoughnessTexture overrides Roughness if set, its X component is used
+	RoughnessTexture Texture
+}
+
+// Scatter reflects the ray off a microfacet normal sampled from the ones visible along the ray
+func (c RoughConductor) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
+	f := newShadingFrame(h.Normal)
+	wo := f.toLocal(r.Direction.Unit().Scale(-1))
+	if wo.Z <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	distribution := newGGX(textureOrValue(c.RoughnessTexture, c.Roughness, h))
+	wi, ok := distribution.sampleReflection(wo, sampler)
+	if !ok {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	value, pdf := distribution.evalReflection(wo, wi, textureOrColor(c.Texture, c.Color, h))
+	if pdf <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	scatteredRay := Ray{Origin: h.Point, Direction: f.toWorld(wi)}
+	return &scatteredRay, value.Scale(1.0 / pdf), true
+}
+
+// Eval returns the microfacet BRDF times the cosine term and the pdf of Scatter reflecting into direction
+func (c RoughConductor) Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64) {
+	f := newShadingFrame(h.Normal)
+	distribution := newGGX(textureOrValue(c.RoughnessTexture, c.Roughness, h))
+	return distribution.evalReflection(f.toLocal(r.Direction.Unit().Scale(-1)), f.toLocal(direction.Unit()),
+		textureOrColor(c.Texture, c.Color, h))
+}
+
+// Emit returns black, since RoughConductor doesn't emit light
+func (c RoughConductor) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
+	return Vector3{0, 0, 0}
+}
+
+// albedo returns the color reflected at the hit
+func (c RoughConductor) albedo(h HitRecord) Vector3 {
+	return textureOrColor(c.Texture, c.Color, h)
+}
+
+// RoughDielectric is frosted glass, its surface is made of microfacets oriented by the GGX distribution
+// Source: Walter et al. 2007, Microfacet Models for Refraction through Rough Surfaces
+type RoughDielectric struct {
+	IndexOfRefraction float64
+	// Roughness goes from 0 for clear glass to 1 for a matte surface
+	Roughness float64
+	// RoughnessTexture overrides Roughness if set, its X component is used
+	RoughnessTexture Texture
+}
+
+// Scatter reflects or refracts the ray through a microfacet normal sampled from the ones visible along the ray,
+// picking reflection with the probability of the Fresnel reflectance
+func (d RoughDielectric) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
+	f := newShadingFrame(h.Normal)
+	wo := f.toLocal(r.Direction.Unit().Scale(-1))
+	if wo.Z <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	eta := refractionRatio(d.IndexOfRefraction, h)
+	distribution := newGGX(textureOrValue(d.RoughnessTexture, d.Roughness, h))
+	wi, ok := distribution.sampleDielectric(wo, eta, sampler)
+	if !ok {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	value, pdf := distribution.evalDielectric(wo, wi, eta)
+	if pdf <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	scatteredRay := Ray{Origin: h.Point, Direction: f.toWorld(wi)}
+	return &scatteredRay, Vector3{1, 1, 1}.Scale(value / pdf), true
+}
+
+// Eval returns the microfacet BSDF times the cosine term and the pdf of Scatter choosing direction,
+// directions below the surface are refractions
+func (d RoughDielectric) Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64) {
+	f := newShadingFrame(h.Normal)
+	distribution := newGGX(textureOrValue(d.RoughnessTexture, d.Roughness, h))
+	value, pdf := distribution.evalDielectric(f.toLocal(r.Direction.Unit().Scale(-1)), f.toLocal(direction.Unit()),
+		refractionRatio(d.IndexOfRefraction, h))
+	return Vector3{value, value, value}, pdf
+}
+
+// Emit returns black, since RoughDielectric doesn't emit light
+func (d RoughDielectric) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
+	return Vector3{0, 0, 0}
+}
+
+// albedo is white like the one of smooth glass
+func (d RoughDielectric) albedo(h HitRecord) Vector3 {
+	return Vector3{1, 1, 1}
+}
+
+// refractionRatio returns the index of refraction on the far side of the surface over the one on the ray's side
+func refractionRatio(indexOfRefraction float64, h HitRecord) float64 {
+	if h.IsFrontFace {
+		return indexOfRefraction
+	}
+	return 1.0 / indexOfRefraction
+}
+
+// ggx is the GGX or Trowbridge-Reitz distribution of microfacet normals with Smith shadowing,
+// its functions take directions in a shading frame with the surface normal along Z
+type ggx struct {
+	alpha float64
+}
+
+// newGGX returns the distribution for the roughness, alpha is the roughness squared so it looks linear
+func newGGX(roughness float64) ggx {
+	return ggx{alpha: math.Max(roughness*roughness, minAlpha)}
+}
+
+// d returns the density of microfacets with the normal m
+func (g ggx) d(m Vector3) float64 {
+	if m.Z <= 0 {
+		return 0
+	}
+	alpha2 := g.alpha * g.alpha
+	t := m.Z*m.Z*(alpha2-1.0) + 1.0
+	return alpha2 / (math.Pi * t * t)
+}
+
+// lambda returns the Smith auxiliary function, the area of microfacets shadowed along w
+func (g ggx) lambda(w Vector3) float64 {
+	cos2 := w.Z * w.Z
+	if cos2 == 0 {
+		return math.Inf(1)
+	}
+	tan2 := (1.0 - cos2) / cos2
+	return (math.Sqrt(1.0+g.alpha*g.alpha*tan2) - 1.0) * 0.5
+}
+
+// g1 returns the fraction of microfacets visible along w
+func (g ggx) g1(w Vector3) float64 {
+	return 1.0 / (1.0 + g.lambda(w))
+}
+
+// g2 returns the fraction of microfacets visible along both directions, with the height-correlated Smith model
+func (g ggx) g2(wo, wi Vector3) float64 {
+	return 1.0 / (1.0 + g.lambda(wo) + g.lambda(wi))
+}
+
+// sampleVisibleNormal returns a microfacet normal sampled proportionally to how much of it is visible along wo
+// Source: Heitz 2018, Sampling the GGX Distribution of Visible Normals
+func (g ggx) sampleVisibleNormal(wo Vector3, u1, u2 float64) Vector3 {
+	// stretch the view so the distribution becomes a hemisphere
+	vh := Vector3{g.alpha * wo.X, g.alpha * wo.Y, wo.Z}.Unit()
+	t1 := Vector3{1, 0, 0}
+	if lengthSquared := vh.X*vh.X + vh.Y*vh.Y; lengthSquared > 0 {
+		t1 = Vector3{-vh.Y, vh.X, 0}.Scale(1.0 / math.Sqrt(lengthSquared))
+	}
+	t2 := vh.Cross(t1)
+
+	// sample the projected area of the hemisphere
+	radius := math.Sqrt(u1)
+	phi := 2.0 * math.Pi * u2
+	p1 := radius * math.Cos(phi)
+	p2 := radius * math.Sin(phi)
+	s := 0.5 * (1.0 + vh.Z)
+	p2 = (1.0-s)*math.Sqrt(1.0-p1*p1) + s*p2
+	nh := t1.Scale(p1).Add(t2.Scale(p2)).Add(vh.Scale(math.Sqrt(math.Max(0, 1.0-p1*p1-p2*p2))))
+
+	// unstretch the normal back
+	return Vector3{g.alpha * nh.X, g.alpha * nh.Y, math.Max(0, nh.Z)}.Unit()
+}
+
+// sampleReflection returns wo reflected off a microfacet normal sampled from the ones visible along wo,
+// false if the reflection points into the surface
+func (g ggx) sampleReflection(wo Vector3, sampler Sampler) (Vector3, bool) {
+	u1, u2 := sampler.Get2D()
+	m := g.sampleVisibleNormal(wo, u1, u2)
+	wi := wo.Scale(-1).Reflect(m)
+	return wi, wi.Z > 0
+}
+
+// evalReflection returns the BRDF of microfacets that reflect color at normal incidence times the cosine term,
+// and the pdf of sampleReflection choosing wi
+func (g ggx) evalReflection(wo, wi, color Vector3) (Vector3, float64) {
+	if wo.Z <= 0 || wi.Z <= 0 {
+		return Vector3{0, 0, 0}, 0
+	}
+	m := wo.Add(wi).Unit()
+	d := g.d(m)
+	fresnel := schlickFresnel(color, wo.Dot(m))
+	return fresnel.Scale(d * g.g2(wo, wi) / (4.0 * wo.Z)), g.g1(wo) * d / (4.0 * wo.Z)
+}
+
+// sampleDielectric reflects or refracts wo through a microfacet normal sampled from the ones visible along wo,
+// picking reflection with the probability of the Fresnel reflectance
+// eta is the index of refraction below the surface over the one above it
+func (g ggx) sampleDielectric(wo Vector3, eta float64, sampler Sampler) (Vector3, bool) {
+	u1, u2 := sampler.Get2D()
+	m := g.sampleVisibleNormal(wo, u1, u2)
+	cosO := wo.Dot(m)
+	if sampler.Get1D() < fresnelDielectric(cosO, eta) {
+		wi := wo.Scale(-1).Reflect(m)
+		return wi, wi.Z > 0
+	}
+	// Fresnel reflectance is 1 when the ray can't refract, so it's always possible here
+	sin2T := (1.0 - cosO*cosO) / (eta * eta)
+	cosT := math.Sqrt(math.Max(0, 1.0-sin2T))
+	wi := wo.Scale(-1.0 / eta).Add(m.Scale(cosO/eta - cosT))
+	return wi, wi.Z < 0
+}
+
+// evalDielectric returns the BSDF of microfacets between two dielectrics times the cosine term,
+// and the pdf of sampleDielectric choosing wi, directions below the surface are refractions
+// Refracted radiance gets compressed into the smaller solid angle of the denser medium, which scales it by 1 / eta²
+func (g ggx) evalDielectric(wo, wi Vector3, eta float64) (float64, float64) {
+	if wo.Z <= 0 || wi.Z == 0 {
+		return 0, 0
+	}
+	if wi.Z > 0 {
+		m := wo.Add(wi).Unit()
+		d := g.d(m)
+		fresnel := fresnelDielectric(wo.Dot(m), eta)
+		return fresnel * d * g.g2(wo, wi) / (4.0 * wo.Z), fresnel * g.g1(wo) * d / (4.0 * wo.Z)
+	}
+
+	// the microfacet normal that refracts wo into wi
+	m := wo.Add(wi.Scale(eta)).Unit()
+	if m.Z < 0 {
+		m = m.Scale(-1)
+	}
+	cosO, cosI := wo.Dot(m), wi.Dot(m)
+	if cosO <= 0 || cosI >= 0 {
+		return 0, 0
+	}
+	d := g.d(m)
+	transmittance := 1.0 - fresnelDielectric(cosO, eta)
+	denominator := cosO + eta*cosI
+	denominator *= denominator
+	value := transmittance * d * g.g2(wo, wi) * -cosI * cosO / (wo.Z * denominator)
+	pdf := transmittance * g.g1(wo) * cosO * d / wo.Z * eta * eta * -cosI / denominator
+	return value, pdf
+}
+
+// shadingFrame converts directions to and from a frame with the normal along Z
+type shadingFrame struct {
+	u, v, n Vector3
+}
+
+func newShadingFrame(normal Vector3) shadingFrame {
+	u, v := orthonormalBasis(normal)
+	return shadingFrame{u: u, v: v, n: normal}
+}
+
+func (f shadingFrame) toLocal(w Vector3) Vector3 {
+	return Vector3{w.Dot(f.u), w.Dot(f.v), w.Dot(f.n)}
+}
+
+func (f shadingFrame) toWorld(w Vector3) Vector3 {
+	return f.u.Scale(w.X).Add(f.v.Scale(w.Y)).Add(f.n.Scale(w.Z))
+}
+
+// textureOrValue returns the X component of the texture's value at the hit, or the value if there's no texture
+func textureOrValue(t Texture, value float64, h HitRecord) float64 {
+	if t == nil {
+		return value
+	}
+	return Clamp(t.Value(h.U, h.V, h.Point).X, 0, 1)
+}
+
+// schlickFresnel returns the reflectance of a conductor reflecting color at normal incidence
+func schlickFresnel(color Vector3, cosine float64) Vector3 {
+	weight := schlickWeight(cosine)
+	return color.Scale(1.0 - weight).AddScalar(weight)
+}
+
+// schlickWeight returns how much of the reflectance at normal incidence fades to white at cosine to the normal
+func schlickWeight(cosine float64) float64 {
+	return math.Pow(1.0-Clamp(cosine, 0, 1), 5)
+}
+
+// fresnelDielectric returns the exact reflectance of unpolarized light arriving at cosI to the normal,
+// eta is the index of refraction on the far side over the one on the near side
+func fresnelDielectric(cosI, eta float64) float64 {
+	sin2T := (1.0 - cosI*cosI) / (eta * eta)
+	if sin2T >= 1 {
+		return 1 // total internal reflection
+	}
+	cosT := math.Sqrt(1.0 - sin2T)
+	rs := (cosI - eta*cosT) / (cosI + eta*cosT)
+	rp := (eta*cosI - cosT) / (eta*cosI + cosT)
+	return 0.5 * (rs*rs + rp*rp)
+}
@@ -0,0 +1,80 @@
+package raytracer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// bsdfMaterial is a Material that can be evaluated for any direction
+type bsdfMaterial interface {
+	Material
+	BSDF
+}
+
+func TestMicrofacetScatterMatchesEval(t *testing.T) {
+	tests := []struct {
+		bsdf        bsdfMaterial
+		isFrontFace bool
+	}{
+		{RoughConductor{Color: Vector3{0.9, 0.6, 0.3}, Roughness: 0.3}, true},
+		{RoughConductor{Color: Vector3{0.9, 0.6, 0.3}, Roughness: 0.8}, true},
+		{RoughDielectric{IndexOfRefraction: 1.5, Roughness: 0.3}, true},
+		{RoughDielectric{IndexOfRefraction: 1.5, Roughness: 0.3}, false},
+		{RoughDielectric{IndexOfRefraction: 1.5, Roughness: 0.8}, true},
+		{RoughDielectric{IndexOfRefraction: 1.5, Roughness: 0.8}, false},
+	}
+
+	sampler := newIndependentSampler(1)
+	for _, test := range tests {
+		checkScatterMatchesEval(t, test.bsdf, test.isFrontFace, sampler)
+	}
+}
+
+// checkScatterMatchesEval checks that the directions Scatter picks and their weights match the pdf and values of Eval
+func checkScatterMatchesEval(t *testing.T, bsdf bsdfMaterial, isFrontFace bool, sampler Sampler) {
+	t.Helper()
+	name := fmt.Sprintf("%+v hit on the front %v", bsdf, isFrontFace)
+	h := HitRecord{Normal: Vector3{0, 0, 1}, IsFrontFace: isFrontFace, Material: bsdf}
+	for _, angle := range []float64{0, 45, 80} {
+		theta := angle * math.Pi / 180
+		r := Ray{Origin: Vector3{math.Sin(theta), 0, math.Cos(theta)}, Direction: Vector3{-math.Sin(theta), 0, -math.Cos(theta)}}
+
+		// Scatter returns the BSDF times the cosine over the pdf of the direction it picked
+		for i := 0; i < 100; i++ {
+			scattered, attenuation, ok := bsdf.Scatter(r, h, sampler)
+			if !ok {
+				continue
+			}
+			value, pdf := bsdf.Eval(r, h, scattered.Direction)
+			if pdf <= 0 || attenuation.Subtract(value.Scale(1.0/pdf)).Length() > 1e-6*attenuation.Length() {
+				t.Errorf("%s at %v degrees: Scatter weighs %v towards %v, Eval gives %v with pdf %v",
+					name, angle, attenuation, scattered.Direction, value, pdf)
+				break
+			}
+		}
+
+		// The pdf integrates to the fraction of rays that Scatter doesn't absorb, summed over a grid of directions
+		const thetaSteps, phiSteps = 500, 400
+		integral := 0.0
+		for i := 0; i < thetaSteps; i++ {
+			theta := (float64(i) + 0.5) * math.Pi / thetaSteps
+			for j := 0; j < phiSteps; j++ {
+				phi := (float64(j) + 0.5) * 2 * math.Pi / phiSteps
+				direction := Vector3{math.Sin(theta) * math.Cos(phi), math.Sin(theta) * math.Sin(phi), math.Cos(theta)}
+				_, pdf := bsdf.Eval(r, h, direction)
+				integral += pdf * math.Sin(theta) * (math.Pi / thetaSteps) * (2 * math.Pi / phiSteps)
+			}
+		}
+		const samples = 20000
+		scatterCount := 0
+		for i := 0; i < samples; i++ {
+			if _, _, ok := bsdf.Scatter(r, h, sampler); ok {
+				scatterCount++
+			}
+		}
+		if scattered := float64(scatterCount) / samples; math.Abs(integral-scattered) > 0.02 {
+			t.Errorf("%s at %v degrees: pdf integrates to %v, %v of the rays scatter", name, angle, integral, scattered)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package raytracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ObjOptions configures how LoadObj assigns materials
+type ObjOptions struct {
+	// Material is used for faces without a material or with a material that isn't in the .mtl files,
+	// a light gray Lambertian by default
+	Material Material
+	// Overrides replaces the materials of the .mtl files by name
+	Overrides map[string]Material
+}
+
+// mtlMaterial holds the parameters of a WaveFront .mtl material that the raytracer understands
+type mtlMaterial struct {
+	diffuse           Vector3 // Kd
+	specular          Vector3 // Ks
+	emission          Vector3 // Ke
+	specularExponent  float64 // Ns, 0 to 1000
+	indexOfRefraction float64 // Ni
+	dissolve          float64 // d, 1 is opaque
+	illum             int
+	// the PBR extension's Pr, Pm, Ps, Pc and Pcr, nil if missing
+	roughness, metallic, sheen, clearcoat, clearcoatRoughness *float64
+	// diffuseMap is the texture file of map_Kd, relative to the .mtl file
+	diffuseMap string
+}
+
+// LoadObj parses a WaveFront .obj file and assigns the materials of the .mtl files it references to its faces
+// Missing .mtl files are skipped, their faces get options.Material
+func LoadObj(filePath string, options ObjOptions) ([]Triangle, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := parseObjData(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	dir := filepath.Dir(filePath)
+	materials := make(map[string]Material)
+	textures := make(map[string]Texture)
+	for _, library := range data.materialLibraries {
+		mtlPath := resolvePath(dir, library)
+		mtlMaterials, err := readMtl(mtlPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for name, m := range mtlMaterials {
+			material, err := m.toMaterial(filepath.Dir(mtlPath), textures)
+			if err != nil {
+				return nil, fmt.Errorf("%s: material %q: %w", mtlPath, name, err)
+			}
+			materials[name] = material
+		}
+	}
+	for name, material := range options.Overrides {
+		materials[name] = material
+	}
+
+	defaultMaterial := options.Material
+	if defaultMaterial == nil {
+		defaultMaterial = Lambertian{Color: Vector3{0.8, 0.8, 0.8}}
+	}
+	return data.triangulate(materials, defaultMaterial), nil
+}
+
+func readMtl(filePath string) (map[string]mtlMaterial, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	materials, err := parseMtl(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return materials, nil
+}
+
+// parseMtl parses the materials of WaveFront .mtl data by name
+func parseMtl(r io.Reader) (map[string]mtlMaterial, error) {
+	materials := make(map[string]mtlMaterial)
+	var current *mtlMaterial
+	var name string
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "newmtl" {
+			if current != nil {
+				materials[name] = *current
+			}
+			name = strings.Join(fields[1:], " ")
+			current = &mtlMaterial{
+				diffuse:           Vector3{0.8, 0.8, 0.8},
+				indexOfRefraction: 1,
+				dissolve:          1,
+			}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: %s before newmtl", lineNumber, fields[0])
+		}
+
+		var err error
+		switch fields[0] {
+		case "Kd":
+			current.diffuse, err = parseObjVector(fields[1:], 3)
+		case "Ks":
+			current.specular, err = parseObjVector(fields[1:], 3)
+		case "Ke":
+			current.emission, err = parseObjVector(fields[1:], 3)
+		case "Ns":
+			current.specularExponent, err = parseMtlFloat(fields[1:])
+		case "Ni":
+			current.indexOfRefraction, err = parseMtlFloat(fields[1:])
+		case "d":
+			current.dissolve, err = parseMtlFloat(fields[1:])
+		case "Tr":
+			var transparency float64
+			transparency, err = parseMtlFloat(fields[1:])
+			current.dissolve = 1 - transparency
+		case "Pr":
+			current.roughness, err = parseMtlOptionalFloat(fields[1:])
+		case "Pm":
+			current.metallic, err = parseMtlOptionalFloat(fields[1:])
+		case "Ps":
+			current.sheen, err = parseMtlOptionalFloat(fields[1:])
+		case "Pc":
+			current.clearcoat, err = parseMtlOptionalFloat(fields[1:])
+		case "Pcr":
+			current.clearcoatRoughness, err = parseMtlOptionalFloat(fields[1:])
+		case "illum":
+			current.illum, err = strconv.Atoi(strings.Join(fields[1:], " "))
+		case "map_Kd":
+			// Options like -s 1 1 1 come before the file name, which is the last field
+			if len(fields) < 2 {
+				err = fmt.Errorf("map_Kd needs a file name")
+			} else {
+				current.diffuseMap = fields[len(fields)-1]
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		materials[name] = *current
+	}
+	return materials, nil
+}
+
+func parseMtlFloat(fields []string) (float64, error) {
+	if len(fields) != 1 {
+		return 0, fmt.Errorf("expected a number, got %d fields", len(fields))
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", fields[0])
+	}
+	return value, nil
+}
+
+// parseMtlOptionalFloat parses a number of a parameter that has no default
+func parseMtlOptionalFloat(fields []string) (*float64, error) {
+	value, err := parseMtlFloat(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// toMaterial picks the closest material: emissive materials are lights, diffuse only (illum 0 and 1) ones
+// are lambertian and anything else is Principled, with transparent or refractive (illum 4, 6, 7, 9) ones
+// as glass and reflective (illum 3, 5, 8) ones as metals reflecting Ks. textures caches the textures loaded by path
+func (m mtlMaterial) toMaterial(dir string, textures map[string]Texture) (Material, error) {
+	if m.emission.Luminance() > 0 {
+		return Light{Emission: m.emission}, nil
+	}
+	var texture Texture
+	if m.diffuseMap != "" {
+		texturePath := resolvePath(dir, m.diffuseMap)
+		var ok bool
+		if texture, ok = textures[texturePath]; !ok {
+			imageTexture, err := NewImageTexture(texturePath)
+			if err != nil {
+				return nil, err
+			}
+			texture = imageTexture
+			textures[texturePath] = texture
+		}
+	}
+	hasPBR := m.roughness != nil || m.metallic != nil || m.sheen != nil || m.clearcoat != nil
+	if (m.illum == 0 || m.illum == 1) && m.dissolve >= 1 && !hasPBR {
+		return Lambertian{Color: m.diffuse, Texture: texture}, nil
+	}
+
+	// The Phong exponent Ns matches a Beckmann roughness of sqrt(2 / (Ns + 2)), see Walter et al. 2007,
+	// which is about the GGX alpha, the square of the Principled roughness
+	p := Principled{
+		BaseColor:        m.diffuse,
+		BaseColorTexture: texture,
+		Roughness:        math.Pow(2/(math.Max(0, m.specularExponent)+2), 0.25),
+		Specular:         Clamp(m.specular.Luminance(), 0, 1),
+	}
+	switch {
+	case m.dissolve < 1 || m.illum == 4 || m.illum == 6 || m.illum == 7 || m.illum == 9:
+		p.BaseColor, p.BaseColorTexture = Vector3{1, 1, 1}, nil
+		p.Transmission = 1
+		p.IndexOfRefraction = m.indexOfRefraction
+		if p.IndexOfRefraction <= 1 {
+			p.IndexOfRefraction = 1.5 // Glass, for exporters that leave Ni at 1
+		}
+		if m.specularExponent <= 0 {
+			p.Roughness = 0 // Clear glass, for exporters that leave out Ns
+		}
+	case m.illum == 3 || m.illum == 5 || m.illum == 8:
+		p.BaseColor, p.BaseColorTexture = m.specular, nil
+		p.Metallic = 1
+	}
+
+	if m.roughness != nil {
+		p.Roughness = *m.roughness
+	}
+	if m.metallic != nil {
+		p.Metallic = *m.metallic
+	}
+	if m.sheen != nil {
+		p.Sheen = *m.sheen
+	}
+	if m.clearcoat != nil {
+		p.Clearcoat = *m.clearcoat
+	}
+	if m.clearcoatRoughness != nil {
+		p.ClearcoatGloss = 1 - *m.clearcoatRoughness
+	}
+	return p, nil
+}
@@ -0,0 +1,135 @@
+package raytracer
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadObjMatchesSeparateFiles(t *testing.T) {
+	got, err := LoadObj("objs/cornell/cornell_box.obj", ObjOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := readObjs(
+		objMesh{"objs/cornell/bottom_and_back_wall.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+		objMesh{"objs/cornell/ceiling.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+		objMesh{"objs/cornell/big_light.obj", Light{Emission: Vector3{1.0, 1.0, 1.0}}},
+		objMesh{"objs/cornell/cube.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+		objMesh{"objs/cornell/left_wall.obj", Lambertian{Color: Vector3{0.8, 0.3, 0.3}}},
+		objMesh{"objs/cornell/right_wall.obj", Lambertian{Color: Vector3{0.3, 0.8, 0.3}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(convertoToHittables(got), want) {
+		t.Error("cornell_box.obj doesn't match the separate .obj files of the Cornell box")
+	}
+}
+
+func TestMtlMaterials(t *testing.T) {
+	mtl := `newmtl matte
+Kd 0.5 0.6 0.7
+illum 1
+
+newmtl plastic
+Kd 0.5 0.6 0.7
+Ks 0.5 0.5 0.5
+Ns 98
+illum 2
+
+newmtl paint
+Kd 0.1 0.2 0.8
+Pr 0.3
+Pm 0.2
+Pc 1
+Pcr 0.1
+
+newmtl chrome
+Ks 0.9 0.9 0.9
+Ns 998
+illum 3
+
+newmtl glass
+Ni 1.33
+illum 7
+
+newmtl window
+d 0.2
+
+newmtl lamp
+Ke 4 4 4
+`
+	materials, err := parseMtl(strings.NewReader(mtl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Material{
+		"matte":   Lambertian{Color: Vector3{0.5, 0.6, 0.7}},
+		"plastic": Principled{BaseColor: Vector3{0.5, 0.6, 0.7}, Roughness: math.Pow(2.0/100, 0.25), Specular: 0.5},
+		"paint": Principled{BaseColor: Vector3{0.1, 0.2, 0.8}, Metallic: 0.2, Roughness: 0.3,
+			Clearcoat: 1, ClearcoatGloss: 0.9},
+		"chrome": Principled{BaseColor: Vector3{0.9, 0.9, 0.9}, Metallic: 1, Roughness: math.Pow(2.0/1000, 0.25),
+			Specular: Vector3{0.9, 0.9, 0.9}.Luminance()},
+		"glass":  Principled{BaseColor: Vector3{1, 1, 1}, Transmission: 1, IndexOfRefraction: 1.33},
+		"window": Principled{BaseColor: Vector3{1, 1, 1}, Transmission: 1, IndexOfRefraction: 1.5},
+		"lamp":   Light{Emission: Vector3{4, 4, 4}},
+	}
+	if len(materials) != len(want) {
+		t.Errorf("got %d materials, want %d", len(materials), len(want))
+	}
+	for name, wantMaterial := range want {
+		got, err := materials[name].toMaterial("", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, wantMaterial) {
+			t.Errorf("material %q is %#v, want %#v", name, got, wantMaterial)
+		}
+	}
+
+	if _, err := parseMtl(strings.NewReader("newmtl a\nKd 1 1\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("parsing a bad Kd gave error %v, want one on line 2", err)
+	}
+}
+
+func TestLoadObjOverridesAndMissingMtl(t *testing.T) {
+	dir := t.TempDir()
+	obj := `mtllib materials.mtl missing.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+usemtl red
+f 1 2 3
+usemtl blue
+f 1 2 3
+usemtl unknown
+f 1 2 3
+`
+	mtl := "newmtl red\nKd 1 0 0\nnewmtl blue\nKd 0 0 1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "model.obj"), []byte(obj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "materials.mtl"), []byte(mtl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gold := Metal{Color: Vector3{1, 0.8, 0.3}, Glosiness: 0.9}
+	fallback := Lambertian{Color: Vector3{0.1, 0.1, 0.1}}
+	triangles, err := LoadObj(filepath.Join(dir, "model.obj"), ObjOptions{
+		Material:  fallback,
+		Overrides: map[string]Material{"blue": gold},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Material{Lambertian{Color: Vector3{1, 0, 0}}, gold, fallback}
+	for i := range want {
+		if !reflect.DeepEqual(triangles[i].Material, want[i]) {
+			t.Errorf("triangle %d has material %#v, want %#v", i, triangles[i].Material, want[i])
+		}
+	}
+}
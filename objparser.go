@@ -1,105 +1,281 @@
-package main
+package raytracer
 
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 )
 
-// ReadObj parses a WaveFront .obj file
-func ReadObj(filePath string, material Material) []Triangle {
+// objFaceVertex holds the 0-based indices of a face corner, texCoord and normal are -1 when missing
+type objFaceVertex struct {
+	vertex, texCoord, normal int
+}
+
+// objFace is a polygon of a .obj file, smoothingGroup is 0 when smoothing is off
+type objFace struct {
+	corners        []objFaceVertex
+	smoothingGroup int
+	material       string
+}
+
+// objData is the contents of a .obj file before triangulation
+type objData struct {
+	verts, texCoords, normals []Vector3
+	faces                     []objFace
+	// materialLibraries are the .mtl files named by mtllib, relative to the .obj file
+	materialLibraries []string
+}
+
+// objSmoothKey identifies a vertex shared by the faces of a smoothing group
+type objSmoothKey struct {
+	vertex, smoothingGroup int
+}
+
+// ReadObj parses a WaveFront .obj file, all of its faces get the material
+func ReadObj(filePath string, material Material) ([]Triangle, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer file.Close()
 
-	var verts []Vector3
-	var normals []Vector3
-	var triangles []Triangle
+	triangles, err := ParseObj(file, material)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return triangles, nil
+}
 
-	scanner := bufio.NewScanner(file)
+// ParseObj parses WaveFront .obj data, all of its faces get the material
+// Polygons are triangulated as fans. Faces without normals get the normal of the face,
+// or the average normal of the faces sharing the vertex when they're in a smoothing group
+func ParseObj(r io.Reader, material Material) ([]Triangle, error) {
+	data, err := parseObjData(r)
+	if err != nil {
+		return nil, err
+	}
+	return data.triangulate(nil, material), nil
+}
+
+func parseObjData(r io.Reader) (objData, error) {
+	var data objData
+	smoothingGroup := 0
+	material := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
 	for scanner.Scan() {
+		lineNumber++
 		line := scanner.Text()
-		switch {
-		case strings.HasPrefix(line, "v "):
-			verts = append(verts, parseVertex(line))
-		case strings.HasPrefix(line, "vn "):
-			normals = append(normals, parseNormal(line))
-		case strings.HasPrefix(line, "f "):
-			triangles = append(triangles, parseFace(line, verts, normals, material))
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
 		}
-	}
 
+		var err error
+		switch fields[0] {
+		case "v":
+			var vertex Vector3
+			vertex, err = parseObjVector(fields[1:], 3)
+			data.verts = append(data.verts, vertex)
+		case "vt":
+			var texCoord Vector3
+			texCoord, err = parseObjVector(fields[1:], 1)
+			data.texCoords = append(data.texCoords, texCoord)
+		case "vn":
+			var normal Vector3
+			normal, err = parseObjVector(fields[1:], 3)
+			data.normals = append(data.normals, normal)
+		case "f":
+			var face objFace
+			face, err = parseFace(fields[1:], len(data.verts), len(data.texCoords), len(data.normals))
+			face.smoothingGroup = smoothingGroup
+			face.material = material
+			data.faces = append(data.faces, face)
+		case "s":
+			smoothingGroup, err = parseSmoothingGroup(fields[1:])
+		case "usemtl":
+			material = strings.Join(fields[1:], " ")
+		case "mtllib":
+			data.materialLibraries = append(data.materialLibraries, fields[1:]...)
+		}
+		if err != nil {
+			return objData{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return objData{}, err
+	}
+	return data, nil
+}
+
+// parseObjVector parses the components of a v, vt or vn line, at least minComponents are required
+// format: v x y z [w], vt u [v [w]], vn x y z
+// example: v 0.361800 0.276393 0.262860
+func parseObjVector(fields []string, minComponents int) (Vector3, error) {
+	if len(fields) < minComponents {
+		return Vector3{}, fmt.Errorf("expected at least %d numbers, got %d", minComponents, len(fields))
+	}
+	var components [3]float64
+	for i := 0; i < len(fields) && i < 3; i++ {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return Vector3{}, fmt.Errorf("invalid number %q", fields[i])
+		}
+		components[i] = value
 	}
+	return Vector3{components[0], components[1], components[2]}, nil
+}
 
-	fmt.Printf("Parsed %s\n%d triangles\n", filePath, len(triangles))
+// parseFace parses the corners of a polygon
+// format: f v1[/vt1][/vn1] v2[/vt2][/vn2] v3[/vt3][/vn3] ...
+// example: f 5/5/2 6/6/2 7/7/2 or f 1//1 2//1 3//1 4//1
+// note: .obj is 1-indexed, negative indices count back from the last element read so far
+func parseFace(fields []string, vertCount, texCoordCount, normalCount int) (objFace, error) {
+	if len(fields) < 3 {
+		return objFace{}, fmt.Errorf("face has %d vertices, expected at least 3", len(fields))
+	}
+	face := objFace{corners: make([]objFaceVertex, len(fields))}
+	for i, field := range fields {
+		indices := strings.Split(field, "/")
+		if len(indices) > 3 {
+			return objFace{}, fmt.Errorf("invalid face vertex %q", field)
+		}
+		corner := objFaceVertex{vertex: -1, texCoord: -1, normal: -1}
 
-	return triangles
+		var err error
+		if corner.vertex, err = parseObjIndex(indices[0], vertCount); err != nil {
+			return objFace{}, fmt.Errorf("vertex index of %q: %w", field, err)
+		}
+		if len(indices) > 1 && indices[1] != "" {
+			if corner.texCoord, err = parseObjIndex(indices[1], texCoordCount); err != nil {
+				return objFace{}, fmt.Errorf("texture coordinate index of %q: %w", field, err)
+			}
+		}
+		if len(indices) > 2 && indices[2] != "" {
+			if corner.normal, err = parseObjIndex(indices[2], normalCount); err != nil {
+				return objFace{}, fmt.Errorf("normal index of %q: %w", field, err)
+			}
+		}
+		face.corners[i] = corner
+	}
+	return face, nil
 }
 
-// format: v x y z
-// example: v 0.361800 0.276393 0.262860
-func parseVertex(line string) Vector3 {
-	var x, y, z float64
-	_, err := fmt.Sscanf(line, "v %f %f %f", &x, &y, &z)
+// parseObjIndex converts a 1-based or negative relative index into a 0-based index in a list of count elements
+func parseObjIndex(s string, count int) (int, error) {
+	index, err := strconv.Atoi(s)
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("invalid index %q", s)
+	}
+	if index < 0 {
+		index += count
+	} else {
+		index--
+	}
+	if index < 0 || index >= count {
+		return 0, fmt.Errorf("index %s out of range, %d defined so far", s, count)
 	}
-	fmt.Printf("Parsed vertex %v\n", Vector3{x, y, z})
-	return Vector3{x, y, z}
+	return index, nil
 }
 
-// format: vn x y z
-// example: vn 0.1876 -0.7947 0.5774
-func parseNormal(line string) Vector3 {
-	var x, y, z float64
-	_, err := fmt.Sscanf(line, "vn %f %f %f", &x, &y, &z)
+// format: s group or s off
+// example: s 1
+func parseSmoothingGroup(fields []string) (int, error) {
+	if len(fields) != 1 {
+		return 0, fmt.Errorf("expected a smoothing group")
+	}
+	if fields[0] == "off" {
+		return 0, nil
+	}
+	group, err := strconv.Atoi(fields[0])
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("invalid smoothing group %q", fields[0])
 	}
-	return Vector3{x, y, z}
+	return group, nil
 }
 
-// format: f v1/vt1/vn1 v2/vt2/vn2 v3/vt3/vn3
-// example: f 5/5/2 6/6/2 7/7/2
-// note: .obj is 1-indexed
-func parseFace(line string, verts, normals []Vector3, material Material) Triangle {
-	groups := strings.Split(line, " ")
-	if len(groups) > 4 {
-		log.Fatal(".obj models should be triangulated")
+// triangulate splits the faces into triangles and fills in the missing normals
+// Faces get their material from materials by name, or defaultMaterial if it's missing
+func (data objData) triangulate(materials map[string]Material, defaultMaterial Material) []Triangle {
+	faces, verts, texCoords, normals := data.faces, data.verts, data.texCoords, data.normals
+	// Sum the area weighted normals of the faces around every vertex of a smoothing group
+	smoothNormals := make(map[objSmoothKey]Vector3)
+	for _, face := range faces {
+		if face.smoothingGroup == 0 {
+			continue
+		}
+		for i := 1; i+1 < len(face.corners); i++ {
+			corners := [3]objFaceVertex{face.corners[0], face.corners[i], face.corners[i+1]}
+			normal := objTriangleNormal(verts, corners)
+			for _, corner := range corners {
+				key := objSmoothKey{corner.vertex, face.smoothingGroup}
+				smoothNormals[key] = smoothNormals[key].Add(normal)
+			}
+		}
 	}
 
-	vertexIndices := make([]int, 3)
-	normalIndices := make([]int, 3)
-	for i := 1; i < 4; i++ {
-		splitGroup := strings.Split(groups[i], "/")
+	var triangles []Triangle
+	for _, face := range faces {
+		for i := 1; i+1 < len(face.corners); i++ {
+			corners := [3]objFaceVertex{face.corners[0], face.corners[i], face.corners[i+1]}
+			faceNormal := objTriangleNormal(verts, corners)
+			if faceNormal.LengthSquared() == 0 {
+				continue // Degenerate triangle, rays can't hit it
+			}
+			faceNormal = faceNormal.Unit()
 
-		vertexIndex, err1 := strconv.Atoi(splitGroup[0])
-		if err1 != nil {
-			log.Fatal("Couldn't parse vertex index as integer")
-		}
-		vertexIndices[i-1] = vertexIndex
+			var triangleNormals, triangleUVs [3]Vector3
+			hasUVs := true
+			for j, corner := range corners {
+				switch {
+				case corner.normal >= 0:
+					triangleNormals[j] = normals[corner.normal]
+				case face.smoothingGroup != 0:
+					triangleNormals[j] = smoothNormals[objSmoothKey{corner.vertex, face.smoothingGroup}].Unit()
+				default:
+					triangleNormals[j] = faceNormal
+				}
+				if corner.texCoord >= 0 {
+					triangleUVs[j] = texCoords[corner.texCoord]
+				} else {
+					hasUVs = false
+				}
+			}
 
-		normalIndex, err2 := strconv.Atoi(splitGroup[2])
-		if err2 != nil {
-			log.Fatal("Couldn't parse normal index as integer")
+			material, ok := materials[face.material]
+			if !ok {
+				material = defaultMaterial
+			}
+			triangle := Triangle{
+				V0:       verts[corners[0].vertex],
+				V1:       verts[corners[1].vertex],
+				V2:       verts[corners[2].vertex],
+				N0:       triangleNormals[0],
+				N1:       triangleNormals[1],
+				N2:       triangleNormals[2],
+				Material: material,
+			}
+			if hasUVs {
+				triangle.UV0, triangle.UV1, triangle.UV2 = triangleUVs[0], triangleUVs[1], triangleUVs[2]
+			}
+			triangles = append(triangles, triangle)
 		}
-		normalIndices[i-1] = normalIndex
 	}
+	return triangles
+}
 
-	return Triangle{
-		V0:       verts[vertexIndices[0]-1],
-		V1:       verts[vertexIndices[1]-1],
-		V2:       verts[vertexIndices[2]-1],
-		N0:       normals[normalIndices[0]-1],
-		N1:       normals[normalIndices[1]-1],
-		N2:       normals[normalIndices[2]-1],
-		Material: material,
-	}
+// objTriangleNormal returns the normal of the triangle scaled by twice its area,
+// counter-clockwise triangles face the viewer
+func objTriangleNormal(verts []Vector3, corners [3]objFaceVertex) Vector3 {
+	v0 := verts[corners[0].vertex]
+	edge1 := verts[corners[1].vertex].Subtract(v0)
+	edge2 := verts[corners[2].vertex].Subtract(v0)
+	return edge1.Cross(edge2)
 }
@@ -0,0 +1,91 @@
+package raytracer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseObjFaceFormats(t *testing.T) {
+	obj := `# a unit square in the XY plane, written in every face format
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+f 1 2 3 4
+f 1/1 2/2 3/3 4/4
+f 1//1 2//1 3//1 4//1
+f -4/-4/-1 -3/-3/-1 -2/-2/-1 -1/-1/-1
+`
+	triangles, err := ParseObj(strings.NewReader(obj), Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triangles) != 8 {
+		t.Fatalf("got %d triangles, want 8", len(triangles))
+	}
+	for i, tri := range triangles {
+		for _, n := range []Vector3{tri.N0, tri.N1, tri.N2} {
+			if n.Subtract(Vector3{0, 0, 1}).Length() > 1e-9 {
+				t.Errorf("triangle %d has normal %v, want 0 0 1", i, n)
+			}
+		}
+	}
+	// The second triangle of the fan of each quad
+	if want := (Triangle{V0: Vector3{0, 0, 0}, V1: Vector3{1, 1, 0}, V2: Vector3{0, 1, 0}}); triangles[1].V0 != want.V0 ||
+		triangles[1].V1 != want.V1 || triangles[1].V2 != want.V2 {
+		t.Errorf("quad is split into %v %v %v, want %v %v %v",
+			triangles[1].V0, triangles[1].V1, triangles[1].V2, want.V0, want.V1, want.V2)
+	}
+	if triangles[3].UV1 != (Vector3{1, 1, 0}) || triangles[7].UV2 != (Vector3{0, 1, 0}) {
+		t.Errorf("texture coordinates are %v and %v, want 1 1 0 and 0 1 0", triangles[3].UV1, triangles[7].UV2)
+	}
+}
+
+func TestParseObjSmoothingGroups(t *testing.T) {
+	// Two faces folded 90 degrees along the edge from vertex 1 to 2
+	obj := `v 0 0 0
+v 0 1 0
+v 1 0 0
+v 0 0 1
+s 1
+f 1 3 2
+f 1 2 4
+s off
+f 1 3 2
+`
+	triangles, err := ParseObj(strings.NewReader(obj), Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := Vector3{1, 0, 1}.Unit()
+	if triangles[0].N0.Subtract(shared).Length() > 1e-9 || triangles[1].N1.Subtract(shared).Length() > 1e-9 {
+		t.Errorf("smoothed normals on the shared edge are %v and %v, want %v", triangles[0].N0, triangles[1].N1, shared)
+	}
+	if triangles[0].N1 != (Vector3{0, 0, 1}) {
+		t.Errorf("smoothed normal of an unshared vertex is %v, want 0 0 1", triangles[0].N1)
+	}
+	if triangles[2].N0 != (Vector3{0, 0, 1}) {
+		t.Errorf("flat normal is %v, want 0 0 1", triangles[2].N0)
+	}
+}
+
+func TestParseObjErrors(t *testing.T) {
+	tests := []struct{ obj, want string }{
+		{"v 0 0 0\nv 1 0 0\nf 1 2 3\n", "line 3: vertex index of \"3\": index 3 out of range"},
+		{"v 0 0\n", "line 1: expected at least 3 numbers"},
+		{"v 0 0 0\n\nv 1 x 0\n", "line 3: invalid number \"x\""},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1/1 2/2 3/3\n", "line 4: texture coordinate index of \"1/1\""},
+		{"v 0 0 0\nf 1 1\n", "line 2: face has 2 vertices"},
+	}
+	for _, test := range tests {
+		_, err := ParseObj(strings.NewReader(test.obj), Lambertian{})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parsing %q gave error %v, want %q", test.obj, err, test.want)
+		}
+	}
+}
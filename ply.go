@@ -0,0 +1,410 @@
+package raytracer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// plyFormat is the encoding of the elements after the header of a .ply file
+type plyFormat int
+
+const (
+	plyASCII plyFormat = iota
+	plyBinaryLittleEndian
+	plyBinaryBigEndian
+)
+
+// plyProperty is a property of a .ply element, list properties have a countType
+type plyProperty struct {
+	name      string
+	valueType string
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plyTypeSizes are the byte sizes of the .ply property types
+var plyTypeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// ReadPly parses a Stanford .ply file in the ASCII or binary format
+func ReadPly(filePath string, material Material) ([]Triangle, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	triangles, err := ParsePly(file, material)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return triangles, nil
+}
+
+// ParsePly parses Stanford .ply data, polygons are triangulated as fans
+// The vertex normals (nx, ny, nz) and texture coordinates (u, v or s, t) are used when present,
+// missing normals are the average normals of the faces around the vertices
+func ParsePly(r io.Reader, material Material) ([]Triangle, error) {
+	reader := bufio.NewReader(r)
+	format, elements, err := parsePlyHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	values := plyValueReader{reader: reader, format: format}
+	var verts, normals, texCoords []Vector3
+	var faces [][]int
+	for _, element := range elements {
+		switch element.name {
+		case "vertex":
+			verts, normals, texCoords, err = values.readVertices(element)
+		case "face":
+			faces, err = values.readFaces(element, len(verts))
+		default:
+			err = values.skip(element)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s element: %w", element.name, err)
+		}
+	}
+
+	if normals == nil {
+		normals = vertexNormals(verts, faces)
+	}
+	var triangles []Triangle
+	for _, face := range faces {
+		for i := 1; i+1 < len(face); i++ {
+			c := [3]int{face[0], face[i], face[i+1]}
+			tri := Triangle{
+				V0: verts[c[0]], V1: verts[c[1]], V2: verts[c[2]],
+				N0: normals[c[0]], N1: normals[c[1]], N2: normals[c[2]],
+				Material: material,
+			}
+			if tri.V1.Subtract(tri.V0).Cross(tri.V2.Subtract(tri.V0)).LengthSquared() == 0 {
+				continue // Degenerate triangle, rays can't hit it
+			}
+			if texCoords != nil {
+				tri.UV0, tri.UV1, tri.UV2 = texCoords[c[0]], texCoords[c[1]], texCoords[c[2]]
+			}
+			triangles = append(triangles, tri)
+		}
+	}
+	return triangles, nil
+}
+
+// format: ply, format ascii 1.0, element vertex 8, property float x, property list uchar int vertex_indices, end_header
+func parsePlyHeader(reader *bufio.Reader) (plyFormat, []plyElement, error) {
+	var format plyFormat
+	var elements []plyElement
+	formatSeen := false
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, nil, fmt.Errorf("header line %d: %w", lineNumber, err)
+		}
+		fields := strings.Fields(line)
+		if lineNumber == 1 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return 0, nil, fmt.Errorf("not a .ply file")
+			}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return 0, nil, fmt.Errorf("header line %d: expected format and version", lineNumber)
+			}
+			switch fields[1] {
+			case "ascii":
+				format = plyASCII
+			case "binary_little_endian":
+				format = plyBinaryLittleEndian
+			case "binary_big_endian":
+				format = plyBinaryBigEndian
+			default:
+				return 0, nil, fmt.Errorf("header line %d: unknown format %q", lineNumber, fields[1])
+			}
+			formatSeen = true
+		case "element":
+			if len(fields) != 3 {
+				return 0, nil, fmt.Errorf("header line %d: expected element name and count", lineNumber)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return 0, nil, fmt.Errorf("header line %d: invalid element count %q", lineNumber, fields[2])
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return 0, nil, fmt.Errorf("header line %d: property before element", lineNumber)
+			}
+			property, err := parsePlyProperty(fields[1:])
+			if err != nil {
+				return 0, nil, fmt.Errorf("header line %d: %w", lineNumber, err)
+			}
+			element := &elements[len(elements)-1]
+			element.properties = append(element.properties, property)
+		case "end_header":
+			if !formatSeen {
+				return 0, nil, fmt.Errorf("header has no format")
+			}
+			return format, elements, nil
+		}
+	}
+}
+
+// format: property type name or property list countType valueType name
+func parsePlyProperty(fields []string) (plyProperty, error) {
+	var property plyProperty
+	switch {
+	case len(fields) == 2 && fields[0] != "list":
+		property = plyProperty{name: fields[1], valueType: fields[0]}
+	case len(fields) == 4 && fields[0] == "list":
+		property = plyProperty{name: fields[3], valueType: fields[2], countType: fields[1]}
+		if _, ok := plyTypeSizes[property.countType]; !ok {
+			return plyProperty{}, fmt.Errorf("unknown type %q", property.countType)
+		}
+	default:
+		return plyProperty{}, fmt.Errorf("invalid property")
+	}
+	if _, ok := plyTypeSizes[property.valueType]; !ok {
+		return plyProperty{}, fmt.Errorf("unknown type %q", property.valueType)
+	}
+	return property, nil
+}
+
+// plyValueReader reads the values of elements in the file's format
+type plyValueReader struct {
+	reader *bufio.Reader
+	format plyFormat
+	buffer [8]byte
+}
+
+func (p *plyValueReader) read(valueType string) (float64, error) {
+	if p.format == plyASCII {
+		word, err := p.readWord()
+		if err != nil {
+			return 0, err
+		}
+		value, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", word)
+		}
+		return value, nil
+	}
+
+	data := p.buffer[:plyTypeSizes[valueType]]
+	if _, err := io.ReadFull(p.reader, data); err != nil {
+		return 0, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if p.format == plyBinaryBigEndian {
+		order = binary.BigEndian
+	}
+	switch valueType {
+	case "char", "int8":
+		return float64(int8(data[0])), nil
+	case "uchar", "uint8":
+		return float64(data[0]), nil
+	case "short", "int16":
+		return float64(int16(order.Uint16(data))), nil
+	case "ushort", "uint16":
+		return float64(order.Uint16(data)), nil
+	case "int", "int32":
+		return float64(int32(order.Uint32(data))), nil
+	case "uint", "uint32":
+		return float64(order.Uint32(data)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(data))), nil
+	default:
+		return math.Float64frombits(order.Uint64(data)), nil
+	}
+}
+
+// readWord returns the next whitespace separated word of an ASCII .ply file
+func (p *plyValueReader) readWord() (string, error) {
+	var word []byte
+	for {
+		c, err := p.reader.ReadByte()
+		if err == io.EOF && len(word) > 0 {
+			return string(word), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if len(word) > 0 {
+				return string(word), nil
+			}
+			continue
+		}
+		word = append(word, c)
+	}
+}
+
+// readProperty returns the value of the property, or all of the values of a list property
+// The values are appended as they're read, a truncated file can't make it allocate the length it claims
+func (p *plyValueReader) readProperty(property plyProperty) ([]float64, error) {
+	count := 1
+	if property.countType != "" {
+		value, err := p.read(property.countType)
+		if err != nil {
+			return nil, err
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("negative list length")
+		}
+		count = int(value)
+	}
+	var values []float64
+	for i := 0; i < count; i++ {
+		value, err := p.read(property.valueType)
+		if err != nil && property.countType != "" {
+			return nil, fmt.Errorf("list value %d of %d: %w", i, count, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (p *plyValueReader) skip(element plyElement) error {
+	for i := 0; i < element.count; i++ {
+		for _, property := range element.properties {
+			if _, err := p.readProperty(property); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readVertices returns the positions of the vertices, and their normals and texture coordinates if they have them
+// Like the other elements they're appended as they're read rather than allocated for the count in the header
+func (p *plyValueReader) readVertices(element plyElement) ([]Vector3, []Vector3, []Vector3, error) {
+	has := make(map[string]bool)
+	for _, property := range element.properties {
+		has[property.name] = true
+	}
+	if !has["x"] || !has["y"] || !has["z"] {
+		return nil, nil, nil, fmt.Errorf("vertices need x, y and z")
+	}
+	hasNormals := has["nx"] && has["ny"] && has["nz"]
+	uName, vName := "", ""
+	for _, names := range [][2]string{{"u", "v"}, {"s", "t"}, {"texture_u", "texture_v"}} {
+		if has[names[0]] && has[names[1]] {
+			uName, vName = names[0], names[1]
+			break
+		}
+	}
+
+	var verts, normals, texCoords []Vector3
+	for i := 0; i < element.count; i++ {
+		verts = append(verts, Vector3{})
+		if hasNormals {
+			normals = append(normals, Vector3{})
+		}
+		if uName != "" {
+			texCoords = append(texCoords, Vector3{})
+		}
+		for _, property := range element.properties {
+			values, err := p.readProperty(property)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("vertex %d: %w", i, err)
+			}
+			if property.countType != "" {
+				continue
+			}
+			value := values[0]
+			switch property.name {
+			case "x":
+				verts[i].X = value
+			case "y":
+				verts[i].Y = value
+			case "z":
+				verts[i].Z = value
+			case "nx", "ny", "nz":
+				if hasNormals {
+					normals[i] = normals[i].setAxis(int(property.name[1]-'x'), value)
+				}
+			case uName:
+				texCoords[i].X = value
+			case vName:
+				texCoords[i].Y = value
+			}
+		}
+	}
+	return verts, normals, texCoords, nil
+}
+
+// readFaces returns the vertex indices of the faces' corners
+func (p *plyValueReader) readFaces(element plyElement, vertCount int) ([][]int, error) {
+	var faces [][]int
+	for i := 0; i < element.count; i++ {
+		var face []int
+		for _, property := range element.properties {
+			values, err := p.readProperty(property)
+			if err != nil {
+				return nil, fmt.Errorf("face %d: %w", i, err)
+			}
+			if property.name != "vertex_indices" && property.name != "vertex_index" {
+				continue
+			}
+			if property.countType == "" {
+				return nil, fmt.Errorf("%s isn't a list", property.name)
+			}
+			face = make([]int, len(values))
+			for j, value := range values {
+				if value < 0 || int(value) >= vertCount {
+					return nil, fmt.Errorf("face %d: vertex index %v out of range, %d vertices", i, value, vertCount)
+				}
+				face[j] = int(value)
+			}
+		}
+		if len(face) >= 3 {
+			faces = append(faces, face)
+		}
+	}
+	return faces, nil
+}
+
+// vertexNormals returns the area weighted average normals of the faces around every vertex
+func vertexNormals(verts []Vector3, faces [][]int) []Vector3 {
+	normals := make([]Vector3, len(verts))
+	for _, face := range faces {
+		for i := 1; i+1 < len(face); i++ {
+			v0 := verts[face[0]]
+			normal := verts[face[i]].Subtract(v0).Cross(verts[face[i+1]].Subtract(v0))
+			for _, corner := range []int{face[0], face[i], face[i+1]} {
+				normals[corner] = normals[corner].Add(normal)
+			}
+		}
+	}
+	for i := range normals {
+		if normals[i].LengthSquared() > 0 {
+			normals[i] = normals[i].Unit()
+		}
+	}
+	return normals
+}
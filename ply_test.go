@@ -0,0 +1,170 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// plyQuadHeader is the header of a unit square in the XY plane with normals, texture coordinates,
+// a property and an element that aren't used
+const plyQuadHeader = `ply
+format %s 1.0
+comment written by hand
+element vertex 4
+property float x
+property float y
+property float z
+property float confidence
+property float nx
+property float ny
+property float nz
+property float u
+property float v
+element face 1
+property list uchar int vertex_indices
+element edge 1
+property int vertex1
+property int vertex2
+end_header
+`
+
+func TestParsePlyFormats(t *testing.T) {
+	verts := [][9]float32{
+		{0, 0, 0, 0.5, 0, 0, 1, 0, 0},
+		{1, 0, 0, 0.5, 0, 0, 1, 1, 0},
+		{1, 1, 0, 0.5, 0, 0, 1, 1, 1},
+		{0, 1, 0, 0.5, 0, 0, 1, 0, 1},
+	}
+	ascii := strings.Replace(plyQuadHeader, "%s", "ascii", 1) + `0 0 0 0.5 0 0 1 0 0
+1 0 0 0.5 0 0 1 1 0
+1 1 0 0.5 0 0 1 1 1
+0 1 0 0.5 0 0 1 0 1
+4 0 1 2 3
+0 2
+`
+	triangles, err := ParsePly(strings.NewReader(ascii), Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triangles) != 2 {
+		t.Fatalf("got %d triangles, want 2", len(triangles))
+	}
+	want := Triangle{
+		V0: Vector3{0, 0, 0}, V1: Vector3{1, 1, 0}, V2: Vector3{0, 1, 0},
+		N0: Vector3{0, 0, 1}, N1: Vector3{0, 0, 1}, N2: Vector3{0, 0, 1},
+		UV0: Vector3{0, 0, 0}, UV1: Vector3{1, 1, 0}, UV2: Vector3{0, 1, 0},
+		Material: Lambertian{},
+	}
+	if triangles[1] != want {
+		t.Errorf("second triangle of the quad is %v, want %v", triangles[1], want)
+	}
+
+	for _, test := range []struct {
+		format string
+		order  binary.ByteOrder
+	}{
+		{"binary_little_endian", binary.LittleEndian},
+		{"binary_big_endian", binary.BigEndian},
+	} {
+		var data bytes.Buffer
+		data.WriteString(strings.Replace(plyQuadHeader, "%s", test.format, 1))
+		binary.Write(&data, test.order, verts)
+		binary.Write(&data, test.order, uint8(4))
+		binary.Write(&data, test.order, []int32{0, 1, 2, 3})
+		binary.Write(&data, test.order, []int32{0, 2})
+
+		got, err := ParsePly(&data, Lambertian{})
+		if err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, triangles) {
+			t.Errorf("%s triangles differ from the ascii ones", test.format)
+		}
+	}
+}
+
+func TestParsePlyComputedNormals(t *testing.T) {
+	// Two faces folded 90 degrees along the edge from vertex 0 to 1
+	ply := `ply
+format ascii 1.0
+element vertex 4
+property double x
+property double y
+property double z
+element face 2
+property list uchar uint vertex_index
+end_header
+0 0 0
+0 1 0
+1 0 0
+0 0 1
+3 0 2 1
+3 0 1 3
+`
+	triangles, err := ParsePly(strings.NewReader(ply), Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := Vector3{1, 0, 1}.Unit()
+	if triangles[0].N0.Subtract(shared).Length() > 1e-9 || triangles[1].N1.Subtract(shared).Length() > 1e-9 {
+		t.Errorf("normals on the shared edge are %v and %v, want %v", triangles[0].N0, triangles[1].N1, shared)
+	}
+	if triangles[0].N1 != (Vector3{0, 0, 1}) {
+		t.Errorf("normal of an unshared vertex is %v, want 0 0 1", triangles[0].N1)
+	}
+}
+
+func TestParsePlyErrors(t *testing.T) {
+	header := "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list uchar int vertex_indices\nend_header\n"
+	tests := []struct{ ply, want string }{
+		{"obj\n", "not a .ply file"},
+		{"ply\nformat binary_middle_endian 1.0\nend_header\n", "header line 2: unknown format"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty half x\nend_header\n", "header line 4: unknown type \"half\""},
+		{header + "0 0 0\n1 0 0\n0 1 0\n3 0 1 3\n", "face 0: vertex index 3 out of range"},
+		{header + "0 0 0\n1 0 0\n0 1\n", "vertex element: vertex 2: EOF"},
+		{header + "0 0 0\n1 0 0\n0 one 0\n", "invalid number \"one\""},
+	}
+	for _, test := range tests {
+		_, err := ParsePly(strings.NewReader(test.ply), Lambertian{})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parsing %q gave error %v, want %q", test.ply, err, test.want)
+		}
+	}
+}
+
+// TestParsePlyTruncated parses binary files that end long before the counts in them, which fail without allocating
+// memory for the counts
+func TestParsePlyTruncated(t *testing.T) {
+	header := "ply\nformat binary_little_endian 1.0\nelement vertex %s\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list uint int vertex_indices\nend_header\n"
+	var vertex bytes.Buffer
+	binary.Write(&vertex, binary.LittleEndian, []float32{0, 0, 0})
+	tests := []struct {
+		name, header string
+		data         []byte
+		want         string
+	}{
+		{"vertices", strings.Replace(header, "%s", "2000000000", 1), vertex.Bytes(), "vertex element: vertex 1: EOF"},
+		// a face of 4 billion corners after a single vertex
+		{"list", strings.Replace(header, "%s", "1", 1), append(vertex.Bytes(), 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0),
+			"face element: face 0: list value 1 of 4294967295: EOF"},
+	}
+	for _, test := range tests {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := ParsePly(bytes.NewReader(append([]byte(test.header), test.data...)), Lambertian{})
+		runtime.ReadMemStats(&after)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: parsing allocated %d bytes", test.name, allocated)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// Principled is a Disney style material that blends a diffuse base, a metal, rough glass and a clear coat,
+// with parameters from 0 to 1 like Blender's Principled BSDF
+// Source: Burley 2012, Physically-Based Shading at Disney,
+// and Burley 2015, Extending the Disney BRDF to a BSDF with Integrated Subsurface Scattering
+type Principled struct {
+	BaseColor Vector3
+	// BaseColorTexture overrides BaseColor if set
+	BaseColorTexture Texture
+	// Metallic blends from a dielectric to a metal reflecting the base color
+	Metallic float64
+	// MetallicTexture overrides Metallic if set, its X component is used
+	MetallicTexture Texture
+	Roughness       float64
+	// RoughnessTexture overrides Roughness if set, its X component is used
+	RoughnessTexture Texture
+	// Specular scales the reflectance of dielectrics, 0.5 is the 4% at normal incidence of most of them
+	Specular float64
+	// SpecularTint tints the reflection of dielectrics towards the base color
+	SpecularTint float64
+	// Sheen adds a soft reflection at grazing angles like that of cloth, SheenTint tints it towards the base color
+	Sheen, SheenTint float64
+	// Clearcoat adds a white specular layer on top, ClearcoatGloss goes from a satin to a glossy coat
+	Clearcoat, ClearcoatGloss float64
+	// Transmission turns the dielectric part into rough glass whose refractions are tinted by the base color
+	Transmission float64
+	// IndexOfRefraction of the glass, 1.5 if it's 0
+	IndexOfRefraction float64
+	// Emission is light given off on top of the reflected light, components can be > 1.0
+	// Emissive spheres and triangles are sampled as lights like those with a Light material
+	Emission Vector3
+}
+
+// Scatter picks one of the lobes and samples a direction from it, the attenuation weighs the direction
+// by the pdf of all of the lobes choosing it
+func (p Principled) Scatter(r Ray, h HitRecord, sampler Sampler) (*Ray, Vector3, bool) {
+	f := newShadingFrame(h.Normal)
+	wo := f.toLocal(r.Direction.Unit().Scale(-1))
+	if wo.Z <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	l := p.lobes(wo, h)
+	wi, ok := l.sample(wo, sampler)
+	if !ok {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	value, pdf := l.eval(wo, wi)
+	if pdf <= 0 {
+		return nil, Vector3{0, 0, 0}, false
+	}
+	scatteredRay := Ray{Origin: h.Point, Direction: f.toWorld(wi)}
+	return &scatteredRay, value.Scale(1.0 / pdf), true
+}
+
+// Eval returns the sum of the lobes times the cosine term and the pdf of Scatter choosing direction
+func (p Principled) Eval(r Ray, h HitRecord, direction Vector3) (Vector3, float64) {
+	f := newShadingFrame(h.Normal)
+	wo := f.toLocal(r.Direction.Unit().Scale(-1))
+	if wo.Z <= 0 {
+		return Vector3{0, 0, 0}, 0
+	}
+	return p.lobes(wo, h).eval(wo, f.toLocal(direction.Unit()))
+}
+
+// Emit returns the material's emission, black unless Emission is set
+func (p Principled) Emit(r Ray, h HitRecord, sampler Sampler) Vector3 {
+	return p.Emission
+}
+
+// emission returns the light the material gives off
+func (p Principled) emission() Vector3 {
+	return p.Emission
+}
+
+// albedo returns the base color at the hit
+func (p Principled) albedo(h HitRecord) Vector3 {
+	return textureOrColor(p.BaseColorTexture, p.BaseColor, h)
+}
+
+// principledLobes are the parts of a Principled material at a hit, in the shading frame of the hit
+type principledLobes struct {
+	baseColor     Vector3
+	roughness     float64
+	specularColor Vector3
+	sheenColor    Vector3
+	eta           float64
+	distribution  ggx
+	// the clear coat has its own distribution of normals, with the shadowing of a fixed roughness
+	clearcoatAlpha     float64
+	clearcoatShadowing ggx
+
+	diffuseWeight, specularWeight, glassWeight, clearcoatWeight float64
+	// the probabilities of sampling each lobe
+	diffuseProb, specularProb, glassProb, clearcoatProb float64
+}
+
+// lobes returns the lobes of the material at the hit, wo is the direction back along the ray
+func (p Principled) lobes(wo Vector3, h HitRecord) principledLobes {
+	baseColor := textureOrColor(p.BaseColorTexture, p.BaseColor, h)
+	metallic := Clamp(textureOrValue(p.MetallicTexture, p.Metallic, h), 0, 1)
+	roughness := Clamp(textureOrValue(p.RoughnessTexture, p.Roughness, h), 0, 1)
+	transmission := Clamp(p.Transmission, 0, 1)
+
+	white := Vector3{1, 1, 1}
+	tint := white
+	if luminance := baseColor.Luminance(); luminance > 0 {
+		tint = baseColor.Scale(1.0 / luminance)
+	}
+	dielectricColor := lerpVector(white, tint, p.SpecularTint).Scale(0.08 * p.Specular)
+	indexOfRefraction := p.IndexOfRefraction
+	if indexOfRefraction == 0 {
+		indexOfRefraction = 1.5
+	}
+
+	l := principledLobes{
+		baseColor:          baseColor,
+		roughness:          roughness,
+		specularColor:      lerpVector(dielectricColor, baseColor, metallic),
+		sheenColor:         lerpVector(white, tint, p.SheenTint).Scale(p.Sheen),
+		eta:                refractionRatio(indexOfRefraction, h),
+		distribution:       newGGX(roughness),
+		clearcoatAlpha:     0.1 + (0.001-0.1)*Clamp(p.ClearcoatGloss, 0, 1),
+		clearcoatShadowing: ggx{alpha: 0.25},
+
+		diffuseWeight:   (1.0 - metallic) * (1.0 - transmission),
+		specularWeight:  1.0 - (1.0-metallic)*transmission,
+		glassWeight:     (1.0 - metallic) * transmission,
+		clearcoatWeight: 0.25 * Clamp(p.Clearcoat, 0, 1),
+	}
+
+	// the specular lobe is sampled about as often as it reflects, but not so rarely that highlights get noisy
+	specularAlbedo := Clamp(schlickFresnel(l.specularColor, wo.Z).Luminance(), 0.1, 1)
+	l.diffuseProb = l.diffuseWeight
+	l.specularProb = l.specularWeight * specularAlbedo
+	l.glassProb = l.glassWeight
+	l.clearcoatProb = l.clearcoatWeight
+	total := l.diffuseProb + l.specularProb + l.glassProb + l.clearcoatProb
+	l.diffuseProb /= total
+	l.specularProb /= total
+	l.glassProb /= total
+	l.clearcoatProb /= total
+	return l
+}
+
+// sample returns a direction sampled from a lobe picked by its probability, false if it points nowhere useful
+func (l principledLobes) sample(wo Vector3, sampler Sampler) (Vector3, bool) {
+	u := sampler.Get1D()
+	switch {
+	case u < l.diffuseProb:
+		return cosineDirection(sampler.Get2D()), true
+	case u < l.diffuseProb+l.specularProb:
+		return l.distribution.sampleReflection(wo, sampler)
+	case u < l.diffuseProb+l.specularProb+l.glassProb:
+		return l.distribution.sampleDielectric(wo, l.eta, sampler)
+	default:
+		u1, u2 := sampler.Get2D()
+		m := sampleGTR1(l.clearcoatAlpha, u1, u2)
+		wi := wo.Scale(-1).Reflect(m)
+		return wi, wi.Z > 0
+	}
+}
+
+// eval returns the sum of the weighted lobes times the cosine term,
+// and the pdf of sample choosing wi through any of the lobes
+func (l principledLobes) eval(wo, wi Vector3) (Vector3, float64) {
+	value := Vector3{0, 0, 0}
+	pdf := 0.0
+	if wi.Z > 0 {
+		if l.diffuseWeight > 0 {
+			value = value.Add(l.diffuse(wo, wi).Scale(l.diffuseWeight))
+			pdf += l.diffuseProb * wi.Z / math.Pi
+		}
+		if l.specularWeight > 0 {
+			specular, specularPDF := l.distribution.evalReflection(wo, wi, l.specularColor)
+			value = value.Add(specular.Scale(l.specularWeight))
+			pdf += l.specularProb * specularPDF
+		}
+		if l.clearcoatWeight > 0 {
+			clearcoat, clearcoatPDF := l.evalClearcoat(wo, wi)
+			value = value.AddScalar(clearcoat * l.clearcoatWeight)
+			pdf += l.clearcoatProb * clearcoatPDF
+		}
+	}
+	if l.glassWeight > 0 {
+		glass, glassPDF := l.distribution.evalDielectric(wo, wi, l.eta)
+		color := Vector3{1, 1, 1}
+		if wi.Z < 0 {
+			color = l.baseColor
+		}
+		value = value.Add(color.Scale(glass * l.glassWeight))
+		pdf += l.glassProb * glassPDF
+	}
+	return value, pdf
+}
+
+// diffuse returns Burley's diffuse, which gets brighter at grazing angles on rough surfaces, and the sheen
+// times the cosine term
+func (l principledLobes) diffuse(wo, wi Vector3) Vector3 {
+	cosD := wi.Dot(wo.Add(wi).Unit())
+	fd90 := 0.5 + 2.0*cosD*cosD*l.roughness
+	fd := (1.0 + (fd90-1.0)*schlickWeight(wi.Z)) * (1.0 + (fd90-1.0)*schlickWeight(wo.Z))
+	sheen := l.sheenColor.Scale(schlickWeight(cosD))
+	return l.baseColor.Scale(fd / math.Pi).Add(sheen).Scale(wi.Z)
+}
+
+// evalClearcoat returns the clear coat's BRDF times the cosine term and the pdf of sampling its reflection
+func (l principledLobes) evalClearcoat(wo, wi Vector3) (float64, float64) {
+	m := wo.Add(wi).Unit()
+	cosO := wo.Dot(m)
+	if cosO <= 0 {
+		return 0, 0
+	}
+	d := gtr1(m.Z, l.clearcoatAlpha)
+	fresnel := 0.04 + 0.96*schlickWeight(cosO)
+	return fresnel * d * l.clearcoatShadowing.g2(wo, wi) / (4.0 * wo.Z), d * m.Z / (4.0 * cosO)
+}
+
+// gtr1 returns the density of the clear coat's microfacet normals at cosM to the normal,
+// the generalized Trowbridge-Reitz distribution with gamma 1 has a longer tail than GGX
+func gtr1(cosM, alpha float64) float64 {
+	if cosM <= 0 {
+		return 0
+	}
+	alpha2 := alpha * alpha
+	t := 1.0 + (alpha2-1.0)*cosM*cosM
+	return (alpha2 - 1.0) / (math.Pi * math.Log(alpha2) * t)
+}
+
+// sampleGTR1 returns a microfacet normal sampled proportionally to gtr1 times the cosine to the normal
+func sampleGTR1(alpha, u1, u2 float64) Vector3 {
+	alpha2 := alpha * alpha
+	cos2 := (1.0 - math.Pow(alpha2, 1.0-u1)) / (1.0 - alpha2)
+	sinM := math.Sqrt(math.Max(0, 1.0-cos2))
+	phi := 2.0 * math.Pi * u2
+	return Vector3{sinM * math.Cos(phi), sinM * math.Sin(phi), math.Sqrt(cos2)}
+}
+
+// cosineDirection returns a direction around Z sampled proportionally to the cosine to Z
+func cosineDirection(u1, u2 float64) Vector3 {
+	radius := math.Sqrt(u1)
+	phi := 2.0 * math.Pi * u2
+	return Vector3{radius * math.Cos(phi), radius * math.Sin(phi), math.Sqrt(math.Max(0, 1.0-u1))}
+}
+
+// lerpVector returns the linear interpolation from a to b by t
+func lerpVector(a, b Vector3, t float64) Vector3 {
+	return a.Scale(1.0 - t).Add(b.Scale(t))
+}
@@ -0,0 +1,59 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPrincipledScatterMatchesEval(t *testing.T) {
+	tests := []struct {
+		material    Principled
+		isFrontFace bool
+	}{
+		{Principled{BaseColor: Vector3{0.8, 0.2, 0.2}, Roughness: 0.5, Specular: 0.5}, true},
+		{Principled{BaseColor: Vector3{0.9, 0.6, 0.3}, Metallic: 1, Roughness: 0.4}, true},
+		{Principled{BaseColor: Vector3{0.2, 0.3, 0.8}, Metallic: 0.5, Roughness: 0.6, Specular: 0.5, SpecularTint: 1}, true},
+		{Principled{BaseColor: Vector3{0.1, 0.1, 0.1}, Roughness: 0.9, Sheen: 1, SheenTint: 0.5, Clearcoat: 1, ClearcoatGloss: 0.5}, true},
+		{Principled{BaseColor: Vector3{1, 1, 1}, Roughness: 0.4, Specular: 0.5, Transmission: 1, IndexOfRefraction: 1.5}, true},
+		{Principled{BaseColor: Vector3{0.9, 1, 0.9}, Roughness: 0.5, Specular: 0.5, Transmission: 0.5}, false},
+	}
+	sampler := newIndependentSampler(1)
+	for _, test := range tests {
+		checkScatterMatchesEval(t, test.material, test.isFrontFace, sampler)
+	}
+}
+
+func TestPrincipledAlbedo(t *testing.T) {
+	// A white metal reflects almost all of the light, white glass reflects by the Fresnel reflectance
+	// and lets the rest in, compressed into the smaller solid angle inside by 1 / 1.5²
+	glassAlbedo := func(cosine float64) float64 {
+		fresnel := fresnelDielectric(cosine, 1.5)
+		return fresnel + (1-fresnel)/(1.5*1.5)
+	}
+	tests := []struct {
+		material Principled
+		angle    float64
+		want     float64
+	}{
+		{Principled{BaseColor: Vector3{1, 1, 1}, Roughness: 0.2, Metallic: 1}, 0, 1},
+		{Principled{BaseColor: Vector3{1, 1, 1}, Roughness: 0.2, Metallic: 1}, 60, 1},
+		{Principled{BaseColor: Vector3{1, 1, 1}, Roughness: 0.2, Transmission: 1}, 0, glassAlbedo(1)},
+		{Principled{BaseColor: Vector3{1, 1, 1}, Roughness: 0.2, Transmission: 1}, 60, glassAlbedo(0.5)},
+	}
+	sampler := newIndependentSampler(1)
+	h := HitRecord{Normal: Vector3{0, 0, 1}, IsFrontFace: true}
+	for _, test := range tests {
+		theta := test.angle * math.Pi / 180
+		r := Ray{Origin: Vector3{math.Sin(theta), 0, math.Cos(theta)}, Direction: Vector3{-math.Sin(theta), 0, -math.Cos(theta)}}
+		const samples = 20000
+		albedo := 0.0
+		for i := 0; i < samples; i++ {
+			if _, attenuation, ok := test.material.Scatter(r, h, sampler); ok {
+				albedo += attenuation.Luminance() / samples
+			}
+		}
+		if math.Abs(albedo-test.want) > 0.03 {
+			t.Errorf("%+v at %v degrees scatters %v of the light, want %v", test.material, test.angle, albedo, test.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// DeltaLight is a light without area, like a point light, that rays can't hit
+// It only lights the world through light sampling, so it needs Options.LightSampling
+type DeltaLight interface {
+	// illuminate returns the unit direction from point towards the light, the distance to the light
+	// and the light arriving at point from that direction
+	illuminate(point Vector3) (Vector3, float64, Vector3)
+	// power returns the light's emitted power, a directional light lights a disk of worldRadius
+	power(worldRadius float64) float64
+}
+
+// PointLight shines Intensity in all directions from Position
+type PointLight struct {
+	Position  Vector3
+	Intensity Vector3
+}
+
+func (l PointLight) illuminate(point Vector3) (Vector3, float64, Vector3) {
+	toLight := l.Position.Subtract(point)
+	distance := toLight.Length()
+	if distance == 0 {
+		return Vector3{0, 0, 0}, 0, Vector3{0, 0, 0}
+	}
+	return toLight.Scale(1.0 / distance), distance, l.Intensity.Scale(1.0 / (distance * distance))
+}
+
+func (l PointLight) power(worldRadius float64) float64 {
+	return 4.0 * math.Pi * l.Intensity.Luminance()
+}
+
+// SpotLight shines Intensity from Position in a cone around Direction
+// The light fades out from InnerConeAngle to OuterConeAngle, both in degrees from Direction
+type SpotLight struct {
+	Position, Direction            Vector3
+	Intensity                      Vector3
+	InnerConeAngle, OuterConeAngle float64
+}
+
+func (l SpotLight) illuminate(point Vector3) (Vector3, float64, Vector3) {
+	toLight := l.Position.Subtract(point)
+	distance := toLight.Length()
+	if distance == 0 {
+		return Vector3{0, 0, 0}, 0, Vector3{0, 0, 0}
+	}
+	direction := toLight.Scale(1.0 / distance)
+	cosine := -direction.Dot(l.Direction.Unit())
+
+	// the falloff of the glTF KHR_lights_punctual extension
+	cosOuter := math.Cos(l.OuterConeAngle * math.Pi / 180.0)
+	cosInner := math.Cos(l.InnerConeAngle * math.Pi / 180.0)
+	falloff := Clamp((cosine-cosOuter)/math.Max(cosInner-cosOuter, 1e-4), 0, 1)
+	return direction, distance, l.Intensity.Scale(falloff * falloff / (distance * distance))
+}
+
+func (l SpotLight) power(worldRadius float64) float64 {
+	cosMiddle := math.Cos((l.InnerConeAngle + l.OuterConeAngle) * math.Pi / 360.0)
+	return 2.0 * math.Pi * (1.0 - cosMiddle) * l.Intensity.Luminance()
+}
+
+// DirectionalLight is infinitely far away and shines along Direction, like the sun
+// Irradiance is the light arriving on a surface facing it
+type DirectionalLight struct {
+	Direction  Vector3
+	Irradiance Vector3
+}
+
+func (l DirectionalLight) illuminate(point Vector3) (Vector3, float64, Vector3) {
+	return l.Direction.Unit().Scale(-1), math.Inf(1), l.Irradiance
+}
+
+func (l DirectionalLight) power(worldRadius float64) float64 {
+	return math.Pi * worldRadius * worldRadius * l.Irradiance.Luminance()
+}
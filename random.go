@@ -0,0 +1,39 @@
+package raytracer
+
+// sampleSource is a rand.Source64 that's cheap to reseed, so every sample of every pixel can get its own random stream
+// It's the SplitMix64 generator, source: https://prng.di.unimi.it/splitmix64.c
+type sampleSource struct {
+	state uint64
+}
+
+// Seed resets the source to the state given by the seed
+func (s *sampleSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Uint64 returns the next 64 random bits
+func (s *sampleSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix64(s.state)
+}
+
+// Int63 returns the next random non-negative int64
+func (s *sampleSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// mix64 is the SplitMix64 finalizer, it scrambles the bits of x
+func mix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// sampleSeed returns the seed of the random stream used by a sample of the pixel at x, y
+// It only depends on its arguments, so renders are reproducible regardless of the order pixels are rendered in
+func sampleSeed(seed int64, x, y, sample int) int64 {
+	h := mix64(uint64(seed) + 0x9e3779b97f4a7c15)
+	h = mix64(h ^ uint64(uint32(x)) ^ uint64(uint32(y))<<32)
+	h = mix64(h ^ uint64(sample))
+	return int64(h)
+}
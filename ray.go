@@ -1,4 +1,4 @@
-package main
+package raytracer
 
 // Ray represents a ray with an origin and direction
 type Ray struct {
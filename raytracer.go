@@ -1,127 +1,313 @@
-package main
+// Package raytracer is a toy path tracer, based on https://raytracing.github.io/books/RayTracingInOneWeekend.html
+package raytracer
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/png"
 	"math"
-	"math/rand"
-	"os"
-	"path"
 	"runtime"
 	"sync"
-	"time"
 )
 
-const width = 100 * 5
-const height = 100 * 5
-const samplesPerPixel = 25
-const maxBounces = 50
-
-func main() {
-	numThreads := 8
-	fmt.Printf("number of available CPUs: %v, spawning %v threads\n", runtime.NumCPU(), numThreads)
+// Options configure a render
+type Options struct {
+	Width, Height   int
+	SamplesPerPixel int
+	MaxBounces      int
+	// LightSampling samples lights directly at every diffuse bounce, combined with the bounces through multiple importance sampling
+	LightSampling bool
+	// Threads is the number of workers rendering tiles in parallel, < 1 uses one per CPU
+	Threads int
+	// Sampler generates the random numbers of the samples
+	Sampler SamplerType
+	// Filter weighs every sample into the pixels around it
+	Filter FilterType
+	// FilterRadius is the radius of the filter in pixels, <= 0 uses the filter's DefaultRadius
+	FilterRadius float64
+	// Seed seeds the random numbers of every sample, renders with the same seed and options are identical
+	Seed int64
+	// ToneMapping converts the rendered radiance for display in Render
+	ToneMapping ToneMapping
+	// TileSize is the side of the square tiles the workers render, < 1 uses 32
+	TileSize int
+	// Progressive renders the whole image with one sample per pixel at a time, in SamplesPerPixel passes
+	Progressive bool
+	// CheckpointInterval is the number of progressive passes between calls to Checkpoint, < 1 never calls it
+	CheckpointInterval int
+	// Checkpoint is called with the image rendered so far and the number of finished passes, can be nil
+	Checkpoint func(img *FloatImage, passes int)
+	// AdaptiveThreshold stops sampling a pixel once the relative error of its brightness is below it with 95% confidence,
+	// so SamplesPerPixel is the most a pixel gets. 0 samples every pixel SamplesPerPixel times
+	AdaptiveThreshold float64
+	// MinSamples is the number of samples every pixel gets before adaptive sampling can stop it, < 1 uses 16
+	MinSamples int
+	// Heatmap is called at the end of the render with an image of the samples taken per pixel, can be nil
+	Heatmap func(img *FloatImage)
+	// AOVs are the passes rendered alongside the image
+	AOVs []AOV
+	// AOVImages is called at the end of the render with an image of every pass in AOVs, can be nil
+	AOVImages func(images map[AOV]*FloatImage)
+	// Progress is called after every rendered tile with the tiles completed over all passes, can be nil
+	Progress func(tilesCompleted, totalTiles int)
+}
 
-	img := createImage()
+// DefaultOptions returns the options used when nothing else is specified
+func DefaultOptions() Options {
+	return Options{
+		Width:           100 * 5,
+		Height:          100 * 5,
+		SamplesPerPixel: 25,
+		MaxBounces:      50,
+		LightSampling:   true,
+		Threads:         runtime.NumCPU(),
+		TileSize:        defaultTileSize,
+		MinSamples:      defaultMinSamples,
+		Sampler:         SobolSampler,
+	}
+}
 
-	// world := newTestWorldIcoSphere()
-	// world := newTestWorldTeapot()
-	// world := newTestWorldSphereTriangleLight()
-	world := newTestWorldCornellBox()
-	// world := newTestWorldPlanet()
-	// world := newTestWorldStairs()
-	// world := newTestWorldPyramid()
+// Render renders the world into a new image, tone mapped for display
+// When ctx is cancelled the render stops and the partially rendered image is returned along with ctx's error
+func Render(ctx context.Context, world *World, options Options) (*image.RGBA, error) {
+	img, err := RenderHDR(ctx, world, options)
+	if img == nil {
+		return nil, err
+	}
+	return img.ToRGBA(options.ToneMapping), err
+}
 
-	startTime := time.Now()
+// RenderHDR renders the world into a new image of linear radiance
+// When ctx is cancelled the render stops and the partially rendered image is returned along with ctx's error
+func RenderHDR(ctx context.Context, world *World, options Options) (*FloatImage, error) {
+	if options.Width <= 0 || options.Height <= 0 {
+		return nil, fmt.Errorf("invalid image size %vx%v", options.Width, options.Height)
+	}
+	numThreads := options.Threads
+	if numThreads < 1 {
+		numThreads = runtime.NumCPU()
+	}
+	tileSize := options.TileSize
+	if tileSize < 1 {
+		tileSize = defaultTileSize
+	}
+	passes, samplesPerPass := 1, options.SamplesPerPixel
+	if options.Progressive {
+		passes, samplesPerPass = options.SamplesPerPixel, 1
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(height)
+	world.Build()
+	buffer := newRenderBuffer(options.Width, options.Height, options.AdaptiveThreshold, options.MinSamples)
+	tiles := spiralTiles(options.Width, options.Height, tileSize)
+	if len(options.AOVs) > 0 {
+		buffer.withAOVs()
+	}
+	f := newFilter(options.Filter, options.FilterRadius)
+	splats := make([]*splatBuffer, len(tiles))
+	for i, tile := range tiles {
+		splats[i] = newSplatBuffer(tile, f, options.Width, options.Height, buffer.aovs != nil)
+	}
 
-	jobs := make(chan int)
 	progressUpdates := make(chan int)
+	progressDone := make(chan struct{})
 
-	go listenForProgress(progressUpdates)
+	go listenForProgress(options, len(tiles)*passes, progressUpdates, progressDone)
 
+	for pass := 0; pass < passes && ctx.Err() == nil && !buffer.converged(); pass++ {
+		renderPass(ctx, world, &options, f, buffer, tiles, splats, pass*samplesPerPass, samplesPerPass, numThreads, progressUpdates)
+		finished := pass + 1
+		if ctx.Err() == nil && finished < passes && options.Checkpoint != nil &&
+			options.CheckpointInterval > 0 && finished%options.CheckpointInterval == 0 {
+			options.Checkpoint(buffer.image(), finished)
+		}
+	}
+
+	close(progressUpdates)
+	<-progressDone
+
+	if options.Heatmap != nil {
+		options.Heatmap(buffer.heatmap(options.SamplesPerPixel))
+	}
+	if options.AOVImages != nil && buffer.aovs != nil {
+		options.AOVImages(buffer.aovImages(options.AOVs))
+	}
+	return buffer.image(), ctx.Err()
+}
+
+// renderPass renders the samples from firstSample on of every pixel of the tiles in parallel,
+// and merges the samples splatted for every tile into the buffer in the order of the tiles
+func renderPass(ctx context.Context, world *World, options *Options, f filter, buffer *renderBuffer, tiles []image.Rectangle,
+	splats []*splatBuffer, firstSample, samples, numThreads int, progressUpdates chan int) {
+	var wg sync.WaitGroup
+	wg.Add(numThreads)
+
+	jobs := make(chan int)
 	for i := 0; i < numThreads; i++ {
-		rnd := rand.New(rand.NewSource(time.Now().Unix()))
-		go lineWorker(world, img, rnd, jobs, progressUpdates, &wg)
+		go tileWorker(world, options, f, buffer, tiles, splats, firstSample, samples, jobs, progressUpdates, &wg)
 	}
 
-	for line := height - 1; line >= 0; line-- {
-		jobs <- line
+feed:
+	for i := range tiles {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
 	}
+	close(jobs)
 
 	wg.Wait()
-
-	fmt.Println("render took ", time.Since(startTime).Round(time.Millisecond))
-	saveImageAs(img, fmt.Sprintf("render%v.png", time.Now().Unix()))
+	for _, splat := range splats {
+		buffer.merge(splat)
+	}
 }
 
-func lineWorker(world World, img *image.RGBA, rnd *rand.Rand, jobs chan int, progressUpdates chan int, wg *sync.WaitGroup) {
-	for y := range jobs {
-		for x := 0; x < width; x++ {
-			accumulatedColor := Vector3{0, 0, 0}
-			for sample := 0; sample < samplesPerPixel; sample++ {
-				u := (float64(x) + rand.Float64()) / float64(width-1)
-				v := (float64(y) + rand.Float64()) / float64(height-1)
-				ray := world.Camera.GetRay(u, v, rnd)
-				accumulatedColor = accumulatedColor.Add(rayColor(ray, world, 0, rnd))
+// tileWorker renders the tiles with the indexes it receives into their splat buffers
+func tileWorker(world *World, options *Options, f filter, buffer *renderBuffer, tiles []image.Rectangle, splats []*splatBuffer,
+	firstSample, samples int, jobs chan int, progressUpdates chan int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	width, height := options.Width, options.Height
+	sampler := NewSampler(options.Sampler, options.Seed, options.SamplesPerPixel)
+	var aov *aovSample
+	if buffer.aovs != nil {
+		aov = &aovSample{}
+	}
+	for i := range jobs {
+		tile, splat := tiles[i], splats[i]
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				for sample := firstSample; sample < firstSample+samples && !buffer.pixelConverged(x, y); sample++ {
+					sampler.StartPixelSample(x, y, sample)
+					dx, dy := sampler.Get2D()
+					px, py := float64(x)+dx, float64(y)+dy
+					ray := world.Camera.GetRay(px/float64(width), py/float64(height), sampler)
+					if aov != nil {
+						*aov = aovSample{}
+					}
+					color := rayColor(ray, world, options, sampler, aov)
+					buffer.addSample(x, y, color, aov)
+					splat.splat(px, py, color, aov, f)
+				}
 			}
-			pixelColor := accumulatedColor.Scale(1.0 / samplesPerPixel).gammaCorrect().ToColor()
-			img.Set(x, height-y, pixelColor)
 		}
 		progressUpdates <- 1
-		wg.Done()
 	}
 }
 
-func listenForProgress(progressUpdates chan int) {
-	linesCompleted := 0
+func listenForProgress(options Options, totalTiles int, progressUpdates chan int, done chan struct{}) {
+	tilesCompleted := 0
 	for p := range progressUpdates {
-		linesCompleted += p
-		percent := math.Floor(100 * float64(linesCompleted) / float64(height))
-		fmt.Printf("rendered %v/%v lines [%v%%]\n", linesCompleted, height, percent)
+		tilesCompleted += p
+		if options.Progress != nil {
+			options.Progress(tilesCompleted, totalTiles)
+		}
 	}
+	close(done)
 }
 
-func rayColor(r Ray, w World, depth int, rnd *rand.Rand) Vector3 {
-	if depth > maxBounces {
-		return Vector3{0, 0, 0}
-	}
-	hitRecord, hit := w.Hit(r, 0.001, math.Inf(1))
-	if hit {
-		// return hitRecord.Normal.Add(Vector3{1, 1, 1}).Scale(0.5) // render normals
-		emitted := hitRecord.Material.Emit(r, *hitRecord, rnd)
-		bounceRay, attenuation, hasScattered := hitRecord.Material.Scatter(r, *hitRecord, rnd)
-		if hasScattered {
-			return rayColor(*bounceRay, w, depth+1, rnd).
-				MultiplyComponents(attenuation).
-				Add(emitted)
+// rayColor returns the light arriving along the camera ray r, and records what it hits first and how the light
+// got there in aov if it isn't nil
+func rayColor(r Ray, w *World, options *Options, sampler Sampler, aov *aovSample) Vector3 {
+	color := Vector3{0, 0, 0}
+	throughput := Vector3{1, 1, 1}
+	// pdf of the BSDF choosing r, 0 for camera rays and specular bounces that light sampling can't reach
+	bsdfPDF := 0.0
+	// scatterPoint is where r was last scattered, r starts further along after crossing into or out of a volume
+	scatterPoint := r.Origin
+	// volume is the medium of the Volume r is going through, nil outside of volumes
+	var volume *Medium
+
+	for depth := 0; depth <= options.MaxBounces; depth++ {
+		sampler.StartBounce()
+		hitRecord, hit := w.Hit(r, 0.001, math.Inf(1))
+
+		tMax := math.Inf(1)
+		if hit {
+			tMax = hitRecord.T
+			if depth == 0 && hitRecord.medium == nil {
+				// the camera ray's first hit, also if it scatters in a medium before reaching it
+				aov.recordHit(hitRecord, w.Camera)
+			}
 		}
-		return emitted
-	}
-	return w.AmbientColor(r)
-}
+		if medium, t0, t1, ok := w.mediumSegment(r, volume, 0, tMax); ok {
+			distance, weight, scattered := medium.sampleDistance(t1-t0, sampler)
+			throughput = throughput.MultiplyComponents(weight)
+			if throughput.IsNearZero() {
+				break
+			}
+			if scattered {
+				phase := henyeyGreenstein{g: medium.Anisotropy}
+				point := r.At(t0 + distance)
+				scatterRecord := HitRecord{Point: point, T: t0 + distance}
+				direction := phase.sample(r.Direction.Unit(), sampler)
 
-func (v Vector3) gammaCorrect() Vector3 {
-	return Vector3{
-		X: math.Sqrt(v.X),
-		Y: math.Sqrt(v.Y),
-		Z: math.Sqrt(v.Z),
+				bsdfPDF = 0
+				if options.LightSampling {
+					direct := throughput.MultiplyComponents(w.sampleLight(r, &scatterRecord, phase, volume, sampler))
+					color = color.Add(direct)
+					aov.addLight(direct, depth+1)
+					_, bsdfPDF = phase.Eval(r, scatterRecord, direction)
+				}
+				// the phase function is its own pdf, so the throughput stays the same
+				r = Ray{Origin: point, Direction: direction}
+				scatterPoint = point
+				continue
+			}
+		}
+
+		if !hit {
+			ambient := w.AmbientColor(r)
+			if bsdfPDF > 0 {
+				// the previous bounce sampled the environment directly too
+				ambient = ambient.Scale(powerHeuristic(bsdfPDF, w.environmentPDF(r.Direction)))
+			}
+			ambient = throughput.MultiplyComponents(ambient)
+			aov.addLight(ambient, depth)
+			return color.Add(ambient)
+		}
+
+		if hitRecord.medium != nil {
+			// crossing into or out of a volume goes straight on and isn't a bounce
+			volume = nil
+			if hitRecord.IsFrontFace {
+				volume = hitRecord.medium
+			}
+			r = Ray{Origin: hitRecord.Point, Direction: r.Direction}
+			depth--
+			continue
+		}
+
+		emitted := hitRecord.Material.Emit(r, *hitRecord, sampler)
+		if bsdfPDF > 0 && hitRecord.light != nil {
+			// the previous bounce sampled this light directly too
+			emitted = emitted.Scale(powerHeuristic(bsdfPDF, hitRecord.light.pdf(scatterPoint, hitRecord)))
+		}
+		emitted = throughput.MultiplyComponents(emitted)
+		color = color.Add(emitted)
+		aov.addLight(emitted, depth)
+
+		bounceRay, attenuation, hasScattered := hitRecord.Material.Scatter(r, *hitRecord, sampler)
+		if !hasScattered {
+			break
+		}
+
+		bsdfPDF = 0
+		if bsdf, ok := hitRecord.Material.(BSDF); ok && options.LightSampling {
+			direct := throughput.MultiplyComponents(w.sampleLight(r, hitRecord, bsdf, volume, sampler))
+			color = color.Add(direct)
+			aov.addLight(direct, depth+1)
+			_, bsdfPDF = bsdf.Eval(r, *hitRecord, bounceRay.Direction)
+		}
+
+		throughput = throughput.MultiplyComponents(attenuation)
+		r = *bounceRay
+		scatterPoint = r.Origin
 	}
+	return color
 }
 
-func createImage() *image.RGBA {
+func createImage(width, height int) *image.RGBA {
 	upLeft := image.Point{0, 0}
 	lowRight := image.Point{width, height}
 	return image.NewRGBA(image.Rectangle{upLeft, lowRight})
 }
-
-func saveImageAs(img *image.RGBA, filename string) {
-	os.Mkdir("output", 0775)
-	f, error := os.Create(path.Join("output", filename))
-	if error != nil {
-		fmt.Println(error)
-	}
-	png.Encode(f, img)
-}
@@ -1,7 +1,115 @@
-package main
+package raytracer
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+)
 
 func TestRaytracer(t *testing.T) {
-	main()
+	options := DefaultOptions()
+	options.Width, options.Height = 100, 100
+	world, err := TestWorld("cornell-box", options.Width, options.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Render(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != options.Width || size.Y != options.Height {
+		t.Errorf("image size is %v, expected %vx%v", size, options.Width, options.Height)
+	}
+}
+
+func TestRenderIsReproducible(t *testing.T) {
+	options := DefaultOptions()
+	options.Width, options.Height = 32, 24
+	options.SamplesPerPixel = 4
+	options.Seed = 42
+	world, err := TestWorld("cornell-box", options.Width, options.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(threads int, seed int64) *FloatImage {
+		options.Threads = threads
+		options.Seed = seed
+		img, err := RenderHDR(context.Background(), &world, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	want := render(1, 42)
+	if got := render(5, 42); !reflect.DeepEqual(got, want) {
+		t.Error("rendering with 5 threads differs from rendering with 1 thread")
+	}
+	if got := render(1, 43); reflect.DeepEqual(got, want) {
+		t.Error("rendering with a different seed gives the same image")
+	}
+}
+
+func TestProgressiveRender(t *testing.T) {
+	options := DefaultOptions()
+	options.Width, options.Height = 32, 24
+	options.SamplesPerPixel = 5
+	options.Threads = 2
+	world, err := TestWorld("cornell-box", options.Width, options.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the passes take the same samples as rendering every pixel at once, in any tiles
+	options.Progressive = true
+	options.TileSize = 7
+	options.CheckpointInterval = 2
+	var checkpoints []int
+	options.Checkpoint = func(img *FloatImage, passes int) {
+		checkpoints = append(checkpoints, passes)
+		if reflect.DeepEqual(img, want) {
+			t.Errorf("the checkpoint after %d passes is already the whole render", passes)
+		}
+	}
+	var progress []int
+	options.Progress = func(tilesCompleted, totalTiles int) {
+		progress = append(progress, tilesCompleted)
+		if totalTiles != 5*5*4 {
+			t.Errorf("progress reports %d tiles, want %d", totalTiles, 5*5*4)
+		}
+	}
+	got, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("the progressive render differs from rendering every pixel at once")
+	}
+	if !reflect.DeepEqual(checkpoints, []int{2, 4}) {
+		t.Errorf("checkpoints were taken after %v passes, want after 2 and 4", checkpoints)
+	}
+	if len(progress) != 100 || progress[len(progress)-1] != 100 {
+		t.Errorf("progress was reported %d times, up to %v", len(progress), progress)
+	}
+
+	// cancelling after the first checkpoint returns the image of the finished passes
+	ctx, cancel := context.WithCancel(context.Background())
+	var checkpoint *FloatImage
+	options.Checkpoint = func(img *FloatImage, passes int) {
+		checkpoint = img
+		cancel()
+	}
+	options.Progress = nil
+	got, err = RenderHDR(ctx, &world, options)
+	if err != context.Canceled {
+		t.Errorf("cancelled render returned %v", err)
+	}
+	if !reflect.DeepEqual(got, checkpoint) {
+		t.Error("the cancelled render differs from its last checkpoint")
+	}
 }
@@ -0,0 +1,266 @@
+package raytracer
+
+import (
+	"image"
+	"math"
+)
+
+// defaultMinSamples is the number of samples every pixel gets with adaptive sampling when Options.MinSamples isn't set
+const defaultMinSamples = 16
+
+// renderBuffer is the film the samples are developed on, every pixel is the sum of the samples around it weighted by
+// the reconstruction filter, divided by the sum of their weights
+// Workers count the samples of the pixels of their own tiles, their filtered samples are merged in from splat buffers
+// Pixels are indexed by x and the line y, which counts from the bottom of the image
+type renderBuffer struct {
+	width, height int
+	sum           []Vector3
+	weights       []float64
+	// samples is the number of samples taken in every pixel
+	samples []int
+	// the running mean of the luminance of every pixel's samples and the sum of their squared differences from it
+	mean, m2 []float64
+
+	// threshold is the relative error under which a pixel has converged, 0 turns adaptive sampling off
+	threshold  float64
+	minSamples int
+
+	// aovs sums up the passes of the first hits, and light the filtered emission, direct and indirect light,
+	// they're nil unless passes are rendered
+	aovs  *aovBuffer
+	light [][3]Vector3
+}
+
+func newRenderBuffer(width, height int, threshold float64, minSamples int) *renderBuffer {
+	if minSamples < 1 {
+		minSamples = defaultMinSamples
+	}
+	b := &renderBuffer{
+		width:      width,
+		height:     height,
+		sum:        make([]Vector3, width*height),
+		weights:    make([]float64, width*height),
+		samples:    make([]int, width*height),
+		threshold:  threshold,
+		minSamples: minSamples,
+	}
+	if threshold > 0 {
+		b.mean = make([]float64, width*height)
+		b.m2 = make([]float64, width*height)
+	}
+	return b
+}
+
+// withAOVs makes the buffer keep the passes
+func (b *renderBuffer) withAOVs() {
+	b.aovs = newAOVBuffer(b.width * b.height)
+	b.light = make([][3]Vector3, b.width*b.height)
+}
+
+// addSample counts a sample taken in the pixel at x, y, which is splatted into the pixels around it separately,
+// and adds what the camera ray hit to the passes if aov isn't nil
+func (b *renderBuffer) addSample(x, y int, color Vector3, aov *aovSample) {
+	i := y*b.width + x
+	b.samples[i]++
+	if b.aovs != nil && aov != nil {
+		b.aovs.add(i, aov, b.samples[i] == 1)
+	}
+	if b.threshold > 0 {
+		// Welford's online algorithm, source: https://en.wikipedia.org/wiki/Algorithms_for_calculating_variance
+		luminance := color.Luminance()
+		delta := luminance - b.mean[i]
+		b.mean[i] += delta / float64(b.samples[i])
+		b.m2[i] += delta * (luminance - b.mean[i])
+	}
+}
+
+// pixelConverged returns whether the pixel at x, y needs no more samples with adaptive sampling
+func (b *renderBuffer) pixelConverged(x, y int) bool {
+	return b.threshold > 0 && b.indexConverged(y*b.width+x)
+}
+
+func (b *renderBuffer) indexConverged(i int) bool {
+	if b.samples[i] < b.minSamples || b.samples[i] < 2 {
+		return false
+	}
+	n := float64(b.samples[i])
+	variance := b.m2[i] / (n - 1.0)
+	// the half width of the 95% confidence interval of the mean, relative to the mean
+	// dark pixels are compared to a small floor, so pixels that stay black converge
+	errorEstimate := 1.96 * math.Sqrt(variance/n)
+	return errorEstimate <= b.threshold*math.Max(b.mean[i], 1e-3)
+}
+
+// converged returns whether every pixel needs no more samples with adaptive sampling
+func (b *renderBuffer) converged() bool {
+	if b.threshold <= 0 {
+		return false
+	}
+	for i := range b.samples {
+		if !b.indexConverged(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge adds the samples splatted into s to the pixels and clears s
+func (b *renderBuffer) merge(s *splatBuffer) {
+	for y := s.bounds.Min.Y; y < s.bounds.Max.Y; y++ {
+		for x := s.bounds.Min.X; x < s.bounds.Max.X; x++ {
+			i, j := y*b.width+x, (y-s.bounds.Min.Y)*s.bounds.Dx()+x-s.bounds.Min.X
+			b.sum[i] = b.sum[i].Add(s.sum[j])
+			b.weights[i] += s.weights[j]
+			s.sum[j], s.weights[j] = Vector3{0, 0, 0}, 0
+			if s.light != nil {
+				for k := range s.light[j] {
+					b.light[i][k] = b.light[i][k].Add(s.light[j][k])
+				}
+				s.light[j] = [3]Vector3{}
+			}
+		}
+	}
+}
+
+// image returns the filtered samples of every pixel, pixels no sample reached are black
+func (b *renderBuffer) image() *FloatImage {
+	return b.toImage(func(i int) Vector3 {
+		if b.weights[i] == 0 {
+			return Vector3{0, 0, 0}
+		}
+		return b.sum[i].Scale(1.0 / b.weights[i])
+	})
+}
+
+// aovImages returns an image of every pass, the lighting passes are filtered like the image and the others are
+// averaged over the samples of the pixels, except for the IDs, which are the ones of the first sample
+func (b *renderBuffer) aovImages(aovs []AOV) map[AOV]*FloatImage {
+	images := make(map[AOV]*FloatImage, len(aovs))
+	var materials []int
+	for _, aov := range aovs {
+		if aov == AOVMaterialID && materials == nil {
+			materials = materialIDs(b.aovs.materialIDs, b.width, b.height)
+		}
+		images[aov] = b.toImage(func(i int) Vector3 {
+			if aov.isLighting() {
+				if b.weights[i] == 0 {
+					return Vector3{0, 0, 0}
+				}
+				return b.light[i][aov.lightingIndex()].Scale(1.0 / b.weights[i])
+			}
+			var value Vector3
+			switch aov {
+			case AOVObjectID:
+				id := float64(b.aovs.objectIDs[i])
+				return Vector3{id, id, id}
+			case AOVMaterialID:
+				id := float64(materials[i])
+				return Vector3{id, id, id}
+			case AOVNormal:
+				value = b.aovs.normal[i]
+			case AOVAlbedo:
+				value = b.aovs.albedo[i]
+			case AOVPosition:
+				value = b.aovs.position[i]
+			case AOVDepth:
+				value = Vector3{b.aovs.depth[i], b.aovs.depth[i], b.aovs.depth[i]}
+			}
+			if b.samples[i] == 0 {
+				return Vector3{0, 0, 0}
+			}
+			return value.Scale(1.0 / float64(b.samples[i]))
+		})
+	}
+	return images
+}
+
+// heatmap returns an image of the samples taken per pixel colored by heatmapColor
+func (b *renderBuffer) heatmap(maxSamples int) *FloatImage {
+	return b.toImage(func(i int) Vector3 {
+		return heatmapColor(b.samples[i], maxSamples)
+	})
+}
+
+// heatmapColor returns the color of a pixel with samples out of maxSamples, from blue for none over green to red
+func heatmapColor(samples, maxSamples int) Vector3 {
+	t := 0.0
+	if maxSamples > 0 {
+		t = Clamp(float64(samples)/float64(maxSamples), 0, 1)
+	}
+	if t < 0.5 {
+		return Vector3{0, 2.0 * t, 1.0 - 2.0*t}
+	}
+	return Vector3{2.0*t - 1.0, 2.0 - 2.0*t, 0}
+}
+
+// toImage returns an image with the color of every pixel by its index, the bottom line is the last row of the image
+func (b *renderBuffer) toImage(color func(i int) Vector3) *FloatImage {
+	img := NewFloatImage(b.width, b.height)
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			img.Set(x, b.height-1-y, color(y*b.width+x))
+		}
+	}
+	return img
+}
+
+// splatBuffer collects the filtered samples of a tile, which reach the pixels around it too
+// Every tile has its own, which is merged into the render buffer in the order of the tiles, so workers never write
+// to the same pixels and the sums don't depend on which tile finished first
+type splatBuffer struct {
+	// bounds are the pixels of the tile and the margin around it the filter reaches, clipped to the image
+	bounds  image.Rectangle
+	sum     []Vector3
+	weights []float64
+	// light holds the lighting passes if they're rendered
+	light [][3]Vector3
+}
+
+// newSplatBuffer returns the splat buffer of the tile, withLight adds the lighting passes
+func newSplatBuffer(tile image.Rectangle, f filter, width, height int, withLight bool) *splatBuffer {
+	bounds := tile.Inset(-f.margin()).Intersect(image.Rect(0, 0, width, height))
+	s := &splatBuffer{
+		bounds:  bounds,
+		sum:     make([]Vector3, bounds.Dx()*bounds.Dy()),
+		weights: make([]float64, bounds.Dx()*bounds.Dy()),
+	}
+	if withLight {
+		s.light = make([][3]Vector3, bounds.Dx()*bounds.Dy())
+	}
+	return s
+}
+
+// splat adds a sample at the position x, y, in pixels from the bottom left of the image, to the pixels the filter reaches,
+// and its light to the lighting passes if aov isn't nil
+func (s *splatBuffer) splat(x, y float64, color Vector3, aov *aovSample, f filter) {
+	x0, x1 := f.pixelRange(x)
+	y0, y1 := f.pixelRange(y)
+	if x0 < s.bounds.Min.X {
+		x0 = s.bounds.Min.X
+	}
+	if x1 >= s.bounds.Max.X {
+		x1 = s.bounds.Max.X - 1
+	}
+	if y0 < s.bounds.Min.Y {
+		y0 = s.bounds.Min.Y
+	}
+	if y1 >= s.bounds.Max.Y {
+		y1 = s.bounds.Max.Y - 1
+	}
+	for py := y0; py <= y1; py++ {
+		for px := x0; px <= x1; px++ {
+			weight := f.weight(float64(px)+0.5-x, float64(py)+0.5-y)
+			if weight == 0 {
+				continue
+			}
+			i := (py-s.bounds.Min.Y)*s.bounds.Dx() + px - s.bounds.Min.X
+			s.sum[i] = s.sum[i].Add(color.Scale(weight))
+			s.weights[i] += weight
+			if s.light != nil && aov != nil {
+				for k, light := range aov.light {
+					s.light[i][k] = s.light[i][k].Add(light.Scale(weight))
+				}
+			}
+		}
+	}
+}
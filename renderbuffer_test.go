@@ -0,0 +1,114 @@
+package raytracer
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRenderBufferConvergence(t *testing.T) {
+	// the pixels are on line 1, the top row of the image
+	b := newRenderBuffer(3, 2, 0.05, 4)
+	box := newFilter(BoxFilter, 0)
+	splat := newSplatBuffer(image.Rect(0, 0, 3, 2), box, 3, 2, false)
+	add := func(x, y int, color Vector3) {
+		b.addSample(x, y, color, nil)
+		splat.splat(float64(x)+0.5, float64(y)+0.5, color, nil, box)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	var values []float64
+	for i := 0; i < 100; i++ {
+		// a constant pixel, a noisy one and a black one
+		add(0, 1, Vector3{0.5, 0.5, 0.5})
+		value := rnd.Float64() * 2
+		values = append(values, value)
+		add(1, 1, Vector3{value, value, value})
+		add(2, 1, Vector3{0, 0, 0})
+		if i == 2 && (b.pixelConverged(0, 1) || b.pixelConverged(2, 1)) {
+			t.Error("pixels converged before their minimum samples")
+		}
+	}
+
+	mean, variance := 0.0, 0.0
+	for _, value := range values {
+		mean += value / float64(len(values))
+	}
+	for _, value := range values {
+		variance += (value - mean) * (value - mean) / float64(len(values)-1)
+	}
+	if got := b.mean[4]; math.Abs(got-mean) > 1e-9 {
+		t.Errorf("the running mean is %v, want %v", got, mean)
+	}
+	if got := b.m2[4] / 99; math.Abs(got-variance) > 1e-9 {
+		t.Errorf("the running variance is %v, want %v", got, variance)
+	}
+
+	// the noisy pixel's relative error is about 1.96 * 0.58 / 10 = 0.11
+	if !b.pixelConverged(0, 1) || b.pixelConverged(1, 1) || !b.pixelConverged(2, 1) || b.converged() {
+		t.Error("only the constant and the black pixel should have converged")
+	}
+	b.merge(splat)
+	if img := b.image(); img.At(1, 0).Subtract(Vector3{mean, mean, mean}).Length() > 1e-6 {
+		t.Errorf("the noisy pixel is %v, want %v", img.At(1, 0), mean)
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	options := DefaultOptions()
+	options.Width, options.Height = 32, 32
+	options.SamplesPerPixel = 64
+	options.MaxBounces = 8
+	options.Threads = 2
+	world, err := newGoldenWorldSphere(float64(options.Width), float64(options.Height))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options.AdaptiveThreshold = 0.05
+	options.MinSamples = 8
+	var heatmap *FloatImage
+	options.Heatmap = func(img *FloatImage) { heatmap = img }
+	got, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sky needs no more than the minimum, the shaded sphere and floor more
+	minimum := heatmapColor(options.MinSamples, options.SamplesPerPixel)
+	if sky := heatmap.At(16, 1); sky != minimum {
+		t.Errorf("the sky's heatmap color is %v, want %v for %d samples", sky, minimum, options.MinSamples)
+	}
+	if floor := heatmap.At(16, 30); floor.X <= minimum.X {
+		t.Errorf("the floor's heatmap color is %v, it didn't get more samples than the minimum", floor)
+	}
+	if rmse := floatImageRMSE(got, want); rmse > 0.02 {
+		t.Errorf("the adaptive render is %v off the full render", rmse)
+	}
+
+	// progressive passes stop the same pixels after the same samples
+	options.Progressive = true
+	progressive, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(progressive, got) {
+		t.Error("the progressive adaptive render differs from the adaptive render")
+	}
+}
+
+// floatImageRMSE returns the root mean square error between the color components of two images of the same size
+func floatImageRMSE(a, b *FloatImage) float64 {
+	sum := 0.0
+	for i := range a.Pix {
+		d := float64(a.Pix[i] - b.Pix[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(a.Pix)))
+}
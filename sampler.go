@@ -0,0 +1,340 @@
+package raytracer
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+// Sampler hands out the random numbers of the samples of pixels, dimension by dimension
+// The camera takes the first dimensions of a sample and every bounce of its path the same number of dimensions after them,
+// so a bounce gets the same dimensions however many the bounces before it took
+// Samplers aren't safe for concurrent use, every render thread has its own
+type Sampler interface {
+	// StartPixelSample starts the sample with the index sample of the pixel at x, y
+	StartPixelSample(x, y, sample int)
+	// StartBounce moves on to the dimensions of the next bounce of the path
+	StartBounce()
+	// Get1D returns the next dimension in [0..1)
+	Get1D() float64
+	// Get2D returns the next two dimensions in [0..1), which are spread out well together
+	Get2D() (float64, float64)
+}
+
+// SamplerType picks how the samples' random numbers are generated
+type SamplerType int
+
+// The available samplers
+const (
+	// IndependentSampler draws every number at random
+	IndependentSampler SamplerType = iota
+	// StratifiedSampler splits every dimension into strata, one per sample of a pixel, and jitters the samples in them
+	StratifiedSampler
+	// HaltonSampler takes the Halton sequence, shifted randomly in every pixel
+	HaltonSampler
+	// SobolSampler takes Owen scrambled Sobol points, shuffled randomly for every pixel and pair of dimensions
+	SobolSampler
+)
+
+var samplerTypeNames = []string{"independent", "stratified", "halton", "sobol"}
+
+// ParseSamplerType returns the sampler type with the name returned by its String method
+func ParseSamplerType(name string) (SamplerType, error) {
+	for i, samplerName := range samplerTypeNames {
+		if name == samplerName {
+			return SamplerType(i), nil
+		}
+	}
+	return IndependentSampler, fmt.Errorf("unknown sampler %q", name)
+}
+
+// String returns the name of the sampler type
+func (t SamplerType) String() string {
+	if t < 0 || int(t) >= len(samplerTypeNames) {
+		return fmt.Sprintf("SamplerType(%d)", int(t))
+	}
+	return samplerTypeNames[t]
+}
+
+// NewSampler returns a sampler of the type for pixels with samplesPerPixel samples, its numbers only depend on
+// the seed, the pixel, the sample and the dimension
+func NewSampler(t SamplerType, seed int64, samplesPerPixel int) Sampler {
+	d := dimensions{seed: seed}
+	switch t {
+	case StratifiedSampler:
+		columns := int(math.Ceil(math.Sqrt(float64(samplesPerPixel))))
+		rows := (samplesPerPixel + columns - 1) / columns
+		return &stratifiedSampler{dimensions: d, samplesPerPixel: samplesPerPixel, columns: columns, rows: rows}
+	case HaltonSampler:
+		return &haltonSampler{dimensions: d}
+	case SobolSampler:
+		return &sobolSampler{dimensions: d}
+	default:
+		return newIndependentSampler(seed)
+	}
+}
+
+const (
+	// cameraDimensions are the dimensions of a sample taken by the camera, for the position in the pixel and on the lens
+	cameraDimensions = 4
+	// bounceDimensions are the dimensions every bounce gets, numbers taken past them are independent
+	bounceDimensions = 16
+)
+
+// independentSampler draws every number from a random stream of its own for every sample
+type independentSampler struct {
+	seed int64
+	rnd  *rand.Rand
+}
+
+// newIndependentSampler returns an independent sampler that's ready to use without starting a pixel sample
+func newIndependentSampler(seed int64) *independentSampler {
+	return &independentSampler{seed: seed, rnd: rand.New(&sampleSource{state: uint64(seed)})}
+}
+
+func (s *independentSampler) StartPixelSample(x, y, sample int) {
+	s.rnd.Seed(sampleSeed(s.seed, x, y, sample))
+}
+
+func (s *independentSampler) StartBounce() {}
+
+func (s *independentSampler) Get1D() float64 {
+	return s.rnd.Float64()
+}
+
+func (s *independentSampler) Get2D() (float64, float64) {
+	return s.rnd.Float64(), s.rnd.Float64()
+}
+
+// dimensions keeps track of the pixel sample a sampler is at and the dimension it hands out next
+type dimensions struct {
+	seed         int64
+	x, y, sample int
+	// bounce is the bounce the next dimensions belong to, -1 for the camera's
+	bounce int
+	// dimension is the next dimension of the camera or the bounce
+	dimension int
+}
+
+func (d *dimensions) StartPixelSample(x, y, sample int) {
+	d.x, d.y, d.sample = x, y, sample
+	d.bounce = -1
+	d.dimension = 0
+}
+
+func (d *dimensions) StartBounce() {
+	d.bounce++
+	d.dimension = 0
+}
+
+// next returns the index of the next count dimensions in the whole sample and moves past them,
+// and false if they don't fit into the dimensions of the camera or the bounce
+func (d *dimensions) next(count int) (int, bool) {
+	offset := d.dimension
+	d.dimension += count
+	if d.bounce < 0 {
+		return offset, d.dimension <= cameraDimensions
+	}
+	return cameraDimensions + d.bounce*bounceDimensions + offset, d.dimension <= bounceDimensions
+}
+
+// hash returns random bits for the pixel, the dimension and the salt
+func (d *dimensions) hash(dimension int, salt uint64) uint64 {
+	return mix64(uint64(sampleSeed(d.seed, d.x, d.y, dimension)) ^ mix64(salt))
+}
+
+// random returns an independent random number for a dimension that doesn't fit into the camera's or the bounce's
+func (d *dimensions) random(dimension int) float64 {
+	return hashFloat(d.hash(dimension, uint64(d.sample)|1<<63))
+}
+
+// stratifiedSampler puts every sample of a pixel into a stratum of its own in every dimension,
+// strata are picked by a random permutation for every pixel and dimension so the dimensions don't correlate
+// 2D dimensions are split into a grid of columns x rows strata
+type stratifiedSampler struct {
+	dimensions
+	samplesPerPixel int
+	columns, rows   int
+}
+
+func (s *stratifiedSampler) Get1D() float64 {
+	dimension, ok := s.next(1)
+	if !ok || s.sample >= s.samplesPerPixel {
+		return s.random(dimension)
+	}
+	stratum := permute(s.sample, s.samplesPerPixel, s.hash(dimension, 0))
+	return (float64(stratum) + hashFloat(s.hash(dimension, uint64(s.sample)+1))) / float64(s.samplesPerPixel)
+}
+
+func (s *stratifiedSampler) Get2D() (float64, float64) {
+	dimension, ok := s.next(2)
+	if !ok || s.sample >= s.columns*s.rows {
+		return s.random(dimension), s.random(dimension + 1)
+	}
+	stratum := permute(s.sample, s.columns*s.rows, s.hash(dimension, 0))
+	u := (float64(stratum%s.columns) + hashFloat(s.hash(dimension, uint64(s.sample)+1))) / float64(s.columns)
+	v := (float64(stratum/s.columns) + hashFloat(s.hash(dimension+1, uint64(s.sample)+1))) / float64(s.rows)
+	return u, v
+}
+
+// haltonSampler takes the radical inverse of the sample index in the prime base of every dimension,
+// shifted by a random offset for every pixel and dimension so pixels don't share their samples
+// Source: https://www.pbr-book.org/3ed-2018/Sampling_and_Reconstruction/The_Halton_Sampler
+type haltonSampler struct {
+	dimensions
+}
+
+// haltonPrimes are the bases of the dimensions, dimensions past them are independent
+var haltonPrimes = firstPrimes(256)
+
+func (s *haltonSampler) Get1D() float64 {
+	dimension, ok := s.next(1)
+	return s.value(dimension, ok)
+}
+
+func (s *haltonSampler) Get2D() (float64, float64) {
+	dimension, ok := s.next(2)
+	return s.value(dimension, ok), s.value(dimension+1, ok)
+}
+
+func (s *haltonSampler) value(dimension int, ok bool) float64 {
+	if !ok || dimension >= len(haltonPrimes) {
+		return s.random(dimension)
+	}
+	value := radicalInverse(haltonPrimes[dimension], s.sample) + hashFloat(s.hash(dimension, 0))
+	return value - math.Floor(value)
+}
+
+// radicalInverse mirrors the digits of index in base around the decimal point
+func radicalInverse(base, index int) float64 {
+	inverse := 0.0
+	digitWeight := 1.0 / float64(base)
+	for i := index; i > 0; i /= base {
+		inverse += float64(i%base) * digitWeight
+		digitWeight /= float64(base)
+	}
+	return inverse
+}
+
+// firstPrimes returns the first n prime numbers
+func firstPrimes(n int) []int {
+	primes := make([]int, 0, n)
+	for candidate := 2; len(primes) < n; candidate++ {
+		isPrime := true
+		for _, p := range primes {
+			if p*p > candidate {
+				break
+			}
+			if candidate%p == 0 {
+				isPrime = false
+				break
+			}
+		}
+		if isPrime {
+			primes = append(primes, candidate)
+		}
+	}
+	return primes
+}
+
+// sobolSampler takes the first two dimensions of the Sobol sequence for every pair of dimensions,
+// with the sample index shuffled and the points Owen scrambled by hashes of the pixel and the dimension
+// Source: Brent Burley 2020, Practical Hash-based Owen Scrambling
+type sobolSampler struct {
+	dimensions
+}
+
+func (s *sobolSampler) Get1D() float64 {
+	dimension, ok := s.next(1)
+	if !ok {
+		return s.random(dimension)
+	}
+	seed := s.hash(dimension, 0)
+	index := nestedUniformScramble(uint32(s.sample), uint32(seed))
+	return uint32ToFloat(nestedUniformScramble(bits.Reverse32(index), uint32(seed>>32)))
+}
+
+func (s *sobolSampler) Get2D() (float64, float64) {
+	dimension, ok := s.next(2)
+	if !ok {
+		return s.random(dimension), s.random(dimension + 1)
+	}
+	seed := s.hash(dimension, 0)
+	index := nestedUniformScramble(uint32(s.sample), uint32(seed))
+	u := nestedUniformScramble(bits.Reverse32(index), uint32(seed>>32))
+	v := nestedUniformScramble(sobolSecondDimension(index), uint32(mix64(seed)))
+	return uint32ToFloat(u), uint32ToFloat(v)
+}
+
+// sobolSecondDimension returns the second dimension of the Sobol point at index, the first is its bits reversed
+func sobolSecondDimension(index uint32) uint32 {
+	result := uint32(0)
+	for v := uint32(1) << 31; index != 0; index >>= 1 {
+		if index&1 != 0 {
+			result ^= v
+		}
+		v ^= v >> 1
+	}
+	return result
+}
+
+// nestedUniformScramble Owen scrambles the bits of x, every bit is flipped by a hash of the bits above it
+func nestedUniformScramble(x, seed uint32) uint32 {
+	x = bits.Reverse32(x)
+	// the Laine-Karras permutation, which only lets bits affect the bits above them
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return bits.Reverse32(x)
+}
+
+// permute returns the element at index i of a random permutation of [0..n) given by the seed
+// Source: Andrew Kensler 2013, Correlated Multi-Jittered Sampling
+func permute(i, n int, seed uint64) int {
+	l, p := uint32(n), uint32(seed)
+	w := l - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	x := uint32(i)
+	// the permutation is over the next power of two, values past n are run through it again until they're in [0..n)
+	for {
+		x ^= p
+		x *= 0xe170893d
+		x ^= p >> 16
+		x ^= (x & w) >> 4
+		x ^= p >> 8
+		x *= 0x0929eb3f
+		x ^= p >> 23
+		x ^= (x & w) >> 1
+		x *= 1 | p>>27
+		x *= 0x6935fa69
+		x ^= (x & w) >> 11
+		x *= 0x74dcb303
+		x ^= (x & w) >> 2
+		x *= 0x9e501cc3
+		x ^= (x & w) >> 2
+		x *= 0xc860a3df
+		x &= w
+		x ^= x >> 5
+		if x < l {
+			break
+		}
+	}
+	return int((x + p) % l)
+}
+
+// hashFloat returns a number in [0..1) from the top 53 bits of h
+func hashFloat(h uint64) float64 {
+	return float64(h>>11) / (1 << 53)
+}
+
+// uint32ToFloat returns a number in [0..1) from the 32 bits of x
+func uint32ToFloat(x uint32) float64 {
+	return float64(x) / (1 << 32)
+}
@@ -0,0 +1,173 @@
+package raytracer
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+var allSamplerTypes = []SamplerType{IndependentSampler, StratifiedSampler, HaltonSampler, SobolSampler}
+
+func TestSamplersAreReproducible(t *testing.T) {
+	// draws the camera's dimensions, bounces with more dimensions than they get, and returns all of the numbers
+	draw := func(s Sampler, x, y, sample int) []float64 {
+		s.StartPixelSample(x, y, sample)
+		u, v := s.Get2D()
+		numbers := []float64{u, v, s.Get1D()}
+		for bounce := 0; bounce < 3; bounce++ {
+			s.StartBounce()
+			for i := 0; i < bounceDimensions+2; i++ {
+				u, v := s.Get2D()
+				numbers = append(numbers, u, v, s.Get1D())
+			}
+		}
+		return numbers
+	}
+
+	for _, samplerType := range allSamplerTypes {
+		a, b := NewSampler(samplerType, 1, 16), NewSampler(samplerType, 1, 16)
+		other := NewSampler(samplerType, 2, 16)
+		for sample := 0; sample < 20; sample++ {
+			got := draw(a, 3, 7, sample)
+			for _, number := range got {
+				if number < 0 || number >= 1 {
+					t.Fatalf("%v: %v is outside of [0..1)", samplerType, number)
+				}
+			}
+			// another sampler gives the same numbers, also after other pixels
+			draw(b, 4, 7, sample)
+			again := draw(b, 3, 7, sample)
+			for i := range got {
+				if got[i] != again[i] {
+					t.Fatalf("%v: number %d of sample %d is %v and %v", samplerType, i, sample, got[i], again[i])
+				}
+			}
+			if seeded := draw(other, 3, 7, sample); seeded[0] == got[0] && seeded[1] == got[1] {
+				t.Errorf("%v: sample %d is the same with another seed", samplerType, sample)
+			}
+		}
+	}
+}
+
+func TestSamplersStratify(t *testing.T) {
+	const samples = 16
+	for _, test := range []struct {
+		samplerType SamplerType
+		// whether the camera's 2D dimensions put one sample into every cell of a 4x4 grid
+		grid bool
+		// whether the camera's first dimension puts one sample into every 16th of [0..1), jittered grids don't
+		first bool
+		// whether the first bounce's 1D dimension puts one sample into every 16th of [0..1)
+		bounce bool
+	}{
+		{StratifiedSampler, true, false, true},
+		{HaltonSampler, false, true, false},
+		{SobolSampler, true, true, true},
+	} {
+		s := NewSampler(test.samplerType, 1, samples)
+		for _, pixel := range [][2]int{{0, 0}, {5, 9}, {100, 3}} {
+			var cells, first, bounce [samples]int
+			for sample := 0; sample < samples; sample++ {
+				s.StartPixelSample(pixel[0], pixel[1], sample)
+				u, v := s.Get2D()
+				cells[int(v*4)*4+int(u*4)]++
+				first[int(u*samples)]++
+				s.StartBounce()
+				bounce[int(s.Get1D()*samples)]++
+			}
+			for i := 0; i < samples; i++ {
+				if test.first && first[i] != 1 {
+					t.Errorf("%v at %v: %d samples in stratum %d of the first dimension", test.samplerType, pixel, first[i], i)
+				}
+				if test.grid && cells[i] != 1 {
+					t.Errorf("%v at %v: %d samples in cell %d of the camera's dimensions", test.samplerType, pixel, cells[i], i)
+				}
+				if test.bounce && bounce[i] != 1 {
+					t.Errorf("%v at %v: %d samples in stratum %d of the bounce", test.samplerType, pixel, bounce[i], i)
+				}
+			}
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 16, 33, 100} {
+		for seed := uint64(0); seed < 4; seed++ {
+			seen := make([]bool, n)
+			for i := 0; i < n; i++ {
+				p := permute(i, n, mix64(seed))
+				if p < 0 || p >= n || seen[p] {
+					t.Fatalf("permute(%d, %d) with seed %d is %d, which is outside of [0..%d) or taken", i, n, seed, p, n)
+				}
+				seen[p] = true
+			}
+		}
+	}
+}
+
+func TestRadicalInverse(t *testing.T) {
+	for _, test := range []struct {
+		base, index int
+		want        float64
+	}{
+		{2, 0, 0}, {2, 1, 0.5}, {2, 6, 0.375}, {3, 1, 1.0 / 3.0}, {3, 5, 2.0/3.0 + 1.0/9.0},
+	} {
+		if got := radicalInverse(test.base, test.index); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("radicalInverse(%d, %d) is %v, want %v", test.base, test.index, got, test.want)
+		}
+	}
+}
+
+// TestSamplersConverge renders the same scene with every sampler, which all converge to the same image,
+// and the low discrepancy ones are closer to it than independent numbers
+func TestSamplersConverge(t *testing.T) {
+	options := DefaultOptions()
+	options.Width, options.Height = 24, 24
+	options.MaxBounces = 4
+	options.Threads = 2
+	world, err := newGoldenWorldSphere(float64(options.Width), float64(options.Height))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reference has independent numbers of another seed, so it shares no samples with the renders
+	options.SamplesPerPixel = 1024
+	options.Sampler = IndependentSampler
+	options.Seed = 2
+	reference, err := RenderHDR(context.Background(), &world, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Halton only pulls ahead of independent numbers with a few dozen samples
+	options.SamplesPerPixel = 64
+	options.Seed = 1
+	errors := map[SamplerType]float64{}
+	for _, samplerType := range allSamplerTypes {
+		options.Sampler = samplerType
+		img, err := RenderHDR(context.Background(), &world, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errors[samplerType] = floatImageRMSE(img, reference)
+		if errors[samplerType] > 0.1 {
+			t.Errorf("the render with the %v sampler is %v off the reference", samplerType, errors[samplerType])
+		}
+	}
+	for _, samplerType := range []SamplerType{StratifiedSampler, HaltonSampler, SobolSampler} {
+		if errors[samplerType] >= errors[IndependentSampler] {
+			t.Errorf("the render with the %v sampler is %v off the reference, more than the independent sampler's %v",
+				samplerType, errors[samplerType], errors[IndependentSampler])
+		}
+	}
+}
+
+func TestParseSamplerType(t *testing.T) {
+	for _, samplerType := range allSamplerTypes {
+		if got, err := ParseSamplerType(samplerType.String()); err != nil || got != samplerType {
+			t.Errorf("ParseSamplerType(%q) returned %v, %v", samplerType.String(), got, err)
+		}
+	}
+	if _, err := ParseSamplerType("random"); err == nil {
+		t.Error("an unknown sampler parsed")
+	}
+}
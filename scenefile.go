@@ -0,0 +1,499 @@
+package raytracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sceneFile is the JSON representation of a World, see scenes/ for examples
+type sceneFile struct {
+	Camera sceneCamera `json:"camera"`
+	Sky    sceneSky    `json:"sky"`
+	// Environment or SunSky replace the sky if set
+	Environment *sceneEnvironment `json:"environment"`
+	SunSky      *sceneSunSky      `json:"sunSky"`
+	// Fog fills the scene outside of volumes if set
+	Fog       *sceneMedium             `json:"fog"`
+	Materials map[string]sceneMaterial `json:"materials"`
+	Objects   []sceneObject            `json:"objects"`
+}
+
+// sceneCamera holds the parameters of NewCamera, except for the image size
+type sceneCamera struct {
+	Position    Vector3  `json:"position"`
+	LookAt      Vector3  `json:"lookAt"`
+	Up          *Vector3 `json:"up"`
+	VerticalFov float64  `json:"verticalFov"`
+	Aperture    float64  `json:"aperture"`
+	// FocusDistance defaults to the distance between Position and LookAt
+	FocusDistance float64 `json:"focusDistance"`
+}
+
+type sceneSky struct {
+	Above Vector3 `json:"above"`
+	Below Vector3 `json:"below"`
+}
+
+// sceneEnvironment is an equirectangular .hdr or .pfm image, File is relative to the scene file
+type sceneEnvironment struct {
+	File string `json:"file"`
+	// Rotation around Y in degrees
+	Rotation float64 `json:"rotation"`
+	// Intensity scales the image, 1 if it's 0
+	Intensity float64 `json:"intensity"`
+}
+
+// sceneSunSky holds the parameters of NewSunSky
+type sceneSunSky struct {
+	Elevation float64 `json:"elevation"`
+	Azimuth   float64 `json:"azimuth"`
+	// Turbidity is 3 if it's 0
+	Turbidity float64 `json:"turbidity"`
+	// Intensity is 1 if it's 0
+	Intensity float64 `json:"intensity"`
+}
+
+// sceneMedium holds the coefficients of a Medium
+type sceneMedium struct {
+	Absorption Vector3 `json:"absorption"`
+	Scattering Vector3 `json:"scattering"`
+	Anisotropy float64 `json:"anisotropy"`
+}
+
+// sceneMaterial is one of "lambertian", "metal", "dielectric", "roughConductor", "roughDielectric", "principled"
+// or "light",
+// only the fields of the given type are used
+type sceneMaterial struct {
+	Type              string  `json:"type"`
+	Color             Vector3 `json:"color"`
+	Glosiness         float64 `json:"glosiness"`
+	IndexOfRefraction float64 `json:"indexOfRefraction"`
+	Emission          Vector3 `json:"emission"`
+	// Texture replaces the color of "lambertian", "metal", "roughConductor" and "principled" materials
+	Texture *sceneTexture `json:"texture"`
+	// Roughness of "roughConductor", "roughDielectric" and "principled" materials, RoughnessTexture replaces it
+	Roughness        float64       `json:"roughness"`
+	RoughnessTexture *sceneTexture `json:"roughnessTexture"`
+	// The parameters of "principled" materials, whose base color is Color
+	Metallic        float64       `json:"metallic"`
+	MetallicTexture *sceneTexture `json:"metallicTexture"`
+	Specular        float64       `json:"specular"`
+	SpecularTint    float64       `json:"specularTint"`
+	Sheen           float64       `json:"sheen"`
+	SheenTint       float64       `json:"sheenTint"`
+	Clearcoat       float64       `json:"clearcoat"`
+	ClearcoatGloss  float64       `json:"clearcoatGloss"`
+	Transmission    float64       `json:"transmission"`
+}
+
+// sceneTexture is one of "solid", "checker", "image" or "noise", only the fields of the given type are used
+type sceneTexture struct {
+	Type  string  `json:"type"`
+	Color Vector3 `json:"color"`
+
+	Even Vector3 `json:"even"`
+	Odd  Vector3 `json:"odd"`
+
+	// File is relative to the scene file
+	File string `json:"file"`
+
+	Color0  Vector3 `json:"color0"`
+	Color1  Vector3 `json:"color1"`
+	Octaves int     `json:"octaves"`
+
+	Scale float64 `json:"scale"`
+}
+
+// sceneObject is one of "sphere", "triangle", "mesh" or "volume", only the fields of the given type are used
+type sceneObject struct {
+	Type string `json:"type"`
+	// Material is optional for .obj meshes, which then use the materials of their .mtl files
+	Material string `json:"material"`
+
+	Position Vector3 `json:"position"`
+	Radius   float64 `json:"radius"`
+
+	Vertices []Vector3 `json:"vertices"`
+	// Normals are optional, the face normal is used if they're missing
+	Normals []Vector3 `json:"normals"`
+	// UVs are optional texture coordinates in X and Y
+	UVs []Vector3 `json:"uvs"`
+
+	// File is a .obj, .ply or .stl mesh relative to the scene file
+	File string `json:"file"`
+	// Overrides replaces .mtl materials of a mesh by name with scene materials
+	Overrides map[string]string `json:"overrides"`
+	// Transform is optional, transformed meshes with the same file and materials share their triangles
+	Transform *sceneTransform `json:"transform"`
+
+	// Boundary is the closed object a volume fills with its Medium, its material can be left out
+	Boundary *sceneObject `json:"boundary"`
+	Medium   *sceneMedium `json:"medium"`
+}
+
+// sceneTransform places a mesh, it's scaled first, then rotated and then translated
+type sceneTransform struct {
+	Translate Vector3        `json:"translate"`
+	Rotate    *sceneRotation `json:"rotate"`
+	// Scale defaults to 1 1 1
+	Scale *Vector3 `json:"scale"`
+}
+
+// sceneRotation is a counter-clockwise rotation around Axis by Degrees
+type sceneRotation struct {
+	Axis    Vector3 `json:"axis"`
+	Degrees float64 `json:"degrees"`
+}
+
+// LoadScene reads a JSON scene description and returns the World it describes,
+// its camera is set up for an image of width x height pixels
+func LoadScene(filePath string, width, height int) (World, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return World{}, err
+	}
+	defer file.Close()
+
+	var scene sceneFile
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&scene); err != nil {
+		return World{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	world, err := scene.toWorld(filepath.Dir(filePath), float64(width), float64(height))
+	if err != nil {
+		return World{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return world, nil
+}
+
+func (s sceneFile) toWorld(dir string, width, height float64) (World, error) {
+	materials := make(map[string]Material, len(s.Materials))
+	for name, m := range s.Materials {
+		material, err := m.toMaterial(dir)
+		if err != nil {
+			return World{}, fmt.Errorf("material %q: %w", name, err)
+		}
+		materials[name] = material
+	}
+
+	var hittables []Hittable
+	meshes := make(map[string]*BVHNode)
+	for i, o := range s.Objects {
+		objectHittables, err := o.toHittables(dir, materials, meshes)
+		if err != nil {
+			return World{}, fmt.Errorf("object %d: %w", i, err)
+		}
+		hittables = append(hittables, objectHittables...)
+	}
+
+	var environment *EnvironmentLight
+	if s.Environment != nil {
+		intensity := s.Environment.Intensity
+		if intensity == 0 {
+			intensity = 1
+		}
+		var err error
+		environment, err = ReadEnvironmentLight(resolvePath(dir, s.Environment.File), s.Environment.Rotation, intensity)
+		if err != nil {
+			return World{}, fmt.Errorf("environment: %w", err)
+		}
+	}
+	if s.SunSky != nil {
+		if environment != nil {
+			return World{}, fmt.Errorf("a scene can have an environment or a sunSky, not both")
+		}
+		turbidity, intensity := s.SunSky.Turbidity, s.SunSky.Intensity
+		if turbidity == 0 {
+			turbidity = 3
+		}
+		if intensity == 0 {
+			intensity = 1
+		}
+		environment = NewSunSky(s.SunSky.Elevation, s.SunSky.Azimuth, turbidity, intensity)
+	}
+
+	var fog *Medium
+	if s.Fog != nil {
+		var err error
+		if fog, err = s.Fog.toMedium(); err != nil {
+			return World{}, fmt.Errorf("fog: %w", err)
+		}
+	}
+
+	return World{
+		Camera:        s.Camera.toCamera(width, height),
+		Hittables:     hittables,
+		SkyColorAbove: s.Sky.Above,
+		SkyColorBelow: s.Sky.Below,
+		Environment:   environment,
+		Fog:           fog,
+	}, nil
+}
+
+func (c sceneCamera) toCamera(width, height float64) Camera {
+	up := Vector3{0, 1, 0}
+	if c.Up != nil {
+		up = *c.Up
+	}
+	focusDistance := c.FocusDistance
+	if focusDistance == 0 {
+		focusDistance = c.Position.Subtract(c.LookAt).Length()
+	}
+	return NewCamera(c.Position, c.LookAt, up, c.VerticalFov, c.Aperture, focusDistance, width, height)
+}
+
+func (m sceneMedium) toMedium() (*Medium, error) {
+	if m.Absorption.X < 0 || m.Absorption.Y < 0 || m.Absorption.Z < 0 ||
+		m.Scattering.X < 0 || m.Scattering.Y < 0 || m.Scattering.Z < 0 {
+		return nil, fmt.Errorf("medium coefficients can't be negative")
+	}
+	if m.Anisotropy <= -1 || m.Anisotropy >= 1 {
+		return nil, fmt.Errorf("medium anisotropy has to be between -1 and 1, got %v", m.Anisotropy)
+	}
+	return &Medium{Absorption: m.Absorption, Scattering: m.Scattering, Anisotropy: m.Anisotropy}, nil
+}
+
+func (m sceneMaterial) toMaterial(dir string) (Material, error) {
+	var texture Texture
+	if m.Texture != nil {
+		var err error
+		if texture, err = m.Texture.toTexture(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var roughnessTexture Texture
+	if m.RoughnessTexture != nil {
+		var err error
+		if roughnessTexture, err = m.RoughnessTexture.toTexture(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var metallicTexture Texture
+	if m.MetallicTexture != nil {
+		var err error
+		if metallicTexture, err = m.MetallicTexture.toTexture(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	switch m.Type {
+	case "lambertian":
+		return Lambertian{Color: m.Color, Texture: texture}, nil
+	case "metal":
+		return Metal{Color: m.Color, Glosiness: m.Glosiness, Texture: texture}, nil
+	case "dielectric":
+		return Dielectric{IndexOfRefraction: m.IndexOfRefraction}, nil
+	case "roughConductor":
+		return RoughConductor{Color: m.Color, Texture: texture, Roughness: m.Roughness, RoughnessTexture: roughnessTexture}, nil
+	case "roughDielectric":
+		return RoughDielectric{IndexOfRefraction: m.IndexOfRefraction, Roughness: m.Roughness, RoughnessTexture: roughnessTexture}, nil
+	case "principled":
+		return Principled{
+			BaseColor:         m.Color,
+			BaseColorTexture:  texture,
+			Metallic:          m.Metallic,
+			MetallicTexture:   metallicTexture,
+			Roughness:         m.Roughness,
+			RoughnessTexture:  roughnessTexture,
+			Specular:          m.Specular,
+			SpecularTint:      m.SpecularTint,
+			Sheen:             m.Sheen,
+			SheenTint:         m.SheenTint,
+			Clearcoat:         m.Clearcoat,
+			ClearcoatGloss:    m.ClearcoatGloss,
+			Transmission:      m.Transmission,
+			IndexOfRefraction: m.IndexOfRefraction,
+		}, nil
+	case "light":
+		return Light{Emission: m.Emission}, nil
+	default:
+		return nil, fmt.Errorf("unknown material type %q", m.Type)
+	}
+}
+
+func (t sceneTexture) toTexture(dir string) (Texture, error) {
+	switch t.Type {
+	case "solid":
+		return SolidColor{Color: t.Color}, nil
+	case "checker":
+		return CheckerTexture{Even: SolidColor{Color: t.Even}, Odd: SolidColor{Color: t.Odd}, Scale: t.Scale}, nil
+	case "image":
+		return NewImageTexture(resolvePath(dir, t.File))
+	case "noise":
+		return NoiseTexture{Color0: t.Color0, Color1: t.Color1, Scale: t.Scale, Octaves: t.Octaves}, nil
+	default:
+		return nil, fmt.Errorf("unknown texture type %q", t.Type)
+	}
+}
+
+// resolvePath returns filePath relative to dir unless it's absolute
+func resolvePath(dir, filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+	return filepath.Join(dir, filePath)
+}
+
+// toHittables returns the object's Hittables, transformed meshes are instances of the BVHs in meshes
+func (o sceneObject) toHittables(dir string, materials map[string]Material, meshes map[string]*BVHNode) ([]Hittable, error) {
+	if o.Type == "volume" {
+		return o.volume(dir, materials, meshes)
+	}
+	if o.Transform == nil {
+		return o.untransformedHittables(dir, materials)
+	}
+	if o.Type != "mesh" {
+		return nil, fmt.Errorf("only meshes can have a transform")
+	}
+
+	key := fmt.Sprint(resolvePath(dir, o.File), o.Material, o.Overrides)
+	mesh, ok := meshes[key]
+	if !ok {
+		hittables, err := o.untransformedHittables(dir, materials)
+		if err != nil {
+			return nil, err
+		}
+		mesh = NewBVHNode(hittables)
+		meshes[key] = mesh
+	}
+	if mesh == nil {
+		return nil, nil
+	}
+	instance, err := NewInstance(mesh, o.Transform.toMatrix())
+	if err != nil {
+		return nil, err
+	}
+	return []Hittable{instance}, nil
+}
+
+// volumeBoundaryMaterial names the material of volume boundaries without one, which is never used
+const volumeBoundaryMaterial = "volume boundary"
+
+// volume returns a Volume filling the object's boundary with its medium
+func (o sceneObject) volume(dir string, materials map[string]Material, meshes map[string]*BVHNode) ([]Hittable, error) {
+	if o.Boundary == nil || o.Medium == nil {
+		return nil, fmt.Errorf("volume needs a boundary and a medium")
+	}
+	medium, err := o.Medium.toMedium()
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := *o.Boundary
+	if boundary.Type == "volume" {
+		return nil, fmt.Errorf("the boundary of a volume can't be a volume")
+	}
+	if boundary.Material == "" {
+		boundary.Material = volumeBoundaryMaterial
+		withBoundary := make(map[string]Material, len(materials)+1)
+		for name, material := range materials {
+			withBoundary[name] = material
+		}
+		withBoundary[volumeBoundaryMaterial] = Lambertian{}
+		materials = withBoundary
+	}
+	hittables, err := boundary.toHittables(dir, materials, meshes)
+	if err != nil {
+		return nil, fmt.Errorf("boundary: %w", err)
+	}
+	switch len(hittables) {
+	case 0:
+		return nil, nil
+	case 1:
+		return []Hittable{Volume{Boundary: hittables[0], Medium: medium}}, nil
+	default:
+		return []Hittable{Volume{Boundary: NewBVHNode(hittables), Medium: medium}}, nil
+	}
+}
+
+// toMatrix returns the transform matrix, scale first, then rotation and then translation
+func (t sceneTransform) toMatrix() Matrix4 {
+	matrix := TranslationMatrix(t.Translate)
+	if t.Rotate != nil {
+		matrix = matrix.Multiply(RotationMatrix(t.Rotate.Axis, t.Rotate.Degrees))
+	}
+	if t.Scale != nil {
+		matrix = matrix.Multiply(ScaleMatrix(*t.Scale))
+	}
+	return matrix
+}
+
+func (o sceneObject) untransformedHittables(dir string, materials map[string]Material) ([]Hittable, error) {
+	if o.Type == "mesh" && o.Material == "" {
+		if strings.ToLower(filepath.Ext(o.File)) != ".obj" {
+			return nil, fmt.Errorf("mesh %q needs a material, only .obj meshes have .mtl materials", o.File)
+		}
+		return o.meshWithMtlMaterials(dir, materials)
+	}
+	material, ok := materials[o.Material]
+	if !ok {
+		return nil, fmt.Errorf("unknown material %q", o.Material)
+	}
+
+	switch o.Type {
+	case "sphere":
+		return []Hittable{Sphere{Position: o.Position, Radius: o.Radius, Material: material}}, nil
+	case "triangle":
+		if len(o.Vertices) != 3 {
+			return nil, fmt.Errorf("triangle needs 3 vertices, got %d", len(o.Vertices))
+		}
+		normals := o.Normals
+		switch len(normals) {
+		case 0:
+			normal := o.Vertices[1].Subtract(o.Vertices[0]).Cross(o.Vertices[2].Subtract(o.Vertices[0])).Unit()
+			normals = []Vector3{normal, normal, normal}
+		case 3:
+		default:
+			return nil, fmt.Errorf("triangle needs 0 or 3 normals, got %d", len(normals))
+		}
+		triangle := Triangle{
+			V0:       o.Vertices[0],
+			V1:       o.Vertices[1],
+			V2:       o.Vertices[2],
+			N0:       normals[0],
+			N1:       normals[1],
+			N2:       normals[2],
+			Material: material,
+		}
+		switch len(o.UVs) {
+		case 0:
+		case 3:
+			triangle.UV0, triangle.UV1, triangle.UV2 = o.UVs[0], o.UVs[1], o.UVs[2]
+		default:
+			return nil, fmt.Errorf("triangle needs 0 or 3 uvs, got %d", len(o.UVs))
+		}
+		return []Hittable{triangle}, nil
+	case "mesh":
+		triangles, err := ReadMesh(resolvePath(dir, o.File), material)
+		if err != nil {
+			return nil, err
+		}
+		return convertoToHittables(triangles), nil
+	default:
+		return nil, fmt.Errorf("unknown object type %q", o.Type)
+	}
+}
+
+// meshWithMtlMaterials loads the mesh with the materials of its .mtl files and the overrides
+func (o sceneObject) meshWithMtlMaterials(dir string, materials map[string]Material) ([]Hittable, error) {
+	options := ObjOptions{Overrides: make(map[string]Material, len(o.Overrides))}
+	for mtlName, name := range o.Overrides {
+		material, ok := materials[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown material %q", name)
+		}
+		options.Overrides[mtlName] = material
+	}
+	triangles, err := LoadObj(resolvePath(dir, o.File), options)
+	if err != nil {
+		return nil, err
+	}
+	return convertoToHittables(triangles), nil
+}
@@ -0,0 +1,91 @@
+package raytracer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSceneFilesMatchTestWorlds(t *testing.T) {
+	sceneFiles := map[string]string{
+		"sphere-triangle":       "scenes/sphere_triangle.json",
+		"sphere-triangle-light": "scenes/sphere_triangle_light.json",
+		"test-scene-obj":        "scenes/test_scene_obj.json",
+		"icosphere":             "scenes/icosphere.json",
+		"teapot":                "scenes/teapot.json",
+		"teapots":               "scenes/teapots.json",
+		"cornell-box":           "scenes/cornell_box.json",
+		"planet":                "scenes/planet.json",
+		"stairs":                "scenes/stairs.json",
+		"pyramid":               "scenes/pyramid.json",
+	}
+	for _, name := range TestWorldNames() {
+		fileName, ok := sceneFiles[name]
+		if !ok {
+			t.Errorf("test world %q has no scene file", name)
+			continue
+		}
+		want, err := TestWorld(name, 160, 90)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadScene(fileName, 160, 90)
+		if err != nil {
+			t.Errorf("%s: %v", fileName, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s doesn't match test world %q", fileName, name)
+		}
+	}
+}
+
+func TestSceneEnvironment(t *testing.T) {
+	world, err := LoadScene("scenes/environment.json", 160, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.Environment == nil {
+		t.Fatal("the scene has no environment")
+	}
+	// straight up is the deep blue of sky.hdr
+	if got, want := world.AmbientColor(Ray{Direction: Vector3{0, 1, 0}}), (Vector3{0.25, 0.45, 0.9}); got.Subtract(want).Length() > 0.02 {
+		t.Errorf("light from straight up is %v, want %v", got, want)
+	}
+}
+
+func TestSceneVolumes(t *testing.T) {
+	world, err := LoadScene("scenes/volumes.json", 160, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.Fog == nil || world.Fog.Anisotropy != 0.5 {
+		t.Errorf("the scene's fog is %+v", world.Fog)
+	}
+	volumes := 0
+	for _, h := range world.Hittables {
+		if v, ok := h.(Volume); ok {
+			volumes++
+			if _, ok := v.Boundary.(Sphere); !ok {
+				t.Errorf("the volume's boundary is a %T, want a Sphere", v.Boundary)
+			}
+		}
+	}
+	if volumes != 2 {
+		t.Errorf("the scene has %d volumes, want 2", volumes)
+	}
+
+	for _, invalid := range []string{
+		`{"objects": [{"type": "volume", "medium": {"scattering": [1, 1, 1]}}]}`,
+		`{"fog": {"scattering": [1, 1, 1], "anisotropy": 1}}`,
+		`{"fog": {"absorption": [0, -1, 0]}}`,
+	} {
+		var scene sceneFile
+		if err := json.Unmarshal([]byte(invalid), &scene); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := scene.toWorld("", 160, 160); err == nil {
+			t.Errorf("%s loaded", invalid)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package raytracer
+
+import (
+	"fmt"
+	"sort"
+)
+
+var testWorlds = map[string]func(width, height float64) (World, error){
+	"sphere-triangle":       newTestWorldSphereTriangle,
+	"sphere-triangle-light": newTestWorldSphereTriangleLight,
+	"test-scene-obj":        newTestWorldTestSceneObj,
+	"icosphere":             newTestWorldIcoSphere,
+	"teapot":                newTestWorldTeapot,
+	"teapots":               newTestWorldTeapots,
+	"cornell-box":           newTestWorldCornellBox,
+	"planet":                newTestWorldPlanet,
+	"stairs":                newTestWorldStairs,
+	"pyramid":               newTestWorldPyramid,
+}
+
+// TestWorld returns the built-in test world with the given name,
+// its camera is set up for an image of width x height pixels
+func TestWorld(name string, width, height int) (World, error) {
+	newTestWorld, ok := testWorlds[name]
+	if !ok {
+		return World{}, fmt.Errorf("unknown test world %q", name)
+	}
+	return newTestWorld(float64(width), float64(height))
+}
+
+// TestWorldNames returns the names of the built-in test worlds in alphabetical order
+func TestWorldNames() []string {
+	names := make([]string, 0, len(testWorlds))
+	for name := range testWorlds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTestWorldSphereTriangle(width, height float64) (World, error) {
+	position := Vector3{1, 0, 0}
+	lookAt := Vector3{0, 0, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 1.0 / 16.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 75.0, aperture, focusDistance, width, height)
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Triangle{
+				V0:       Vector3{-2.0, -1.0, -2.5},
+				V1:       Vector3{0.0, 2.0, -2.5},
+				V2:       Vector3{2.0, -1.0, -2.5},
+				N0:       Vector3{0, 0, 1},
+				N1:       Vector3{0, 0, 1},
+				N2:       Vector3{0, 0, 1},
+				Material: Metal{Color: Vector3{0.8, 0.8, 0.8}, Glosiness: 0.99}},
+			Sphere{
+				Position: Vector3{0, 0, -1},
+				Radius:   0.5,
+				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+			Sphere{
+				Position: Vector3{0, -100.5, -1},
+				Radius:   100,
+				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
+		},
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+func newTestWorldSphereTriangleLight(width, height float64) (World, error) {
+	position := Vector3{1, 0, 0}
+	lookAt := Vector3{0.4, 0.15, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 1.0 / 8.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Triangle{
+				V0:       Vector3{-2.0, -1.0, -2.5},
+				V1:       Vector3{0.0, 2.0, -2.5},
+				V2:       Vector3{2.0, -1.0, -2.5},
+				N0:       Vector3{0, 0, 1},
+				N1:       Vector3{0, 0, 1},
+				N2:       Vector3{0, 0, 1},
+				Material: Metal{Color: Vector3{0.8, 0.8, 0.8}, Glosiness: 0.99}},
+			Sphere{
+				Position: Vector3{0, 0, -1},
+				Radius:   0.5,
+				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+			Sphere{
+				Position: Vector3{0, 2, -0.5},
+				Radius:   0.3,
+				Material: Light{Emission: Vector3{10, 10, 10}}},
+			Sphere{
+				Position: Vector3{0, -100.5, -1},
+				Radius:   100,
+				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
+		},
+		SkyColorAbove: Vector3{0, 0, 0},
+		SkyColorBelow: Vector3{0, 0, 0},
+	}, nil
+}
+
+// func newTestWorldThreeTriangles(width, height float64) World {
+// 	position := Vector3{0, 0, 0}
+// 	lookAt := Vector3{0, 0, -1.0}
+// 	up := Vector3{0, 1, 0}
+// 	aperture := 0.0
+// 	focusDistance := position.Subtract(lookAt).Length()
+// 	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
+// 	return World{
+// 		Camera: camera,
+// 		Hittables: []Hittable{
+// 			Triangle{
+// 				V0:       Vector3{-2.0, -1.0, -2.5},
+// 				V1:       Vector3{0.0, -1.0, -2.5},
+// 				V2:       Vector3{-1.0, 1.0, -2.5},
+// 				Material: Lambertian{Color: Vector3{0.8, 0.2, 0.2}}},
+// 			Triangle{
+// 				V0:       Vector3{-1.5, -1.0, -2.8},
+// 				V1:       Vector3{0.5, -1.0, -2.8},
+// 				V2:       Vector3{-0.5, 1.0, -2.8},
+// 				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
+// 			Triangle{
+// 				V0:       Vector3{-1.0, -1.0, -3.0},
+// 				V1:       Vector3{1.0, -1.0, -3.0},
+// 				V2:       Vector3{0.0, 1.0, -3.0},
+// 				Material: Lambertian{Color: Vector3{0.2, 0.2, 0.8}}},
+// 			Sphere{
+// 				Position: Vector3{0, 0, -1},
+// 				Radius:   0.1,
+// 				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+// 			Sphere{
+// 				Position: Vector3{0, -100.5, -1},
+// 				Radius:   100,
+// 				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
+// 		}}
+// }
+
+func newTestWorldTestSceneObj(width, height float64) (World, error) {
+	position := Vector3{0, 0.5, 1}
+	lookAt := Vector3{0, 0, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 1.0 / 16.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
+	triangles, err := ReadObj("objs/test_scene.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}})
+	if err != nil {
+		return World{}, err
+	}
+	hittables := make([]Hittable, len(triangles))
+	for i := range triangles {
+		hittables[i] = triangles[i]
+	}
+
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+func newTestWorldIcoSphere(width, height float64) (World, error) {
+	position := Vector3{0, 0.8, 1}
+	lookAt := Vector3{0, 0.5, 0}
+	up := Vector3{0, 1, 0}
+	aperture := 1.0 / 32.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
+	triangles, err := ReadObj("objs/icosphere_smooth.obj", Metal{Color: Vector3{0.8, 0.8, 1.0}, Glosiness: 0.99})
+	if err != nil {
+		return World{}, err
+	}
+	// triangles := ReadObj("objs/icosphere_smooth.obj", Lambertian{Color: Vector3{0.4, 0.4, 0.9}})
+	// triangles := ReadObj("objs/icosphere_smooth.obj", Dielectric{IndexOfRefraction: 1.4})
+	hittables := make([]Hittable, len(triangles))
+	for i := range triangles {
+		hittables[i] = triangles[i]
+	}
+	hittables = append(hittables, Sphere{
+		Position: Vector3{0, -100.5, -1},
+		Radius:   100,
+		Material: Lambertian{Color: Vector3{0.8, 0.2, 0.2}}})
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+func newTestWorldTeapot(width, height float64) (World, error) {
+	position := Vector3{0, 0.5, 1}
+	lookAt := Vector3{0, 0, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 0.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
+	triangles, err := ReadObj("objs/teapot.obj", Metal{Color: Vector3{0.9, 0.3, 0.3}, Glosiness: 0.99})
+	if err != nil {
+		return World{}, err
+	}
+	hittables := make([]Hittable, len(triangles))
+	for i := range triangles {
+		hittables[i] = triangles[i]
+	}
+	hittables = append(hittables, Sphere{
+		Position: Vector3{0, -100.5, -1},
+		Radius:   100,
+		Material: Lambertian{Color: Vector3{0.6, 0.6, 0.6}}})
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+// newTestWorldTeapots returns a grid of turned copies of one teapot mesh
+func newTestWorldTeapots(width, height float64) (World, error) {
+	position := Vector3{0, 2.5, 3}
+	lookAt := Vector3{0, 0.3, -3}
+	up := Vector3{0, 1, 0}
+	aperture := 0.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 50.0, aperture, focusDistance, width, height)
+	triangles, err := ReadObj("objs/teapot.obj", Metal{Color: Vector3{0.9, 0.3, 0.3}, Glosiness: 0.99})
+	if err != nil {
+		return World{}, err
+	}
+	teapot := NewBVHNode(convertoToHittables(triangles))
+	var hittables []Hittable
+	for i := 0; i < 9; i++ {
+		position := Vector3{float64(i%3-1) * 2, 0, float64(i/3)*-2 - 1}
+		instance, err := NewInstance(teapot, TranslationMatrix(position).Multiply(RotationMatrix(Vector3{0, 1, 0}, float64(i)*40)))
+		if err != nil {
+			return World{}, err
+		}
+		hittables = append(hittables, instance)
+	}
+	hittables = append(hittables, Sphere{
+		Position: Vector3{0, -100, -1},
+		Radius:   100,
+		Material: Lambertian{Color: Vector3{0.6, 0.6, 0.6}}})
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+func newTestWorldCornellBox(width, height float64) (World, error) {
+	position := Vector3{0, 1, 1.8}
+	lookAt := Vector3{0, 1, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 0.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
+
+	triangles, err := LoadObj("objs/cornell/cornell_box.obj", ObjOptions{})
+	if err != nil {
+		return World{}, err
+	}
+	hittables := convertoToHittables(triangles)
+
+	hittables = append(hittables, Sphere{
+		Position: Vector3{-0.44, 0.4, -1.1},
+		Radius:   0.4,
+		Material: Metal{Color: Vector3{0.9, 0.9, 0.9}, Glosiness: 0.99},
+		// Material: Dielectric{IndexOfRefraction: 1.4},
+	})
+
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0, 0, 0},
+		SkyColorBelow: Vector3{0, 0, 0},
+	}, nil
+}
+
+func newTestWorldPlanet(width, height float64) (World, error) {
+	position := Vector3{0, 0, 0}
+	lookAt := Vector3{0, 0, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 0.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
+	return World{
+		Camera: camera,
+		Hittables: []Hittable{
+			Sphere{
+				Position: Vector3{0, 0, -3},
+				Radius:   0.5,
+				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+			Sphere{
+				Position: Vector3{-50, 50, -15},
+				Radius:   45,
+				Material: Light{Emission: Vector3{2.0, 2.0, 2.0}}},
+		},
+		SkyColorAbove: Vector3{0, 0, 0},
+		SkyColorBelow: Vector3{0, 0, 0},
+	}, nil
+}
+
+func newTestWorldStairs(width, height float64) (World, error) {
+	position := Vector3{0, 1, 1.8}
+	lookAt := Vector3{0, 1, -1.0}
+	up := Vector3{0, 1, 0}
+	aperture := 0.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
+
+	wallColor := Vector3{0.8, 0.8, 0.8}
+	hittables, err := readObjs(
+		objMesh{"objs/stairs/stairs_lower.obj", Lambertian{Color: wallColor}},
+		objMesh{"objs/stairs/stairs_upper.obj", Lambertian{Color: wallColor}},
+		objMesh{"objs/stairs/walls.obj", Lambertian{Color: wallColor}},
+		objMesh{"objs/stairs/light.obj", Light{Emission: Vector3{2.0, 2.0, 2.0}}},
+	)
+	if err != nil {
+		return World{}, err
+	}
+
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0, 0, 0},
+		SkyColorBelow: Vector3{0, 0, 0},
+	}, nil
+}
+
+func newTestWorldPyramid(width, height float64) (World, error) {
+	position := Vector3{0, 3, 2.6}
+	lookAt := Vector3{0, 1, -800.0}
+	up := Vector3{0, 1, 0}
+	aperture := 1.0 / 3.0
+	focusDistance := position.Subtract(lookAt).Length()
+	camera := NewCamera(position, lookAt, up, 22.0, aperture, focusDistance, width, height)
+
+	hittables, err := readObjs(
+		objMesh{"objs/pyramid/pyramid.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
+	)
+	if err != nil {
+		return World{}, err
+	}
+
+	hittables = append(hittables,
+		// Sphere{
+		// 	Position: Vector3{400, 400, -200},
+		// 	Radius:   400,
+		// 	Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
+		// },
+		// Sphere{
+		// 	Position: Vector3{600, 600, -400},
+		// 	Radius:   400,
+		// 	Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
+		// },
+		Sphere{
+			Position: Vector3{400, 400, 0},
+			Radius:   400,
+			Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
+		})
+
+	return World{
+		Camera:        camera,
+		Hittables:     hittables,
+		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
+		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
+	}, nil
+}
+
+// objMesh is a .obj file rendered with a single material
+type objMesh struct {
+	path     string
+	material Material
+}
+
+// readObjs reads the .obj files and returns the triangles of all of them
+func readObjs(meshes ...objMesh) ([]Hittable, error) {
+	var hittables []Hittable
+	for _, mesh := range meshes {
+		triangles, err := ReadObj(mesh.path, mesh.material)
+		if err != nil {
+			return nil, err
+		}
+		hittables = append(hittables, convertoToHittables(triangles)...)
+	}
+	return hittables, nil
+}
+
+func convertoToHittables(triangleArrays ...[]Triangle) []Hittable {
+	var hittables []Hittable
+	for _, array := range triangleArrays {
+		h := make([]Hittable, len(array))
+		for i := range array {
+			h[i] = array[i]
+		}
+		hittables = append(hittables, h...)
+	}
+	return hittables
+}
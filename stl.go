@@ -0,0 +1,153 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// stlHeaderSize is the size of the header of a binary .stl file, 80 bytes of text and the triangle count
+const stlHeaderSize = 84
+
+// stlTriangleSize is the size of a triangle of a binary .stl file, the normal, 3 vertices and an attribute
+const stlTriangleSize = 50
+
+// ReadStl parses a .stl file in the ASCII or binary format
+func ReadStl(filePath string, material Material) ([]Triangle, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	triangles, err := ParseStl(file, material)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return triangles, nil
+}
+
+// ParseStl parses .stl data, the triangles get the facet normals, or normals from their winding when those are zero
+// Binary files can start with "solid" too, so the format is picked by whether the data holds the triangle count,
+// some exporters pad binary files after the triangles. The count read from ASCII text is at least 0x09090909,
+// so only ASCII files of several gigabytes could be taken for binary ones
+func ParseStl(r io.Reader, material Material) ([]Triangle, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= stlHeaderSize {
+		count := binary.LittleEndian.Uint32(data[80:stlHeaderSize])
+		if uint64(len(data)) >= stlHeaderSize+uint64(count)*stlTriangleSize {
+			return parseBinaryStl(data[stlHeaderSize:], int(count), material), nil
+		}
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid")) {
+		return nil, fmt.Errorf("not a .stl file, the size doesn't match a binary file and it doesn't start with solid")
+	}
+	return parseASCIIStl(data, material)
+}
+
+func parseBinaryStl(data []byte, count int, material Material) []Triangle {
+	var triangles []Triangle
+	for i := 0; i < count; i++ {
+		var values [12]float64
+		for j := range values {
+			offset := i*stlTriangleSize + j*4
+			values[j] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[offset : offset+4])))
+		}
+		normal := Vector3{values[0], values[1], values[2]}
+		verts := [3]Vector3{
+			{values[3], values[4], values[5]},
+			{values[6], values[7], values[8]},
+			{values[9], values[10], values[11]},
+		}
+		if tri, ok := stlTriangle(normal, verts, material); ok {
+			triangles = append(triangles, tri)
+		}
+	}
+	return triangles
+}
+
+// format: solid name, facet normal nx ny nz, outer loop, vertex x y z (3 times), endloop, endfacet, endsolid name
+func parseASCIIStl(data []byte, material Material) ([]Triangle, error) {
+	var triangles []Triangle
+	var normal Vector3
+	var verts []Vector3
+	facets := 0
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNumber := i + 1
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "facet":
+			if len(fields) != 5 || fields[1] != "normal" {
+				return nil, fmt.Errorf("line %d: expected facet normal and 3 numbers", lineNumber)
+			}
+			var err error
+			if normal, err = parseStlVector(fields[2:]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			verts = verts[:0]
+		case "vertex":
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("line %d: expected vertex and 3 numbers", lineNumber)
+			}
+			vertex, err := parseStlVector(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			verts = append(verts, vertex)
+		case "endfacet":
+			if len(verts) != 3 {
+				return nil, fmt.Errorf("line %d: facet has %d vertices, want 3", lineNumber, len(verts))
+			}
+			if tri, ok := stlTriangle(normal, [3]Vector3{verts[0], verts[1], verts[2]}, material); ok {
+				triangles = append(triangles, tri)
+			}
+			facets++
+		}
+	}
+	if facets == 0 {
+		return nil, fmt.Errorf("no facets")
+	}
+	return triangles, nil
+}
+
+func parseStlVector(fields []string) (Vector3, error) {
+	var values [3]float64
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return Vector3{}, fmt.Errorf("invalid number %q", field)
+		}
+		values[i] = value
+	}
+	return Vector3{values[0], values[1], values[2]}, nil
+}
+
+// stlTriangle returns a flat shaded triangle, false if it's degenerate
+func stlTriangle(normal Vector3, verts [3]Vector3, material Material) (Triangle, bool) {
+	windingNormal := verts[1].Subtract(verts[0]).Cross(verts[2].Subtract(verts[0]))
+	if windingNormal.LengthSquared() == 0 {
+		return Triangle{}, false
+	}
+	if normal.LengthSquared() == 0 {
+		normal = windingNormal
+	}
+	normal = normal.Unit()
+	return Triangle{
+		V0: verts[0], V1: verts[1], V2: verts[2],
+		N0: normal, N1: normal, N2: normal,
+		Material: material,
+	}, true
+}
@@ -0,0 +1,84 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStlFormats(t *testing.T) {
+	// The second facet has no normal, it gets the normal of its winding
+	ascii := `solid square
+  facet normal 0 0 1
+    outer loop
+      vertex 0 0 0
+      vertex 1 0 0
+      vertex 1 1 0
+    endloop
+  endfacet
+  facet normal 0 0 0
+    outer loop
+      vertex 0 0 0
+      vertex 1 1 0
+      vertex 0 1 0
+    endloop
+  endfacet
+endsolid square
+`
+	triangles, err := ParseStl(strings.NewReader(ascii), Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triangles) != 2 {
+		t.Fatalf("got %d triangles, want 2", len(triangles))
+	}
+	want := Triangle{
+		V0: Vector3{0, 0, 0}, V1: Vector3{1, 1, 0}, V2: Vector3{0, 1, 0},
+		N0: Vector3{0, 0, 1}, N1: Vector3{0, 0, 1}, N2: Vector3{0, 0, 1},
+		Material: Lambertian{},
+	}
+	if triangles[1] != want {
+		t.Errorf("second triangle is %v, want %v", triangles[1], want)
+	}
+
+	// A binary file with a header starting with solid, like many exporters write
+	var data bytes.Buffer
+	header := make([]byte, 80)
+	copy(header, "solid square")
+	data.Write(header)
+	binary.Write(&data, binary.LittleEndian, uint32(2))
+	binary.Write(&data, binary.LittleEndian, []float32{0, 0, 1, 0, 0, 0, 1, 0, 0, 1, 1, 0})
+	binary.Write(&data, binary.LittleEndian, uint16(0))
+	binary.Write(&data, binary.LittleEndian, []float32{0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 1, 0})
+	binary.Write(&data, binary.LittleEndian, uint16(0))
+	// padding after the triangles, which some exporters write
+	data.Write(make([]byte, 16))
+
+	got, err := ParseStl(&data, Lambertian{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, triangles) {
+		t.Errorf("binary triangles %v differ from the ascii ones %v", got, triangles)
+	}
+}
+
+func TestParseStlErrors(t *testing.T) {
+	tests := []struct{ stl, want string }{
+		{"ply\n", "not a .stl file"},
+		{"solid a\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\nendfacet\n", "line 7: facet has 2 vertices"},
+		{"solid a\nfacet normal 0 0\n", "line 2: expected facet normal and 3 numbers"},
+		{"solid a\nfacet normal 0 0 1\nouter loop\nvertex 0 0 x\n", "line 4: invalid number \"x\""},
+		{"solid a\nendsolid a\n", "no facets"},
+		// a binary file cut off after its header
+		{"solid" + strings.Repeat(" ", 75) + "\x01\x00\x00\x00", "no facets"},
+	}
+	for _, test := range tests {
+		_, err := ParseStl(strings.NewReader(test.stl), Lambertian{})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parsing %q gave error %v, want %q", test.stl, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package raytracer
+
+import (
+	"math"
+)
+
+const (
+	// sunSkyScale turns the luminance of the sky model in cd/m² into radiance,
+	// so that a white surface facing a high sun is about 1
+	sunSkyScale = 1.0 / 30000.0
+	// sunAngularRadius is half of the angle the sun covers, in degrees
+	sunAngularRadius = 0.5357 / 2.0
+	// sunLuminance is the luminance of the sun outside of the atmosphere in cd/m²
+	sunLuminance = 2.0e9
+	// sunSkyWidth and sunSkyHeight are the size of the image the sky is rendered into
+	sunSkyWidth, sunSkyHeight = 512, 256
+)
+
+// NewSunSky creates an environment light of a clear sky with the sun at elevation degrees above the horizon,
+// turned azimuth degrees from -Z towards +X, and a sun disk that's sampled on its own
+// turbidity is the haziness of the air, from 2 for a very clear sky to 10 for a hazy one, intensity scales the light
+// The elevation is clamped to [0..90], the model doesn't cover twilight. Directions below the horizon see the horizon
+// Source: Preetham et al. 1999, A Practical Analytic Model for Daylight
+func NewSunSky(elevation, azimuth, turbidity, intensity float64) *EnvironmentLight {
+	elevation = Clamp(elevation, 0, 90) * math.Pi / 180.0
+	azimuth *= math.Pi / 180.0
+	sunDirection := Vector3{
+		math.Cos(elevation) * math.Sin(azimuth),
+		math.Sin(elevation),
+		-math.Cos(elevation) * math.Cos(azimuth),
+	}
+	sky := newPreethamSky(sunDirection, turbidity)
+
+	img := NewFloatImage(sunSkyWidth, sunSkyHeight)
+	// an unrotated environment, for its mapping of pixels to directions
+	e := &EnvironmentLight{cosRotation: 1}
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			direction := e.fromImage((float64(x)+0.5)/float64(img.Width), (float64(y)+0.5)/float64(img.Height))
+			img.Set(x, y, sky.radiance(direction))
+		}
+	}
+	e = NewEnvironmentLight(img, 0, intensity)
+
+	e.sun = sunDisk{
+		direction: sunDirection,
+		cosAngle:  math.Cos(sunAngularRadius * math.Pi / 180.0),
+		radiance:  sunTransmittance(sunDirection, turbidity).Scale(sunLuminance * sunSkyScale * intensity),
+	}
+	skyPower := e.averageLuminance * 4.0 * math.Pi
+	sunPower := e.sun.radiance.Luminance() * e.sun.solidAngle()
+	if sunPower > 0 {
+		e.sun.prob = sunPower / (sunPower + skyPower)
+	}
+	return e
+}
+
+// preethamSky holds the zenith color and the Perez distribution coefficients of the luminance Y
+// and the chromaticities x and y of the sky
+type preethamSky struct {
+	sunDirection Vector3
+	// thetaSun is the angle from the zenith to the sun
+	thetaSun float64
+	// zenith is the Y, x and y of the zenith
+	zenith [3]float64
+	perez  [3][5]float64
+}
+
+func newPreethamSky(sunDirection Vector3, turbidity float64) preethamSky {
+	t := turbidity
+	theta := math.Acos(Clamp(sunDirection.Y, -1, 1))
+	theta2, theta3 := theta*theta, theta*theta*theta
+
+	chi := (4.0/9.0 - t/120.0) * (math.Pi - 2.0*theta)
+	zenithY := (4.0453*t-4.9710)*math.Tan(chi) - 0.2155*t + 2.4192
+	zenithX := t*t*(0.00166*theta3-0.00375*theta2+0.00209*theta) +
+		t*(-0.02903*theta3+0.06377*theta2-0.03202*theta+0.00394) +
+		(0.11693*theta3 - 0.21196*theta2 + 0.06052*theta + 0.25886)
+	zenithy := t*t*(0.00275*theta3-0.00610*theta2+0.00317*theta) +
+		t*(-0.04214*theta3+0.08970*theta2-0.04153*theta+0.00516) +
+		(0.15346*theta3 - 0.26756*theta2 + 0.06670*theta + 0.26688)
+
+	return preethamSky{
+		sunDirection: sunDirection,
+		thetaSun:     theta,
+		// the zenith luminance is in kcd/m²
+		zenith: [3]float64{zenithY * 1000.0, zenithX, zenithy},
+		perez: [3][5]float64{
+			{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703},
+			{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452},
+			{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529},
+		},
+	}
+}
+
+// radiance returns the light of the sky arriving from the unit direction, without the sun
+func (s preethamSky) radiance(direction Vector3) Vector3 {
+	// below the horizon the sky is seen as at the horizon
+	cosTheta := math.Max(direction.Y, 1e-3)
+	cosGamma := Clamp(direction.Dot(s.sunDirection), -1, 1)
+	gamma := math.Acos(cosGamma)
+
+	var values [3]float64
+	for i, c := range s.perez {
+		value := (1.0 + c[0]*math.Exp(c[1]/cosTheta)) * (1.0 + c[2]*math.Exp(c[3]*gamma) + c[4]*cosGamma*cosGamma)
+		cosSun := math.Cos(s.thetaSun)
+		atZenith := (1.0 + c[0]*math.Exp(c[1])) * (1.0 + c[2]*math.Exp(c[3]*s.thetaSun) + c[4]*cosSun*cosSun)
+		values[i] = s.zenith[i] * value / atZenith
+	}
+	return xyYToRGB(values[1], values[2], values[0]).Scale(sunSkyScale)
+}
+
+// xyYToRGB converts a color from its CIE chromaticity and luminance to linear sRGB, clamping negative components
+func xyYToRGB(x, y, luminance float64) Vector3 {
+	if y <= 0 {
+		return Vector3{0, 0, 0}
+	}
+	cx := x / y * luminance
+	cz := (1.0 - x - y) / y * luminance
+	return Vector3{
+		math.Max(0, 3.2406*cx-1.5372*luminance-0.4986*cz),
+		math.Max(0, -0.9689*cx+1.8758*luminance+0.0415*cz),
+		math.Max(0, 0.0557*cx-0.2040*luminance+1.0570*cz),
+	}
+}
+
+// sunTransmittance returns the fraction of sunlight getting through the air towards the unit direction
+// of the sun in red, green and blue, scattered by molecules and by haze
+func sunTransmittance(sunDirection Vector3, turbidity float64) Vector3 {
+	thetaDegrees := math.Acos(Clamp(sunDirection.Y, 0, 1)) * 180.0 / math.Pi
+	// the relative length of the path through the air, source: Kasten and Young 1989
+	airMass := 1.0 / (sunDirection.Y + 0.50572*math.Pow(96.07995-thetaDegrees, -1.6364))
+	beta := 0.04608*turbidity - 0.04586
+	transmittance := func(wavelength float64) float64 {
+		// the wavelength in micrometers
+		rayleigh := math.Exp(-0.008735 * math.Pow(wavelength, -4.08) * airMass)
+		aerosol := math.Exp(-beta * math.Pow(wavelength, -1.3) * airMass)
+		return rayleigh * aerosol
+	}
+	return Vector3{transmittance(0.680), transmittance(0.550), transmittance(0.440)}
+}
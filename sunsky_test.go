@@ -0,0 +1,72 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSunSkyZenith(t *testing.T) {
+	e := NewSunSky(30, 0, 3, 1)
+	// the zenith luminance of Preetham et al. in kcd/m² for the sun 60 degrees from the zenith
+	chi := (4.0/9.0 - 3.0/120.0) * (math.Pi - 2.0*math.Pi/3.0)
+	want := ((4.0453*3-4.9710)*math.Tan(chi) - 0.2155*3 + 2.4192) * 1000 * sunSkyScale
+	// the top row of the sky image is a little off the zenith
+	if got := e.Radiance(Vector3{0, 1, 0}).Luminance(); math.Abs(got-want) > 0.01*want {
+		t.Errorf("zenith luminance is %v, want %v", got, want)
+	}
+	if toward, away := e.Radiance(Vector3{0, 0.3, -1}), e.Radiance(Vector3{0, 0.3, 1}); toward.Luminance() <= away.Luminance() {
+		t.Errorf("the sky towards the sun %v isn't brighter than away from it %v", toward, away)
+	}
+	if got := e.Radiance(e.sun.direction); got.Luminance() < 1000 {
+		t.Errorf("the sun's radiance is %v", got)
+	}
+
+	high, low := NewSunSky(60, 0, 3, 1).sun.radiance, NewSunSky(5, 0, 3, 1).sun.radiance
+	if high.X/high.Z >= low.X/low.Z || high.Luminance() <= low.Luminance() {
+		t.Errorf("the sun at 60 degrees is %v, it isn't whiter and brighter than at 5 degrees %v", high, low)
+	}
+}
+
+func TestSunSkySampling(t *testing.T) {
+	e := NewSunSky(40, 120, 4, 1)
+	sunNormal := e.sun.direction
+
+	// The light falling on a surface facing the sun, from the sun disk and from the sky summed over a grid
+	wantSun := e.sun.radiance.Luminance() * e.sun.solidAngle()
+	const thetaSteps, phiSteps = 400, 800
+	wantSky := 0.0
+	for i := 0; i < thetaSteps; i++ {
+		theta := (float64(i) + 0.5) * math.Pi / thetaSteps
+		for j := 0; j < phiSteps; j++ {
+			phi := (float64(j) + 0.5) * 2 * math.Pi / phiSteps
+			direction := Vector3{math.Sin(theta) * math.Cos(phi), math.Cos(theta), math.Sin(theta) * math.Sin(phi)}
+			if e.sun.covers(direction) {
+				continue
+			}
+			cosine := math.Max(0, direction.Dot(sunNormal))
+			wantSky += e.Radiance(direction).Luminance() * cosine * math.Sin(theta) * (math.Pi / thetaSteps) * (2 * math.Pi / phiSteps)
+		}
+	}
+
+	// sample weighs the directions it picks by its pdf, which includes the sun
+	sampler := newIndependentSampler(1)
+	const samples = 200000
+	got := 0.0
+	sunSamples := 0
+	for i := 0; i < samples; i++ {
+		direction, pdf := e.sample(sampler)
+		if pdf <= 0 {
+			continue
+		}
+		if e.sun.covers(direction) {
+			sunSamples++
+		}
+		got += e.Radiance(direction).Luminance() * math.Max(0, direction.Dot(sunNormal)) / pdf / samples
+	}
+	if want := wantSun + wantSky; math.Abs(got-want) > 0.02*want {
+		t.Errorf("sampled irradiance is %v, want %v from the sun and %v from the sky", got, wantSun, wantSky)
+	}
+	if fraction := float64(sunSamples) / samples; math.Abs(fraction-e.sun.prob) > 0.01 {
+		t.Errorf("%v of the samples hit the sun, want %v", fraction, e.sun.prob)
+	}
+}
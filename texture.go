@@ -0,0 +1,228 @@
+package raytracer
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+
+	// register the decoders image.Decode can use
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Texture determines the color at a point of a surface
+type Texture interface {
+	// Value returns the color at the u, v texture coordinates, p is the point in world space
+	Value(u, v float64, p Vector3) Vector3
+}
+
+// SolidColor is a Texture with a single color
+type SolidColor struct {
+	Color Vector3
+}
+
+// Value returns the color
+func (s SolidColor) Value(u, v float64, p Vector3) Vector3 {
+	return s.Color
+}
+
+// CheckerTexture alternates between two textures in a checkerboard pattern over the texture coordinates
+type CheckerTexture struct {
+	Even, Odd Texture
+	// Scale is the number of squares along each texture coordinate
+	Scale float64
+}
+
+// Value returns the value of the Even or the Odd texture depending on the square u, v is in
+func (c CheckerTexture) Value(u, v float64, p Vector3) Vector3 {
+	square := int(math.Floor(u*c.Scale)) + int(math.Floor(v*c.Scale))
+	if square%2 == 0 {
+		return c.Even.Value(u, v, p)
+	}
+	return c.Odd.Value(u, v, p)
+}
+
+// ImageTexture maps an image over the texture coordinates, repeating it outside of [0..1]
+type ImageTexture struct {
+	width, height int
+	// pixels hold the linear colors of the image, row by row from the top
+	pixels []Vector3
+}
+
+// NewImageTexture loads a PNG or JPEG image as a texture, the image is expected to be sRGB encoded
+func NewImageTexture(filePath string) (*ImageTexture, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return NewImageTextureFromImage(img), nil
+}
+
+// NewImageTextureFromImage creates a texture from an sRGB encoded image
+func NewImageTextureFromImage(img image.Image) *ImageTexture {
+	return newImageTexture(img, srgbToLinear)
+}
+
+// newImageTexture creates a texture from an image whose components are turned into linear values by decode,
+// images holding data rather than colors are already linear
+func newImageTexture(img image.Image, decode func(float64) float64) *ImageTexture {
+	bounds := img.Bounds()
+	t := &ImageTexture{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		pixels: make([]Vector3, bounds.Dx()*bounds.Dy()),
+	}
+	for y := 0; y < t.height; y++ {
+		for x := 0; x < t.width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			t.pixels[y*t.width+x] = Vector3{
+				X: decode(float64(r) / 0xffff),
+				Y: decode(float64(g) / 0xffff),
+				Z: decode(float64(b) / 0xffff),
+			}
+		}
+	}
+	return t
+}
+
+// Value returns the color of the pixel at u, v, with v pointing up in the image
+func (t *ImageTexture) Value(u, v float64, p Vector3) Vector3 {
+	if t.width == 0 || t.height == 0 {
+		return Vector3{0, 0, 0}
+	}
+	u -= math.Floor(u)
+	v -= math.Floor(v)
+	x := int(u * float64(t.width))
+	y := int((1.0 - v) * float64(t.height))
+	if x >= t.width {
+		x = t.width - 1
+	}
+	if y >= t.height {
+		y = t.height - 1
+	}
+	return t.pixels[y*t.width+x]
+}
+
+// NoiseTexture blends between two colors with Perlin noise in world space
+type NoiseTexture struct {
+	Color0, Color1 Vector3
+	// Scale is the frequency of the noise
+	Scale float64
+	// Octaves adds turbulence when > 1
+	Octaves int
+}
+
+// Value returns the blend of the colors at p
+func (n NoiseTexture) Value(u, v float64, p Vector3) Vector3 {
+	p = p.Scale(n.Scale)
+	var t float64
+	if n.Octaves > 1 {
+		t = turbulence(p, n.Octaves)
+	} else {
+		t = 0.5 * (1.0 + perlinNoise(p))
+	}
+	t = Clamp(t, 0, 1)
+	return n.Color0.Scale(1.0 - t).Add(n.Color1.Scale(t))
+}
+
+// turbulence returns the sum of octaves of noise with halving amplitude and doubling frequency
+func turbulence(p Vector3, octaves int) float64 {
+	sum := 0.0
+	weight := 1.0
+	for i := 0; i < octaves; i++ {
+		sum += weight * math.Abs(perlinNoise(p))
+		weight *= 0.5
+		p = p.Scale(2.0)
+	}
+	return sum
+}
+
+// perlinNoise returns Ken Perlin's improved noise at p in [-1..1]
+// Source: https://mrl.cs.nyu.edu/~perlin/noise/
+func perlinNoise(p Vector3) float64 {
+	fx, fy, fz := math.Floor(p.X), math.Floor(p.Y), math.Floor(p.Z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z := p.X-fx, p.Y-fy, p.Z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	A := perlinPermutation[X] + Y
+	AA := perlinPermutation[A] + Z
+	AB := perlinPermutation[A+1] + Z
+	B := perlinPermutation[X+1] + Y
+	BA := perlinPermutation[B] + Z
+	BB := perlinPermutation[B+1] + Z
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(perlinPermutation[AA], x, y, z), grad(perlinPermutation[BA], x-1, y, z)),
+			lerp(u, grad(perlinPermutation[AB], x, y-1, z), grad(perlinPermutation[BB], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(perlinPermutation[AA+1], x, y, z-1), grad(perlinPermutation[BA+1], x-1, y, z-1)),
+			lerp(u, grad(perlinPermutation[AB+1], x, y-1, z-1), grad(perlinPermutation[BB+1], x-1, y-1, z-1))))
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+var perlinPermutation = func() [512]int {
+	permutation := [256]int{151, 160, 137, 91, 90, 15,
+		131, 13, 201, 95, 96, 53, 194, 233, 7, 225, 140, 36, 103, 30, 69, 142, 8, 99, 37, 240, 21, 10, 23,
+		190, 6, 148, 247, 120, 234, 75, 0, 26, 197, 62, 94, 252, 219, 203, 117, 35, 11, 32, 57, 177, 33,
+		88, 237, 149, 56, 87, 174, 20, 125, 136, 171, 168, 68, 175, 74, 165, 71, 134, 139, 48, 27, 166,
+		77, 146, 158, 231, 83, 111, 229, 122, 60, 211, 133, 230, 220, 105, 92, 41, 55, 46, 245, 40, 244,
+		102, 143, 54, 65, 25, 63, 161, 1, 216, 80, 73, 209, 76, 132, 187, 208, 89, 18, 169, 200, 196,
+		135, 130, 116, 188, 159, 86, 164, 100, 109, 198, 173, 186, 3, 64, 52, 217, 226, 250, 124, 123,
+		5, 202, 38, 147, 118, 126, 255, 82, 85, 212, 207, 206, 59, 227, 47, 16, 58, 17, 182, 189, 28, 42,
+		223, 183, 170, 213, 119, 248, 152, 2, 44, 154, 163, 70, 221, 153, 101, 155, 167, 43, 172, 9,
+		129, 22, 39, 253, 19, 98, 108, 110, 79, 113, 224, 232, 178, 185, 112, 104, 218, 246, 97, 228,
+		251, 34, 242, 193, 238, 210, 144, 12, 191, 179, 162, 241, 81, 51, 145, 235, 249, 14, 239, 107,
+		49, 192, 214, 31, 181, 199, 106, 157, 184, 84, 204, 176, 115, 121, 50, 45, 127, 4, 150, 254,
+		138, 236, 205, 93, 222, 114, 67, 29, 24, 72, 243, 141, 128, 195, 78, 66, 215, 61, 156, 180}
+	var p [512]int
+	for i := 0; i < 256; i++ {
+		p[i] = permutation[i]
+		p[256+i] = permutation[i]
+	}
+	return p
+}()
+
+// srgbToLinear decodes an sRGB encoded color component in [0..1]
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
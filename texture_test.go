@@ -0,0 +1,63 @@
+package raytracer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestCheckerTexture(t *testing.T) {
+	black, white := Vector3{0, 0, 0}, Vector3{1, 1, 1}
+	checker := CheckerTexture{Even: SolidColor{black}, Odd: SolidColor{white}, Scale: 2}
+	tests := []struct {
+		u, v float64
+		want Vector3
+	}{
+		{0.25, 0.25, black},
+		{0.75, 0.25, white},
+		{0.25, 0.75, white},
+		{0.75, 0.75, black},
+		{-0.25, 0.25, white},
+	}
+	for _, test := range tests {
+		if got := checker.Value(test.u, test.v, Vector3{}); got != test.want {
+			t.Errorf("Value(%v, %v) = %v, want %v", test.u, test.v, got, test.want)
+		}
+	}
+}
+
+func TestImageTexture(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255}) // top left
+	img.Set(1, 1, color.RGBA{0, 0, 255, 255}) // bottom right
+	texture := NewImageTextureFromImage(img)
+
+	if got := texture.Value(0.25, 0.75, Vector3{}); got != (Vector3{1, 0, 0}) {
+		t.Errorf("top left is %v, want red", got)
+	}
+	if got := texture.Value(0.75, 0.25, Vector3{}); got != (Vector3{0, 0, 1}) {
+		t.Errorf("bottom right is %v, want blue", got)
+	}
+	if got := texture.Value(1.25, -0.75, Vector3{}); got != (Vector3{0, 0, 0}) {
+		t.Errorf("wrapped bottom left is %v, want black", got)
+	}
+}
+
+func TestHitTextureCoordinates(t *testing.T) {
+	sphere := Sphere{Position: Vector3{0, 0, 0}, Radius: 2}
+	hitRecord, _ := sphere.Hit(Ray{Origin: Vector3{0, 5, 0}, Direction: Vector3{0, -1, 0}}, 0.001, math.Inf(1))
+	if math.Abs(hitRecord.V-1.0) > 1e-9 {
+		t.Errorf("top of the sphere has v %v, want 1", hitRecord.V)
+	}
+
+	triangle := Triangle{
+		V0: Vector3{0, 0, 0}, V1: Vector3{1, 0, 0}, V2: Vector3{0, 1, 0},
+		N0: Vector3{0, 0, 1}, N1: Vector3{0, 0, 1}, N2: Vector3{0, 0, 1},
+		UV0: Vector3{0.5, 0.5, 0}, UV1: Vector3{1, 0.5, 0}, UV2: Vector3{0.5, 1, 0},
+	}
+	hitRecord, _ = triangle.Hit(Ray{Origin: Vector3{0.5, 0.25, 1}, Direction: Vector3{0, 0, -1}}, 0.001, math.Inf(1))
+	if math.Abs(hitRecord.U-0.75) > 1e-9 || math.Abs(hitRecord.V-0.625) > 1e-9 {
+		t.Errorf("triangle hit has uv %v, %v, want 0.75, 0.625", hitRecord.U, hitRecord.V)
+	}
+}
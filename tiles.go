@@ -0,0 +1,32 @@
+package raytracer
+
+import "image"
+
+// defaultTileSize is the side of the tiles when Options.TileSize isn't set
+const defaultTileSize = 32
+
+// spiralTiles splits a width x height image into square tiles of size pixels, clipped at the edges,
+// and orders them in a spiral from the middle of the image outwards so the interesting parts finish first
+func spiralTiles(width, height, size int) []image.Rectangle {
+	columns, rows := (width+size-1)/size, (height+size-1)/size
+	bounds := image.Rect(0, 0, width, height)
+	tiles := make([]image.Rectangle, 0, columns*rows)
+
+	// walk right, down, left and up, one more step every second turn, and keep the tiles inside the image
+	x, y := (columns-1)/2, (rows-1)/2
+	dx, dy := 1, 0
+	steps := 1
+	for len(tiles) < columns*rows {
+		for turn := 0; turn < 2; turn++ {
+			for step := 0; step < steps; step++ {
+				if x >= 0 && x < columns && y >= 0 && y < rows {
+					tiles = append(tiles, image.Rect(x*size, y*size, (x+1)*size, (y+1)*size).Intersect(bounds))
+				}
+				x, y = x+dx, y+dy
+			}
+			dx, dy = -dy, dx
+		}
+		steps++
+	}
+	return tiles
+}
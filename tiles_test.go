@@ -0,0 +1,36 @@
+package raytracer
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSpiralTilesCoverTheImage(t *testing.T) {
+	for _, test := range []struct{ width, height, size int }{
+		{100, 100, 32},
+		{7, 300, 16},
+		{500, 20, 8},
+		{1, 1, 32},
+	} {
+		tiles := spiralTiles(test.width, test.height, test.size)
+		covered := make([]int, test.width*test.height)
+		for _, tile := range tiles {
+			if tile.Dx() > test.size || tile.Dy() > test.size {
+				t.Errorf("%+v: tile %v is bigger than %d pixels", test, tile, test.size)
+			}
+			for y := tile.Min.Y; y < tile.Max.Y; y++ {
+				for x := tile.Min.X; x < tile.Max.X; x++ {
+					covered[y*test.width+x]++
+				}
+			}
+		}
+		for i, count := range covered {
+			if count != 1 {
+				t.Fatalf("%+v: pixel %d, %d is in %d tiles", test, i%test.width, i/test.width, count)
+			}
+		}
+		if middle := image.Pt(test.width/2, test.height/2); !middle.In(tiles[0]) && test.size < test.width && test.size < test.height {
+			t.Errorf("%+v: the first tile %v doesn't cover the middle %v", test, tiles[0], middle)
+		}
+	}
+}
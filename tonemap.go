@@ -0,0 +1,105 @@
+package raytracer
+
+import (
+	"fmt"
+	"math"
+)
+
+// ToneMapOperator compresses linear radiance into [0..1] for display
+type ToneMapOperator int
+
+// The available tone mapping operators
+const (
+	// ToneMapClamp cuts off everything above 1.0
+	ToneMapClamp ToneMapOperator = iota
+	// ToneMapReinhard maps x to x / (1 + x)
+	ToneMapReinhard
+	// ToneMapExtendedReinhard is Reinhard's operator with WhitePoint mapped to 1.0
+	ToneMapExtendedReinhard
+	// ToneMapACES is Krzysztof Narkowicz's fit of the ACES filmic curve
+	ToneMapACES
+	// ToneMapHable is John Hable's filmic curve from Uncharted 2, with WhitePoint mapped to 1.0
+	ToneMapHable
+)
+
+var toneMapOperatorNames = []string{"clamp", "reinhard", "reinhard-extended", "aces", "hable"}
+
+// ParseToneMapOperator returns the operator with the name returned by its String method
+func ParseToneMapOperator(name string) (ToneMapOperator, error) {
+	for i, operatorName := range toneMapOperatorNames {
+		if name == operatorName {
+			return ToneMapOperator(i), nil
+		}
+	}
+	return ToneMapClamp, fmt.Errorf("unknown tone mapping operator %q", name)
+}
+
+// String returns the name of the operator
+func (o ToneMapOperator) String() string {
+	if o < 0 || int(o) >= len(toneMapOperatorNames) {
+		return fmt.Sprintf("ToneMapOperator(%d)", int(o))
+	}
+	return toneMapOperatorNames[o]
+}
+
+// ToneMapping configures the conversion of linear radiance to sRGB display colors
+// The zero value clamps without changing the exposure
+type ToneMapping struct {
+	Operator ToneMapOperator
+	// Exposure scales the radiance by 2^Exposure before tone mapping
+	Exposure float64
+	// WhitePoint is the radiance mapped to white by ToneMapExtendedReinhard and ToneMapHable,
+	// 0 uses 4.0 and 11.2 respectively
+	WhitePoint float64
+}
+
+// Apply returns the display color of the linear radiance, sRGB encoded and in [0..1]
+func (t ToneMapping) Apply(c Vector3) Vector3 {
+	c = c.Scale(math.Exp2(t.Exposure))
+	mapped := Vector3{t.mapComponent(c.X), t.mapComponent(c.Y), t.mapComponent(c.Z)}
+	return Vector3{
+		X: linearToSRGB(Clamp(mapped.X, 0, 1)),
+		Y: linearToSRGB(Clamp(mapped.Y, 0, 1)),
+		Z: linearToSRGB(Clamp(mapped.Z, 0, 1)),
+	}
+}
+
+func (t ToneMapping) mapComponent(x float64) float64 {
+	x = math.Max(x, 0)
+	switch t.Operator {
+	case ToneMapReinhard:
+		return x / (1.0 + x)
+	case ToneMapExtendedReinhard:
+		white := t.WhitePoint
+		if white <= 0 {
+			white = 4.0
+		}
+		return x * (1.0 + x/(white*white)) / (1.0 + x)
+	case ToneMapACES:
+		// Source: https://knarkowicz.wordpress.com/2016/01/06/aces-filmic-tone-mapping-curve/
+		return (x * (2.51*x + 0.03)) / (x*(2.43*x+0.59) + 0.14)
+	case ToneMapHable:
+		// Source: http://filmicworlds.com/blog/filmic-tonemapping-operators/
+		white := t.WhitePoint
+		if white <= 0 {
+			white = 11.2
+		}
+		const exposureBias = 2.0
+		return hableCurve(x*exposureBias) / hableCurve(white)
+	default:
+		return x
+	}
+}
+
+func hableCurve(x float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+// linearToSRGB encodes a linear color component in [0..1] with the sRGB transfer function
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1.0/2.4) - 0.055
+}
@@ -0,0 +1,57 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLinearToSRGB(t *testing.T) {
+	tests := []struct{ linear, want float64 }{
+		{0, 0},
+		{0.002, 0.02584},
+		{0.18, 0.46135},
+		{1, 1},
+	}
+	for _, test := range tests {
+		if got := linearToSRGB(test.linear); math.Abs(got-test.want) > 1e-4 {
+			t.Errorf("linearToSRGB(%v) = %v, want %v", test.linear, got, test.want)
+		}
+		if got := srgbToLinear(linearToSRGB(test.linear)); math.Abs(got-test.linear) > 1e-9 {
+			t.Errorf("srgbToLinear doesn't invert linearToSRGB(%v), got %v", test.linear, got)
+		}
+	}
+}
+
+func TestToneMapOperators(t *testing.T) {
+	for i := range toneMapOperatorNames {
+		operator, err := ParseToneMapOperator(toneMapOperatorNames[i])
+		if err != nil || operator.String() != toneMapOperatorNames[i] {
+			t.Fatalf("operator %q doesn't round trip: %v, %v", toneMapOperatorNames[i], operator, err)
+		}
+		toneMapping := ToneMapping{Operator: operator}
+
+		previous := -1.0
+		for _, radiance := range []float64{0, 0.01, 0.1, 0.5, 1, 2, 10, 100} {
+			mapped := toneMapping.Apply(Vector3{radiance, radiance, radiance}).X
+			if mapped < 0 || mapped > 1 {
+				t.Errorf("%v maps %v out of range to %v", operator, radiance, mapped)
+			}
+			if mapped < previous {
+				t.Errorf("%v isn't monotonic, maps %v to %v below %v", operator, radiance, mapped, previous)
+			}
+			previous = mapped
+		}
+		if black := toneMapping.Apply(Vector3{0, 0, 0}); black.Length() > 1e-6 {
+			t.Errorf("%v maps black to %v", operator, black)
+		}
+	}
+
+	white := ToneMapping{Operator: ToneMapExtendedReinhard, WhitePoint: 8}.Apply(Vector3{8, 8, 8})
+	if math.Abs(white.X-1) > 1e-9 {
+		t.Errorf("extended Reinhard maps the white point to %v, want 1", white.X)
+	}
+	exposed := ToneMapping{Exposure: 1}.Apply(Vector3{0.25, 0.25, 0.25})
+	if want := linearToSRGB(0.5); math.Abs(exposed.X-want) > 1e-9 {
+		t.Errorf("one stop of exposure maps 0.25 to %v, want %v", exposed.X, want)
+	}
+}
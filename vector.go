@@ -1,9 +1,10 @@
-package main
+package raytracer
 
 import (
+	"encoding/json"
+	"fmt"
 	"image/color"
 	"math"
-	"math/rand"
 )
 
 // Vector3 is a 3d vector
@@ -104,6 +105,30 @@ func (a Vector3) Refract(normal Vector3, coefficient float64) Vector3 {
 	return perpendicular.Add(parallel)
 }
 
+// Axis returns the component of the vector along the axis, 0 is X, 1 is Y and 2 is Z
+func (a Vector3) Axis(axis int) float64 {
+	switch axis {
+	case 0:
+		return a.X
+	case 1:
+		return a.Y
+	default:
+		return a.Z
+	}
+}
+
+func (a Vector3) setAxis(axis int, value float64) Vector3 {
+	switch axis {
+	case 0:
+		a.X = value
+	case 1:
+		a.Y = value
+	default:
+		a.Z = value
+	}
+	return a
+}
+
 // ToColor converts the vector to a image.Color.RGBA and returns the result
 // The values are expected to be [0..1]
 func (a Vector3) ToColor() color.Color {
@@ -115,28 +140,56 @@ func (a Vector3) ToColor() color.Color {
 	}
 }
 
-// RandomInUnitSphere returns a random vector inside a unit sphere
-func RandomInUnitSphere(r *rand.Rand) Vector3 {
-	for {
-		vector := Vector3{
-			X: r.Float64()*2.0 - 1.0,
-			Y: r.Float64()*2.0 - 1.0,
-			Z: r.Float64()*2.0 - 1.0,
-		}
-		if vector.LengthSquared() < 1 {
-			return vector
-		}
+// Luminance returns the luminance of the vector as a linear RGB color
+func (a Vector3) Luminance() float64 {
+	return 0.2126*a.X + 0.7152*a.Y + 0.0722*a.Z
+}
+
+// orthonormalBasis returns two unit vectors that form an orthonormal basis with the unit vector n
+// Source: https://graphics.pixar.com/library/OrthonormalB/paper.pdf
+func orthonormalBasis(n Vector3) (Vector3, Vector3) {
+	sign := math.Copysign(1.0, n.Z)
+	a := -1.0 / (sign + n.Z)
+	b := n.X * n.Y * a
+	return Vector3{1.0 + sign*n.X*n.X*a, sign * b, -sign * n.X},
+		Vector3{b, sign + n.Y*n.Y*a, -n.Y}
+}
+
+// MarshalJSON encodes the vector as an array of 3 numbers
+func (a Vector3) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]float64{a.X, a.Y, a.Z})
+}
+
+// UnmarshalJSON decodes the vector from an array of 3 numbers
+func (a *Vector3) UnmarshalJSON(data []byte) error {
+	var components []float64
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+	if len(components) != 3 {
+		return fmt.Errorf("vector needs 3 components, got %d", len(components))
 	}
+	*a = Vector3{components[0], components[1], components[2]}
+	return nil
+}
+
+// RandomInUnitSphere returns a random vector inside a unit sphere
+func RandomInUnitSphere(s Sampler) Vector3 {
+	return RandomOnUnitSphere(s).Scale(math.Cbrt(s.Get1D()))
 }
 
 // RandomOnUnitSphere returns a random vector on the surface of a unit sphere
-func RandomOnUnitSphere(r *rand.Rand) Vector3 {
-	return RandomInUnitSphere(r).Unit()
+func RandomOnUnitSphere(s Sampler) Vector3 {
+	u1, u2 := s.Get2D()
+	z := 1.0 - 2.0*u1
+	r := math.Sqrt(math.Max(0, 1.0-z*z))
+	phi := 2.0 * math.Pi * u2
+	return Vector3{r * math.Cos(phi), r * math.Sin(phi), z}
 }
 
 // RandomInUnitHemisphere returns a random vector in the unit hemisphere determined by the normal
-func RandomInUnitHemisphere(normal Vector3, r *rand.Rand) Vector3 {
-	if inUnitSphere := RandomInUnitSphere(r); inUnitSphere.Dot(normal) > 0.0 {
+func RandomInUnitHemisphere(normal Vector3, s Sampler) Vector3 {
+	if inUnitSphere := RandomInUnitSphere(s); inUnitSphere.Dot(normal) > 0.0 {
 		return inUnitSphere
 	} else {
 		return inUnitSphere.Scale(-1.0)
@@ -144,15 +197,18 @@ func RandomInUnitHemisphere(normal Vector3, r *rand.Rand) Vector3 {
 }
 
 // RandomOnUnitDisk returns a random vector on a unit disk
-func RandomOnUnitDisk(r *rand.Rand) Vector3 {
-	for {
-		vector := Vector3{
-			X: r.Float64()*2.0 - 1.0,
-			Y: r.Float64()*2.0 - 1.0,
-			Z: 0,
-		}
-		if vector.LengthSquared() < 1 {
-			return vector
-		}
+// The concentric mapping keeps samples that are spread out well spread out on the disk,
+// source: https://www.pbr-book.org/3ed-2018/Monte_Carlo_Integration/2D_Sampling_with_Multidimensional_Transformations
+func RandomOnUnitDisk(s Sampler) Vector3 {
+	u1, u2 := s.Get2D()
+	x, y := 2.0*u1-1.0, 2.0*u2-1.0
+	if x == 0 && y == 0 {
+		return Vector3{0, 0, 0}
+	}
+	if math.Abs(x) > math.Abs(y) {
+		phi := math.Pi / 4.0 * y / x
+		return Vector3{x * math.Cos(phi), x * math.Sin(phi), 0}
 	}
+	phi := math.Pi/2.0 - math.Pi/4.0*x/y
+	return Vector3{y * math.Cos(phi), y * math.Sin(phi), 0}
 }
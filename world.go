@@ -1,14 +1,104 @@
-package main
+package raytracer
 
 // World holds the objects in it
 type World struct {
 	Camera                       Camera
 	Hittables                    []Hittable
 	SkyColorBelow, SkyColorAbove Vector3
+	// Environment replaces the sky colors if set, it's sampled like the other lights
+	Environment *EnvironmentLight
+	// Lights are the lights rays can't hit, Hittables with a Light material are lights too
+	Lights []DeltaLight
+	// Fog fills the box around the Hittables if set, outside of volumes
+	Fog *Medium
+
+	bvh         *BVHNode
+	lights      []*areaLight
+	deltaLights []DeltaLight
+	// lightCDF picks from lights followed by deltaLights and the Environment
+	lightCDF []float64
+	// environmentSelectionPDF is the probability of lightCDF picking the Environment
+	environmentSelectionPDF float64
+	// fogBox is the box the Fog fills
+	fogBox AABB
+}
+
+// Build prepares the world for rendering by collecting its lights and building a bounding volume hierarchy over its Hittables
+// It numbers the objects of the Hittables from 1 for AOVObjectID, every Hittable is an object except for runs of triangles
+// with the same material, which are one object as meshes are loaded that way
+// It numbers the materials of the objects from 1 for AOVMaterialID too, every sphere or triangle object has a material
+// of its own, materials aren't compared between objects. Other Hittables, like Instances, have none
+// It has to be called again after Hittables change
+func (w *World) Build() {
+	hittables := w.buildLights()
+	w.fogBox = hittablesBox(hittables)
+	w.bvh = NewBVHNode(identifyObjects(hittables))
+}
+
+// objectHittable marks the hits of a Hittable with the ID of the object it belongs to and the index of its material
+type objectHittable struct {
+	Hittable
+	id int
+	// material is 0 for objects without a single material
+	material int
+}
+
+// Hit returns the hit of the Hittable with the object's ID and material index
+func (o objectHittable) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	hitRecord, hit := o.Hittable.Hit(r, tMin, tMax)
+	if hit {
+		hitRecord.objectID = o.id
+		hitRecord.materialID = o.material
+	}
+	return hitRecord, hit
+}
+
+// identifyObjects returns the hittables wrapped with the IDs of their objects and the indexes of their materials
+func identifyObjects(hittables []Hittable) []Hittable {
+	identified := make([]Hittable, len(hittables))
+	id, material := 0, 0
+	var previous Material
+	for i, h := range hittables {
+		m, isTriangle := shapeMaterial(h)
+		if !isTriangle || !sameMaterial(m, previous) {
+			id++
+			if m != nil {
+				material++
+			}
+		}
+		// only triangles continue a run
+		previous = nil
+		if isTriangle {
+			previous = m
+		}
+		o := objectHittable{Hittable: h, id: id}
+		if m != nil {
+			o.material = material
+		}
+		identified[i] = o
+	}
+	return identified
+}
+
+// shapeMaterial returns the material of a sphere or a triangle, and whether h is a triangle, nil for other Hittables
+func shapeMaterial(h Hittable) (Material, bool) {
+	if l, ok := h.(*areaLight); ok {
+		h = l.shape
+	}
+	switch s := h.(type) {
+	case Triangle:
+		return s.Material, true
+	case Sphere:
+		return s.Material, false
+	}
+	return nil, false
 }
 
 // Hit returns a HitRecord and true if any hits, nil and false otherwise
 func (w *World) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
+	if w.bvh != nil {
+		return w.bvh.Hit(r, tMin, tMax)
+	}
 	hitAnything := false
 	closestT := tMax
 	var hitRecord *HitRecord
@@ -23,316 +113,14 @@ func (w *World) Hit(r Ray, tMin, tMax float64) (*HitRecord, bool) {
 	return hitRecord, hitAnything
 }
 
-// AmbientColor returns the ambient color based on the ray
+// AmbientColor returns the light of the Environment or the sky colors arriving along the ray
 func (w *World) AmbientColor(r Ray) Vector3 {
+	if w.Environment != nil {
+		return w.Environment.Radiance(r.Direction)
+	}
 	unitDirection := r.Direction.Unit()
 	t := 0.5 * (unitDirection.Y + 1.0)
 	a := w.SkyColorAbove.Scale(t)
 	b := w.SkyColorBelow.Scale(1.0 - t)
 	return a.Add(b)
 }
-
-func newTestWorldSphereTriangle() World {
-	position := Vector3{1, 0, 0}
-	lookAt := Vector3{0, 0, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 1.0 / 16.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 75.0, aperture, focusDistance, width, height)
-	return World{
-		Camera: camera,
-		Hittables: []Hittable{
-			Triangle{
-				V0:       Vector3{-2.0, -1.0, -2.5},
-				V1:       Vector3{0.0, 2.0, -2.5},
-				V2:       Vector3{2.0, -1.0, -2.5},
-				N0:       Vector3{0, 0, 1},
-				N1:       Vector3{0, 0, 1},
-				N2:       Vector3{0, 0, 1},
-				Material: Metal{Color: Vector3{0.8, 0.8, 0.8}, Glosiness: 0.99}},
-			Sphere{
-				Position: Vector3{0, 0, -1},
-				Radius:   0.5,
-				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
-			Sphere{
-				Position: Vector3{0, -100.5, -1},
-				Radius:   100,
-				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
-		},
-		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
-		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
-	}
-}
-
-func newTestWorldSphereTriangleLight() World {
-	position := Vector3{1, 0, 0}
-	lookAt := Vector3{0.4, 0.15, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 1.0 / 8.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
-	return World{
-		Camera: camera,
-		Hittables: []Hittable{
-			Triangle{
-				V0:       Vector3{-2.0, -1.0, -2.5},
-				V1:       Vector3{0.0, 2.0, -2.5},
-				V2:       Vector3{2.0, -1.0, -2.5},
-				N0:       Vector3{0, 0, 1},
-				N1:       Vector3{0, 0, 1},
-				N2:       Vector3{0, 0, 1},
-				Material: Metal{Color: Vector3{0.8, 0.8, 0.8}, Glosiness: 0.99}},
-			Sphere{
-				Position: Vector3{0, 0, -1},
-				Radius:   0.5,
-				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
-			Sphere{
-				Position: Vector3{0, 2, -0.5},
-				Radius:   0.3,
-				Material: Light{Emission: Vector3{10, 10, 10}}},
-			Sphere{
-				Position: Vector3{0, -100.5, -1},
-				Radius:   100,
-				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
-		},
-		SkyColorAbove: Vector3{0, 0, 0},
-		SkyColorBelow: Vector3{0, 0, 0},
-	}
-}
-
-// func newTestWorldThreeTriangles() World {
-// 	position := Vector3{0, 0, 0}
-// 	lookAt := Vector3{0, 0, -1.0}
-// 	up := Vector3{0, 1, 0}
-// 	aperture := 0.0
-// 	focusDistance := position.Subtract(lookAt).Length()
-// 	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
-// 	return World{
-// 		Camera: camera,
-// 		Hittables: []Hittable{
-// 			Triangle{
-// 				V0:       Vector3{-2.0, -1.0, -2.5},
-// 				V1:       Vector3{0.0, -1.0, -2.5},
-// 				V2:       Vector3{-1.0, 1.0, -2.5},
-// 				Material: Lambertian{Color: Vector3{0.8, 0.2, 0.2}}},
-// 			Triangle{
-// 				V0:       Vector3{-1.5, -1.0, -2.8},
-// 				V1:       Vector3{0.5, -1.0, -2.8},
-// 				V2:       Vector3{-0.5, 1.0, -2.8},
-// 				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
-// 			Triangle{
-// 				V0:       Vector3{-1.0, -1.0, -3.0},
-// 				V1:       Vector3{1.0, -1.0, -3.0},
-// 				V2:       Vector3{0.0, 1.0, -3.0},
-// 				Material: Lambertian{Color: Vector3{0.2, 0.2, 0.8}}},
-// 			Sphere{
-// 				Position: Vector3{0, 0, -1},
-// 				Radius:   0.1,
-// 				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
-// 			Sphere{
-// 				Position: Vector3{0, -100.5, -1},
-// 				Radius:   100,
-// 				Material: Lambertian{Color: Vector3{0.2, 0.8, 0.2}}},
-// 		}}
-// }
-
-func newTestWorldTestSceneObj() World {
-	position := Vector3{0, 0.5, 1}
-	lookAt := Vector3{0, 0, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 1.0 / 16.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
-	triangles := ReadObj("test_scene.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}})
-	hittables := make([]Hittable, len(triangles))
-	for i := range triangles {
-		hittables[i] = triangles[i]
-	}
-
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
-		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
-	}
-}
-
-func newTestWorldIcoSphere() World {
-	position := Vector3{0, 0.8, 1}
-	lookAt := Vector3{0, 0.5, 0}
-	up := Vector3{0, 1, 0}
-	aperture := 1.0 / 32.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
-	triangles := ReadObj("icosphere_smooth.obj", Metal{Color: Vector3{0.8, 0.8, 1.0}, Glosiness: 0.99})
-	// triangles := ReadObj("icosphere_smooth.obj", Lambertian{Color: Vector3{0.4, 0.4, 0.9}})
-	// triangles := ReadObj("icosphere_smooth.obj", Dielectric{IndexOfRefraction: 1.4})
-	hittables := make([]Hittable, len(triangles))
-	for i := range triangles {
-		hittables[i] = triangles[i]
-	}
-	hittables = append(hittables, Sphere{
-		Position: Vector3{0, -100.5, -1},
-		Radius:   100,
-		Material: Lambertian{Color: Vector3{0.8, 0.2, 0.2}}})
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
-		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
-	}
-}
-
-func newTestWorldTeapot() World {
-	position := Vector3{0, 0.5, 1}
-	lookAt := Vector3{0, 0, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 0.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 90.0, aperture, focusDistance, width, height)
-	triangles := ReadObj("teapot.obj", Metal{Color: Vector3{0.9, 0.3, 0.3}, Glosiness: 0.99})
-	hittables := make([]Hittable, len(triangles))
-	for i := range triangles {
-		hittables[i] = triangles[i]
-	}
-	hittables = append(hittables, Sphere{
-		Position: Vector3{0, -100.5, -1},
-		Radius:   100,
-		Material: Lambertian{Color: Vector3{0.6, 0.6, 0.6}}})
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
-		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
-	}
-}
-
-func newTestWorldCornellBox() World {
-	position := Vector3{0, 1, 1.8}
-	lookAt := Vector3{0, 1, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 0.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
-
-	hittables := convertoToHittables(
-		ReadObj("objs/cornell/bottom_and_back_wall.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}),
-		ReadObj("objs/cornell/ceiling.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}),
-		// ReadObj("objs/cornell/ceiling.obj", Light{Emission: Vector3{1.0, 1.0, 1.0}}),
-		ReadObj("objs/cornell/big_light.obj", Light{Emission: Vector3{1.0, 1.0, 1.0}}),
-		ReadObj("objs/cornell/cube.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}),
-		ReadObj("objs/cornell/left_wall.obj", Lambertian{Color: Vector3{0.8, 0.3, 0.3}}),
-		ReadObj("objs/cornell/right_wall.obj", Lambertian{Color: Vector3{0.3, 0.8, 0.3}}),
-	)
-
-	hittables = append(hittables, Sphere{
-		Position: Vector3{-0.44, 0.4, -1.1},
-		Radius:   0.4,
-		Material: Metal{Color: Vector3{0.9, 0.9, 0.9}, Glosiness: 0.99},
-		// Material: Dielectric{IndexOfRefraction: 1.4},
-	})
-
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0, 0, 0},
-		SkyColorBelow: Vector3{0, 0, 0},
-	}
-}
-
-func newTestWorldPlanet() World {
-	position := Vector3{0, 0, 0}
-	lookAt := Vector3{0, 0, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 0.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
-	return World{
-		Camera: camera,
-		Hittables: []Hittable{
-			Sphere{
-				Position: Vector3{0, 0, -3},
-				Radius:   0.5,
-				Material: Lambertian{Color: Vector3{0.8, 0.8, 0.8}}},
-			Sphere{
-				Position: Vector3{-50, 50, -15},
-				Radius:   45,
-				Material: Light{Emission: Vector3{2.0, 2.0, 2.0}}},
-		},
-		SkyColorAbove: Vector3{0, 0, 0},
-		SkyColorBelow: Vector3{0, 0, 0},
-	}
-}
-
-func newTestWorldStairs() World {
-	position := Vector3{0, 1, 1.8}
-	lookAt := Vector3{0, 1, -1.0}
-	up := Vector3{0, 1, 0}
-	aperture := 0.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 55.0, aperture, focusDistance, width, height)
-
-	wallColor := Vector3{0.8, 0.8, 0.8}
-	hittables := convertoToHittables(
-		ReadObj("objs/stairs/stairs_lower.obj", Lambertian{Color: wallColor}),
-		ReadObj("objs/stairs/stairs_upper.obj", Lambertian{Color: wallColor}),
-		ReadObj("objs/stairs/walls.obj", Lambertian{Color: wallColor}),
-		ReadObj("objs/stairs/light.obj", Light{Emission: Vector3{2.0, 2.0, 2.0}}),
-	)
-
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0, 0, 0},
-		SkyColorBelow: Vector3{0, 0, 0},
-	}
-}
-
-func newTestWorldPyramid() World {
-	position := Vector3{0, 3, 2.6}
-	lookAt := Vector3{0, 1, -800.0}
-	up := Vector3{0, 1, 0}
-	aperture := 1.0 / 3.0
-	focusDistance := position.Subtract(lookAt).Length()
-	camera := NewCamera(position, lookAt, up, 22.0, aperture, focusDistance, width, height)
-
-	hittables := convertoToHittables(
-		ReadObj("objs/pyramid/pyramid.obj", Lambertian{Color: Vector3{0.8, 0.8, 0.8}}),
-	)
-
-	hittables = append(hittables,
-		// Sphere{
-		// 	Position: Vector3{400, 400, -200},
-		// 	Radius:   400,
-		// 	Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
-		// },
-		// Sphere{
-		// 	Position: Vector3{600, 600, -400},
-		// 	Radius:   400,
-		// 	Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
-		// },
-		Sphere{
-			Position: Vector3{400, 400, 0},
-			Radius:   400,
-			Material: Light{Emission: Vector3{2.0, 2.0, 2.0}},
-		})
-
-	return World{
-		Camera:        camera,
-		Hittables:     hittables,
-		SkyColorAbove: Vector3{0.5, 0.7, 1.0},
-		SkyColorBelow: Vector3{1.0, 1.0, 1.0},
-	}
-}
-
-func convertoToHittables(triangleArrays ...[]Triangle) []Hittable {
-	var hittables []Hittable
-	for _, array := range triangleArrays {
-		h := make([]Hittable, len(array))
-		for i := range array {
-			h[i] = array[i]
-		}
-		hittables = append(hittables, h...)
-	}
-	return hittables
-}